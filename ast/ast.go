@@ -0,0 +1,281 @@
+// Package ast parses GraphQL documents - executable ones holding operations and fragments, and
+// type system ones (SDL) describing a schema.
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Operation types
+const (
+	Query        = "query"
+	Mutation     = "mutation"
+	Subscription = "subscription"
+)
+
+// Document is an executable document: operations and the fragments they use
+type Document struct {
+	Operations []*OperationDefinition
+	Fragments  []*FragmentDefinition
+}
+
+// OperationDefinition is a query, mutation or subscription. End is the byte offset right
+// after it, so Source[Offset:End] is the definition as written.
+type OperationDefinition struct {
+	Operation           string // Query, Mutation or Subscription
+	Name                string // Empty for anonymous operations
+	VariableDefinitions []*VariableDefinition
+	Directives          []*Directive
+	SelectionSet        SelectionSet
+	Position
+	End int
+}
+
+// VariableDefinition declares a variable of an operation
+type VariableDefinition struct {
+	Type         *Type
+	DefaultValue *Value // Nil without a default
+	Variable     string // Name without the $
+	Directives   []*Directive
+	Position
+}
+
+// FragmentDefinition is a named fragment. End is the byte offset right after it.
+type FragmentDefinition struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  SelectionSet
+	Position
+	End int
+}
+
+// SelectionSet lists the fields and fragments selected on an object
+type SelectionSet []Selection
+
+// Selection is a *Field, a *FragmentSpread or an *InlineFragment
+type Selection interface {
+	Pos() Position
+}
+
+// Field selects a field, under its alias when it has one
+type Field struct {
+	Alias        string // Empty when not aliased
+	Name         string
+	Arguments    []*Argument
+	Directives   []*Directive
+	SelectionSet SelectionSet
+	Position
+}
+
+// ResponseKey is the key of the field in the response: its alias, or its name
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// FragmentSpread includes a named fragment
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Position
+}
+
+// InlineFragment selects fields, optionally only on objects of a type
+type InlineFragment struct {
+	TypeCondition string // Empty when the fragment applies to the enclosing type
+	Directives    []*Directive
+	SelectionSet  SelectionSet
+	Position
+}
+
+// Argument is a named value passed to a field or directive
+type Argument struct {
+	Value *Value
+	Name  string
+	Position
+}
+
+// Directive annotates a definition, selection or type system member
+type Directive struct {
+	Name      string
+	Arguments []*Argument
+	Position
+}
+
+// Argument returns the named argument, or nil when it wasn't given
+func (d *Directive) Argument(name string) *Argument {
+	for _, arg := range d.Arguments {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// ValueKind is the kind of a value
+type ValueKind int
+
+const (
+	Variable ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+// Value is a literal or a variable. Raw holds scalars as written - strings with their quotes -
+// and the name of variables without the $.
+type Value struct {
+	Raw    string
+	List   []*Value       // Items of lists
+	Fields []*ObjectField // Fields of objects
+	Position
+	Kind ValueKind
+}
+
+// String renders the value as a GraphQL literal, like {id: {_eq: $id}}. Block strings are
+// rendered as regular strings.
+func (v *Value) String() string {
+	switch v.Kind {
+	case StringValue:
+		if strings.HasPrefix(v.Raw, `"""`) {
+			return Quote(Unquote(v.Raw))
+		}
+	case Variable:
+		return "$" + v.Raw
+	case ListValue:
+		items := make([]string, len(v.List))
+		for i, item := range v.List {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case ObjectValue:
+		fields := make([]string, len(v.Fields))
+		for i, field := range v.Fields {
+			fields[i] = field.Name + ": " + field.Value.String()
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return v.Raw
+}
+
+// ObjectField is a field of an object value
+type ObjectField struct {
+	Value *Value
+	Name  string
+	Position
+}
+
+// Type is a type reference: a named type, or a list of Elem, optionally non-null
+type Type struct {
+	Elem    *Type // Item type of lists, nil for named types
+	Name    string
+	NonNull bool
+	Position
+}
+
+// NamedType returns the name inside all the lists and non-nulls
+func (t *Type) NamedType() string {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	return t.Name
+}
+
+// String renders the type in GraphQL notation, like [String!]!
+func (t *Type) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+	return s
+}
+
+// Unquote returns the value of a string or block string literal as written in the source.
+// Block strings have their common indentation and surrounding blank lines removed.
+func Unquote(raw string) string {
+	if strings.HasPrefix(raw, `"""`) && strings.HasSuffix(raw, `"""`) && len(raw) >= 6 {
+		return blockStringValue(strings.ReplaceAll(raw[3:len(raw)-3], `\"""`, `"""`))
+	}
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return raw
+	}
+
+	var b strings.Builder
+	body := raw[1 : len(raw)-1]
+	for i := 0; i < len(body); i++ {
+		if body[i] != '\\' || i+1 == len(body) {
+			b.WriteByte(body[i])
+			continue
+		}
+		i++
+		switch body[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if i+4 < len(body) {
+				if r, err := strconv.ParseUint(body[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteString(`\u`)
+		default: // \" \\ \/
+			b.WriteByte(body[i])
+		}
+	}
+	return b.String()
+}
+
+// blockStringValue removes the common indentation and the leading and trailing blank lines
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw), "\n")
+
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
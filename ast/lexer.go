@@ -0,0 +1,249 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position is where a token or node starts in the source. Lines and columns count from 1.
+type Position struct {
+	Line   int
+	Column int
+	Offset int // Byte offset from the start of the source
+}
+
+// Pos returns the position itself, so nodes embedding it implement Selection
+func (p Position) Pos() Position {
+	return p
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// SyntaxError is a source that can't be tokenized or parsed
+type SyntaxError struct {
+	Message string
+	Position
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %s: %s", e.Position, e.Message)
+}
+
+// TokenKind is the kind of a lexical token
+type TokenKind int
+
+const (
+	EOF TokenKind = iota
+	Punctuator
+	Name
+	Int
+	Float
+	String
+	BlockString
+)
+
+// Token is a lexical token. Value is the punctuator, the name or the literal as written,
+// strings with their quotes.
+type Token struct {
+	Value string
+	Position
+	End  int // Byte offset right after the token
+	Kind TokenKind
+}
+
+func (t Token) String() string {
+	if t.Kind == EOF {
+		return "<EOF>"
+	}
+	return fmt.Sprintf("%q", t.Value)
+}
+
+// Lexer splits a GraphQL source into tokens, skipping whitespace, commas and comments
+type Lexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int // Offset of the first character of the current line
+}
+
+// NewLexer returns a lexer reading src
+func NewLexer(src string) *Lexer {
+	return &Lexer{src: src, line: 1}
+}
+
+func (l *Lexer) position() Position {
+	return Position{Line: l.line, Column: l.pos - l.lineStart + 1, Offset: l.pos}
+}
+
+func (l *Lexer) newline() {
+	l.line++
+	l.lineStart = l.pos
+}
+
+// skipIgnored moves past whitespace, line terminators, commas, comments and the byte order mark
+func (l *Lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == ' ' || c == '\t' || c == ',':
+			l.pos++
+		case c == '\n':
+			l.pos++
+			l.newline()
+		case c == '\r':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.pos++
+			}
+			l.newline()
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		default:
+			return
+		}
+	}
+}
+
+// Next returns the next token, or an EOF token at the end of the source
+func (l *Lexer) Next() (Token, error) {
+	l.skipIgnored()
+	start := l.position()
+	token, err := l.read(start)
+	token.Position, token.End = start, l.pos
+	return token, err
+}
+
+func (l *Lexer) read(start Position) (Token, error) {
+	if l.pos >= len(l.src) {
+		return Token{Kind: EOF}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+		l.pos++
+		return Token{Kind: Punctuator, Value: string(c)}, nil
+	case c == '.':
+		if strings.HasPrefix(l.src[l.pos:], "...") {
+			l.pos += 3
+			return Token{Kind: Punctuator, Value: "..."}, nil
+		}
+		return Token{}, &SyntaxError{Message: `unexpected ".", did you mean "..."?`, Position: start}
+	case isNameStart(c):
+		begin := l.pos
+		for l.pos < len(l.src) && isNameContinue(l.src[l.pos]) {
+			l.pos++
+		}
+		return Token{Kind: Name, Value: l.src[begin:l.pos]}, nil
+	case c == '-' || isDigit(c):
+		return l.number(start)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString(start)
+		}
+		return l.string(start)
+	}
+	return Token{}, &SyntaxError{Message: fmt.Sprintf("unexpected character %q", c), Position: start}
+}
+
+func (l *Lexer) number(start Position) (Token, error) {
+	begin := l.pos
+	digits := func() int {
+		from := l.pos
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		return l.pos - from
+	}
+
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	leadingZero := l.pos < len(l.src) && l.src[l.pos] == '0'
+	if n := digits(); n == 0 {
+		return Token{}, &SyntaxError{Message: "invalid number, expected digit", Position: l.position()}
+	} else if leadingZero && n > 1 {
+		return Token{}, &SyntaxError{Message: "invalid number, unexpected digit after 0", Position: start}
+	}
+
+	kind := Int
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = Float
+		l.pos++
+		if digits() == 0 {
+			return Token{}, &SyntaxError{Message: `invalid number, expected digit after "."`, Position: l.position()}
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = Float
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return Token{}, &SyntaxError{Message: "invalid number, expected digit in exponent", Position: l.position()}
+		}
+	}
+	if l.pos < len(l.src) && (isNameStart(l.src[l.pos]) || l.src[l.pos] == '.') {
+		return Token{}, &SyntaxError{Message: fmt.Sprintf("invalid number, unexpected %q", l.src[l.pos]), Position: l.position()}
+	}
+	return Token{Kind: kind, Value: l.src[begin:l.pos]}, nil
+}
+
+func (l *Lexer) string(start Position) (Token, error) {
+	begin := l.pos
+	for l.pos++; l.pos < len(l.src); l.pos++ {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos++
+		case '"':
+			l.pos++
+			return Token{Kind: String, Value: l.src[begin:l.pos]}, nil
+		case '\n', '\r':
+			return Token{}, &SyntaxError{Message: "unterminated string", Position: start}
+		}
+	}
+	return Token{}, &SyntaxError{Message: "unterminated string", Position: start}
+}
+
+func (l *Lexer) blockString(start Position) (Token, error) {
+	begin := l.pos
+	for l.pos += 3; l.pos < len(l.src); {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			l.pos += 4
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.pos += 3
+			return Token{Kind: BlockString, Value: l.src[begin:l.pos]}, nil
+		case l.src[l.pos] == '\n':
+			l.pos++
+			l.newline()
+		case l.src[l.pos] == '\r':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.pos++
+			}
+			l.newline()
+		default:
+			l.pos++
+		}
+	}
+	return Token{}, &SyntaxError{Message: "unterminated block string", Position: start}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,434 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// parser builds nodes from tokens, looking one token ahead
+type parser struct {
+	lex     *Lexer
+	tok     Token
+	prevEnd int // Byte offset right after the last consumed token
+}
+
+func newParser(src string) (*parser, error) {
+	p := &parser{lex: NewLexer(src)}
+	return p, p.advance()
+}
+
+// Parse parses an executable document: operations and fragments
+func Parse(src string) (*Document, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	for p.tok.Kind != EOF {
+		switch {
+		case p.peek(Punctuator, "{") || p.peek(Name, Query) || p.peek(Name, Mutation) || p.peek(Name, Subscription):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peek(Name, "fragment"):
+			fragment, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			doc.Fragments = append(doc.Fragments, fragment)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 && len(doc.Fragments) == 0 {
+		return nil, &SyntaxError{Message: "empty document", Position: p.tok.Position}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.Next()
+	if err != nil {
+		return err
+	}
+	p.prevEnd = p.tok.End
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(kind TokenKind, value string) bool {
+	return p.tok.Kind == kind && p.tok.Value == value
+}
+
+func (p *parser) unexpected() error {
+	return &SyntaxError{Message: "unexpected " + p.tok.String(), Position: p.tok.Position}
+}
+
+// expect consumes the punctuator
+func (p *parser) expect(punctuator string) error {
+	if !p.peek(Punctuator, punctuator) {
+		return &SyntaxError{Message: fmt.Sprintf("expected %q, found %s", punctuator, p.tok), Position: p.tok.Position}
+	}
+	return p.advance()
+}
+
+// skip consumes the punctuator when it's next, reporting whether it was
+func (p *parser) skip(punctuator string) (bool, error) {
+	if !p.peek(Punctuator, punctuator) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.Kind != Name {
+		return "", &SyntaxError{Message: "expected Name, found " + p.tok.String(), Position: p.tok.Position}
+	}
+	name := p.tok.Value
+	return name, p.advance()
+}
+
+// keyword consumes the name, which must be word
+func (p *parser) keyword(word string) error {
+	if !p.peek(Name, word) {
+		return &SyntaxError{Message: fmt.Sprintf("expected %q, found %s", word, p.tok), Position: p.tok.Position}
+	}
+	return p.advance()
+}
+
+func (p *parser) operation() (*OperationDefinition, error) {
+	op := &OperationDefinition{Operation: Query, Position: p.tok.Position}
+	var err error
+	if p.peek(Punctuator, "{") {
+		if op.SelectionSet, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+		op.End = p.prevEnd
+		return op, nil
+	}
+
+	op.Operation = p.tok.Value
+	if err = p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind == Name {
+		if op.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if op.VariableDefinitions, err = p.variableDefinitions(); err != nil {
+		return nil, err
+	}
+	if op.Directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	if op.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	op.End = p.prevEnd
+	return op, nil
+}
+
+func (p *parser) variableDefinitions() ([]*VariableDefinition, error) {
+	if open, err := p.skip("("); !open || err != nil {
+		return nil, err
+	}
+	var definitions []*VariableDefinition
+	for {
+		definition := &VariableDefinition{Position: p.tok.Position}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		var err error
+		if definition.Variable, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if definition.Type, err = p.typeReference(); err != nil {
+			return nil, err
+		}
+		if definition.DefaultValue, err = p.defaultValue(); err != nil {
+			return nil, err
+		}
+		if definition.Directives, err = p.directives(true); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+		if closed, err := p.skip(")"); closed || err != nil {
+			return definitions, err
+		}
+	}
+}
+
+// defaultValue parses "= value" when it's next
+func (p *parser) defaultValue() (*Value, error) {
+	if hasDefault, err := p.skip("="); !hasDefault || err != nil {
+		return nil, err
+	}
+	return p.value(true)
+}
+
+func (p *parser) typeReference() (*Type, error) {
+	t := &Type{Position: p.tok.Position}
+	var err error
+	if open, err := p.skip("["); err != nil {
+		return nil, err
+	} else if open {
+		if t.Elem, err = p.typeReference(); err != nil {
+			return nil, err
+		}
+		if err = p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if t.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if t.NonNull, err = p.skip("!"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (p *parser) fragment() (*FragmentDefinition, error) {
+	fragment := &FragmentDefinition{Position: p.tok.Position}
+	if err := p.keyword("fragment"); err != nil {
+		return nil, err
+	}
+	if p.peek(Name, "on") {
+		return nil, &SyntaxError{Message: `unexpected "on", fragments need a name`, Position: p.tok.Position}
+	}
+	var err error
+	if fragment.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err = p.keyword("on"); err != nil {
+		return nil, err
+	}
+	if fragment.TypeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if fragment.Directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	if fragment.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	fragment.End = p.prevEnd
+	return fragment, nil
+}
+
+func (p *parser) selectionSet() (SelectionSet, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections SelectionSet
+	for {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+		if closed, err := p.skip("}"); closed || err != nil {
+			return selections, err
+		}
+	}
+}
+
+func (p *parser) selection() (Selection, error) {
+	start := p.tok.Position
+	if spread, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if spread {
+		return p.fragmentSelection(start)
+	}
+
+	f := &Field{Position: start}
+	var err error
+	if f.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if aliased, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if aliased {
+		f.Alias = f.Name
+		if f.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.Arguments, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.Directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	if p.peek(Punctuator, "{") {
+		if f.SelectionSet, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// fragmentSelection parses what follows "...": a fragment name or an inline fragment
+func (p *parser) fragmentSelection(start Position) (Selection, error) {
+	var err error
+	if p.tok.Kind == Name && p.tok.Value != "on" {
+		spread := &FragmentSpread{Position: start}
+		if spread.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if spread.Directives, err = p.directives(false); err != nil {
+			return nil, err
+		}
+		return spread, nil
+	}
+
+	inline := &InlineFragment{Position: start}
+	if p.peek(Name, "on") {
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+		if inline.TypeCondition, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if inline.Directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	if inline.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return inline, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*Argument, error) {
+	if open, err := p.skip("("); !open || err != nil {
+		return nil, err
+	}
+	var arguments []*Argument
+	for {
+		arg := &Argument{Position: p.tok.Position}
+		var err error
+		if arg.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arg.Value, err = p.value(constant); err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, arg)
+		if closed, err := p.skip(")"); closed || err != nil {
+			return arguments, err
+		}
+	}
+}
+
+func (p *parser) directives(constant bool) ([]*Directive, error) {
+	var directives []*Directive
+	for p.peek(Punctuator, "@") {
+		d := &Directive{Position: p.tok.Position}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if d.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.Arguments, err = p.arguments(constant); err != nil {
+			return nil, err
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+// value parses a value; constant ones, like defaults, can't hold variables
+func (p *parser) value(constant bool) (*Value, error) {
+	v := &Value{Position: p.tok.Position, Raw: p.tok.Value}
+	switch p.tok.Kind {
+	case Int:
+		v.Kind = IntValue
+	case Float:
+		v.Kind = FloatValue
+	case String, BlockString:
+		v.Kind = StringValue
+	case Name:
+		switch p.tok.Value {
+		case "true", "false":
+			v.Kind = BooleanValue
+		case "null":
+			v.Kind = NullValue
+		default:
+			v.Kind = EnumValue
+		}
+	case Punctuator:
+		switch p.tok.Value {
+		case "$":
+			if constant {
+				return nil, &SyntaxError{Message: "unexpected variable in a constant value", Position: p.tok.Position}
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			var err error
+			v.Kind = Variable
+			v.Raw, err = p.name()
+			return v, err
+		case "[":
+			return p.listValue(v, constant)
+		case "{":
+			return p.objectValue(v, constant)
+		}
+		return nil, p.unexpected()
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.advance()
+}
+
+func (p *parser) listValue(v *Value, constant bool) (*Value, error) {
+	v.Kind, v.Raw = ListValue, ""
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for {
+		if closed, err := p.skip("]"); closed || err != nil {
+			return v, err
+		}
+		item, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		v.List = append(v.List, item)
+	}
+}
+
+func (p *parser) objectValue(v *Value, constant bool) (*Value, error) {
+	v.Kind, v.Raw = ObjectValue, ""
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	for {
+		if closed, err := p.skip("}"); closed || err != nil {
+			return v, err
+		}
+		field := &ObjectField{Position: p.tok.Position}
+		var err error
+		if field.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if field.Value, err = p.value(constant); err != nil {
+			return nil, err
+		}
+		v.Fields = append(v.Fields, field)
+	}
+}
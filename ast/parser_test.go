@@ -0,0 +1,108 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `
+# Fetch users
+query GetUsers($limit: Int = 10, $where: users_bool_exp!, $ids: [uuid!]) @cached(ttl: 60) {
+  list: users(limit: $limit, where: {id: {_in: $ids}, name: "a \"b\""}) {
+    ...UserFields
+    ... on users @include(if: true) { role }
+  }
+}
+
+fragment UserFields on users {
+  id
+  bio(format: """block "quoted" \""" string""")
+}
+mutation { delete_users(where: {}, ratio: -1.5e3, flags: [A, B], nothing: null) }
+{ __typename }`
+	doc, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(doc.Operations) != 3 || len(doc.Fragments) != 1 {
+		t.Fatalf("Parse() = %d operations and %d fragments, want 3 and 1", len(doc.Operations), len(doc.Fragments))
+	}
+
+	op := doc.Operations[0]
+	if op.Operation != Query || op.Name != "GetUsers" || op.Position != (Position{Line: 3, Column: 1, Offset: 15}) {
+		t.Errorf("operation = %s %q at %s", op.Operation, op.Name, op.Position)
+	}
+	if text := src[op.Offset:op.End]; !strings.HasPrefix(text, "query GetUsers(") || !strings.HasSuffix(text, "}\n}") {
+		t.Errorf("operation source = %q", text)
+	}
+	if fragment := doc.Fragments[0]; !strings.HasPrefix(src[fragment.Offset:fragment.End], "fragment UserFields on users {") || src[fragment.End:fragment.End+1] != "\n" {
+		t.Errorf("fragment source = %q", src[fragment.Offset:fragment.End])
+	}
+	if len(op.VariableDefinitions) != 3 || op.VariableDefinitions[1].Type.String() != "users_bool_exp!" || op.VariableDefinitions[2].Type.String() != "[uuid!]" {
+		t.Errorf("variables = %+v", op.VariableDefinitions)
+	}
+	if op.VariableDefinitions[0].DefaultValue == nil || op.VariableDefinitions[0].DefaultValue.Raw != "10" {
+		t.Errorf("default value of $limit = %+v, want 10", op.VariableDefinitions[0].DefaultValue)
+	}
+	if len(op.Directives) != 1 || op.Directives[0].Name != "cached" {
+		t.Errorf("directives = %+v", op.Directives)
+	}
+
+	list := op.SelectionSet[0].(*Field)
+	if list.Alias != "list" || list.Name != "users" || list.Position != (Position{Line: 4, Column: 3, Offset: 109}) {
+		t.Errorf("field = %q: %q at %s", list.Alias, list.Name, list.Position)
+	}
+	where := list.Arguments[1].Value
+	if where.Kind != ObjectValue || where.Fields[1].Value.Raw != `"a \"b\""` || where.Fields[0].Value.Fields[0].Value.Kind != Variable {
+		t.Errorf("where = %+v", where)
+	}
+	if spread, ok := list.SelectionSet[0].(*FragmentSpread); !ok || spread.Name != "UserFields" {
+		t.Errorf("first selection = %+v, want a spread of UserFields", list.SelectionSet[0])
+	}
+	if inline, ok := list.SelectionSet[1].(*InlineFragment); !ok || inline.TypeCondition != "users" || len(inline.Directives) != 1 {
+		t.Errorf("second selection = %+v, want an inline fragment on users", list.SelectionSet[1])
+	}
+
+	bio := doc.Fragments[0].SelectionSet[1].(*Field)
+	if bio.Arguments[0].Value.Kind != StringValue || bio.Arguments[0].Value.Raw != `"""block "quoted" \""" string"""` {
+		t.Errorf("block string = %q", bio.Arguments[0].Value.Raw)
+	}
+
+	mutation := doc.Operations[1].SelectionSet[0].(*Field)
+	kinds := []ValueKind{ObjectValue, FloatValue, ListValue, NullValue}
+	for i, arg := range mutation.Arguments {
+		if arg.Value.Kind != kinds[i] {
+			t.Errorf("argument %s kind = %d, want %d", arg.Name, arg.Value.Kind, kinds[i])
+		}
+	}
+	if shorthand := doc.Operations[2]; shorthand.Operation != Query || shorthand.Name != "" {
+		t.Errorf("shorthand = %s %q, want an anonymous query", shorthand.Operation, shorthand.Name)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "syntax error at 1:1: empty document"},
+		{"query {", "syntax error at 1:8: expected Name, found <EOF>"},
+		{"query { users { } }", `syntax error at 1:17: expected Name, found "}"`},
+		{"query ($id uuid) { users }", `syntax error at 1:12: expected ":", found "uuid"`},
+		{"query {\n  users(limit: 01)\n}", "syntax error at 2:16: invalid number, unexpected digit after 0"},
+		{`{ users(name: "unterminated) }`, "syntax error at 1:15: unterminated string"},
+		{"{ users(limit: $) }", `syntax error at 1:17: expected Name, found ")"`},
+		{"query($a: Int = $b) { users }", "syntax error at 1:17: unexpected variable in a constant value"},
+		{"fragment on users { id }", `syntax error at 1:10: unexpected "on", fragments need a name`},
+		{"{ users } ?", "syntax error at 1:11: unexpected character '?'"},
+		{"{ users.id }", `syntax error at 1:8: unexpected ".", did you mean "..."?`},
+		{"type User { id: ID }", `syntax error at 1:1: unexpected "type"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %s", tt.query, err, tt.want)
+		}
+	}
+}
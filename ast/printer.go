@@ -0,0 +1,158 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Print renders the document on a single line, without comments or insignificant whitespace.
+// Operations come first, then fragments, each in the order they were defined. Block strings are
+// printed as regular strings holding the same value.
+func Print(doc *Document) string {
+	p := &printer{}
+	for _, op := range doc.Operations {
+		p.separate()
+		p.operation(op)
+	}
+	for _, fragment := range doc.Fragments {
+		p.separate()
+		p.fragment(fragment)
+	}
+	return p.String()
+}
+
+// Minify parses src and prints it back with Print, so the result means exactly the same
+func Minify(src string) (string, error) {
+	doc, err := Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return Print(doc), nil
+}
+
+// Quote renders s as a GraphQL string literal
+func Quote(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, r)
+				continue
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+type printer struct {
+	strings.Builder
+}
+
+// separate puts a space between two definitions
+func (p *printer) separate() {
+	if p.Len() > 0 {
+		p.WriteByte(' ')
+	}
+}
+
+func (p *printer) operation(op *OperationDefinition) {
+	p.WriteString(op.Operation)
+	if op.Name != "" {
+		p.WriteByte(' ')
+		p.WriteString(op.Name)
+	}
+	if len(op.VariableDefinitions) > 0 {
+		p.WriteByte('(')
+		for i, definition := range op.VariableDefinitions {
+			if i > 0 {
+				p.WriteString(", ")
+			}
+			p.WriteString("$" + definition.Variable + ": " + definition.Type.String())
+			if definition.DefaultValue != nil {
+				p.WriteString(" = " + definition.DefaultValue.String())
+			}
+			p.directives(definition.Directives)
+		}
+		p.WriteByte(')')
+	}
+	p.directives(op.Directives)
+	p.selectionSet(op.SelectionSet)
+}
+
+func (p *printer) fragment(fragment *FragmentDefinition) {
+	p.WriteString("fragment " + fragment.Name + " on " + fragment.TypeCondition)
+	p.directives(fragment.Directives)
+	p.selectionSet(fragment.SelectionSet)
+}
+
+func (p *printer) selectionSet(set SelectionSet) {
+	p.WriteByte('{')
+	for i, s := range set {
+		if i > 0 {
+			p.WriteByte(' ')
+		}
+		switch s := s.(type) {
+		case *Field:
+			if s.Alias != "" {
+				p.WriteString(s.Alias + ": ")
+			}
+			p.WriteString(s.Name)
+			p.arguments(s.Arguments)
+			p.directives(s.Directives)
+			if len(s.SelectionSet) > 0 {
+				p.selectionSet(s.SelectionSet)
+			}
+		case *FragmentSpread:
+			p.WriteString("..." + s.Name)
+			p.directives(s.Directives)
+		case *InlineFragment:
+			p.WriteString("...")
+			if s.TypeCondition != "" {
+				p.WriteString("on " + s.TypeCondition)
+			}
+			p.directives(s.Directives)
+			p.selectionSet(s.SelectionSet)
+		}
+	}
+	p.WriteByte('}')
+}
+
+func (p *printer) arguments(arguments []*Argument) {
+	if len(arguments) == 0 {
+		return
+	}
+	p.WriteByte('(')
+	for i, arg := range arguments {
+		if i > 0 {
+			p.WriteString(", ")
+		}
+		p.WriteString(arg.Name + ": " + arg.Value.String())
+	}
+	p.WriteByte(')')
+}
+
+func (p *printer) directives(directives []*Directive) {
+	for _, d := range directives {
+		p.WriteString(" @" + d.Name)
+		p.arguments(d.Arguments)
+	}
+}
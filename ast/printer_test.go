@@ -0,0 +1,58 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestMinify(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"{ users { id } }", "query{users{id}}"},
+		{
+			"query GetUsers($ids: [uuid!]! = [], $where: users_bool_exp = {name: {_eq: \"x\"}}) @cached(ttl: 60) {\n  list: users(where: $where) { id ...F }\n}\nfragment F on users @skip(if: false) { name }",
+			`query GetUsers($ids: [uuid!]! = [], $where: users_bool_exp = {name: {_eq: "x"}}) @cached(ttl: 60){list: users(where: $where){id ...F}} fragment F on users @skip(if: false){name}`,
+		},
+		{"fragment F on users { id }\n# comment\nquery Q { ...F }", "query Q{...F} fragment F on users{id}"},
+		{"query { a(s: \"#not a comment\", n: -1.5e3, b: true, e: ENUM, z: null, l: [1, 2], o: {}) }", `query{a(s: "#not a comment", n: -1.5e3, b: true, e: ENUM, z: null, l: [1, 2], o: {})}`},
+		{"query {\n  a(text: \"\"\"\n    line \\\"\"\" \"one\"\n      line two\n  \"\"\")\n}", `query{a(text: "line \"\"\" \"one\"\n  line two")}`},
+		{"subscription OnUser { user { ... on Admin { level } ... @include(if: true) { id } } }", "subscription OnUser{user{...on Admin{level} ... @include(if: true){id}}}"},
+	}
+	for _, tt := range tests {
+		got, err := Minify(tt.src)
+		if err != nil {
+			t.Errorf("Minify(%q) error = %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Minify(%q)\n got %s\nwant %s", tt.src, got, tt.want)
+		}
+		// The output parses back into the same document
+		if again, err := Minify(got); err != nil || again != got {
+			t.Errorf("Minify(%q) = %q, %v, want it unchanged", got, again, err)
+		}
+	}
+
+	if _, err := Minify("query { users(name: 'single') }"); err == nil {
+		t.Error("Minify() of an invalid document should fail")
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":            `"plain"`,
+		`say "hi" \ bye`:   `"say \"hi\" \\ bye"`,
+		"tab\tnew\nline\r": `"tab\tnew\nline\r"`,
+		"bell\x07":         `"bell\u0007"`,
+		"emoji 😈 and 巴结":   `"emoji 😈 and 巴结"`,
+	}
+	for s, want := range tests {
+		if got := Quote(s); got != want {
+			t.Errorf("Quote(%q) = %s, want %s", s, got, want)
+		}
+		if Unquote(want) != s {
+			t.Errorf("Unquote(%s) = %q, want %q", want, Unquote(want), s)
+		}
+	}
+}
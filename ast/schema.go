@@ -0,0 +1,425 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// Type definition kinds, named like the __TypeKind values of introspection
+const (
+	KindScalar      = "SCALAR"
+	KindObject      = "OBJECT"
+	KindInterface   = "INTERFACE"
+	KindUnion       = "UNION"
+	KindEnum        = "ENUM"
+	KindInputObject = "INPUT_OBJECT"
+)
+
+var definitionKinds = map[string]string{
+	"scalar":    KindScalar,
+	"type":      KindObject,
+	"interface": KindInterface,
+	"union":     KindUnion,
+	"enum":      KindEnum,
+	"input":     KindInputObject,
+}
+
+// SchemaDocument is a type system document (SDL). Extensions are merged into the definitions
+// they extend.
+type SchemaDocument struct {
+	Schema     *SchemaDefinition // Nil when the document has no schema definition
+	Types      []*TypeDefinition
+	Directives []*DirectiveDefinition
+}
+
+// SchemaDefinition names the root operation types
+type SchemaDefinition struct {
+	Query        string
+	Mutation     string
+	Subscription string
+	Directives   []*Directive
+	Position
+}
+
+// TypeDefinition defines a named type. Which members are set depends on Kind.
+type TypeDefinition struct {
+	Kind        string // KindScalar, KindObject, ...
+	Name        string
+	Description string
+	Interfaces  []string                // Implemented by objects and interfaces
+	Fields      []*FieldDefinition      // Of objects and interfaces
+	InputFields []*InputValueDefinition // Of input objects
+	EnumValues  []*EnumValueDefinition  // Of enums
+	Types       []string                // Members of unions
+	Directives  []*Directive
+	Position
+}
+
+// FieldDefinition is a field of an object or interface
+type FieldDefinition struct {
+	Name        string
+	Description string
+	Arguments   []*InputValueDefinition
+	Type        *Type
+	Directives  []*Directive
+	Position
+}
+
+// InputValueDefinition is an argument, or a field of an input object
+type InputValueDefinition struct {
+	Name         string
+	Description  string
+	Type         *Type
+	DefaultValue *Value // Nil without a default
+	Directives   []*Directive
+	Position
+}
+
+// EnumValueDefinition is a value of an enum
+type EnumValueDefinition struct {
+	Name        string
+	Description string
+	Directives  []*Directive
+	Position
+}
+
+// DirectiveDefinition declares a directive and where it can be used
+type DirectiveDefinition struct {
+	Name        string
+	Description string
+	Arguments   []*InputValueDefinition
+	Locations   []string
+	Repeatable  bool
+	Position
+}
+
+// ParseSchema parses a type system document, as printed by most GraphQL servers
+func ParseSchema(src string) (*SchemaDocument, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &SchemaDocument{}
+	types := map[string]*TypeDefinition{}
+	var extensions []*TypeDefinition
+	for p.tok.Kind != EOF {
+		description, err := p.description()
+		if err != nil {
+			return nil, err
+		}
+		extend := p.peek(Name, "extend")
+		if extend {
+			if err = p.advance(); err != nil {
+				return nil, err
+			}
+		}
+
+		switch start := p.tok.Position; {
+		case p.peek(Name, "schema"):
+			if doc.Schema == nil {
+				doc.Schema = &SchemaDefinition{Position: start}
+			} else if !extend {
+				return nil, &SyntaxError{Message: "schema defined more than once", Position: start}
+			}
+			if err = p.schemaDefinition(doc.Schema); err != nil {
+				return nil, err
+			}
+		case p.peek(Name, "directive") && !extend:
+			directive, err := p.directiveDefinition(description)
+			if err != nil {
+				return nil, err
+			}
+			doc.Directives = append(doc.Directives, directive)
+		case p.tok.Kind == Name && definitionKinds[p.tok.Value] != "":
+			definition, err := p.typeDefinition(description)
+			if err != nil {
+				return nil, err
+			}
+			if extend {
+				extensions = append(extensions, definition)
+				continue
+			}
+			if types[definition.Name] != nil {
+				return nil, &SyntaxError{Message: fmt.Sprintf("type %q defined more than once", definition.Name), Position: start}
+			}
+			types[definition.Name] = definition
+			doc.Types = append(doc.Types, definition)
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	for _, extension := range extensions {
+		definition := types[extension.Name]
+		if definition == nil || definition.Kind != extension.Kind {
+			return nil, &SyntaxError{Message: fmt.Sprintf("cannot extend undefined %s %q", extension.Kind, extension.Name), Position: extension.Position}
+		}
+		definition.Interfaces = append(definition.Interfaces, extension.Interfaces...)
+		definition.Fields = append(definition.Fields, extension.Fields...)
+		definition.InputFields = append(definition.InputFields, extension.InputFields...)
+		definition.EnumValues = append(definition.EnumValues, extension.EnumValues...)
+		definition.Types = append(definition.Types, extension.Types...)
+		definition.Directives = append(definition.Directives, extension.Directives...)
+	}
+	return doc, nil
+}
+
+// description consumes the string describing the next definition, when there is one
+func (p *parser) description() (string, error) {
+	if p.tok.Kind != String && p.tok.Kind != BlockString {
+		return "", nil
+	}
+	description := Unquote(p.tok.Value)
+	return description, p.advance()
+}
+
+func (p *parser) schemaDefinition(schema *SchemaDefinition) error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	directives, err := p.directives(true)
+	if err != nil {
+		return err
+	}
+	schema.Directives = append(schema.Directives, directives...)
+	if open, err := p.skip("{"); !open || err != nil {
+		return err
+	}
+	for {
+		start := p.tok.Position
+		operation, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err = p.expect(":"); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		switch operation {
+		case Query:
+			schema.Query = name
+		case Mutation:
+			schema.Mutation = name
+		case Subscription:
+			schema.Subscription = name
+		default:
+			return &SyntaxError{Message: fmt.Sprintf("unknown operation type %q", operation), Position: start}
+		}
+		if closed, err := p.skip("}"); closed || err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) directiveDefinition(description string) (*DirectiveDefinition, error) {
+	definition := &DirectiveDefinition{Description: description, Position: p.tok.Position}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("@"); err != nil {
+		return nil, err
+	}
+	var err error
+	if definition.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if definition.Arguments, err = p.inputValueDefinitions("(", ")"); err != nil {
+		return nil, err
+	}
+	if p.peek(Name, "repeatable") {
+		definition.Repeatable = true
+		if err = p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.keyword("on"); err != nil {
+		return nil, err
+	}
+	if _, err = p.skip("|"); err != nil {
+		return nil, err
+	}
+	for {
+		location, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		definition.Locations = append(definition.Locations, location)
+		if more, err := p.skip("|"); !more || err != nil {
+			return definition, err
+		}
+	}
+}
+
+func (p *parser) typeDefinition(description string) (*TypeDefinition, error) {
+	definition := &TypeDefinition{Kind: definitionKinds[p.tok.Value], Description: description, Position: p.tok.Position}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if definition.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+
+	switch definition.Kind {
+	case KindObject, KindInterface:
+		if definition.Interfaces, err = p.implementsInterfaces(); err != nil {
+			return nil, err
+		}
+	}
+	if definition.Directives, err = p.directives(true); err != nil {
+		return nil, err
+	}
+
+	switch definition.Kind {
+	case KindObject, KindInterface:
+		definition.Fields, err = p.fieldDefinitions()
+	case KindInputObject:
+		definition.InputFields, err = p.inputValueDefinitions("{", "}")
+	case KindEnum:
+		definition.EnumValues, err = p.enumValueDefinitions()
+	case KindUnion:
+		definition.Types, err = p.unionMembers()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+func (p *parser) implementsInterfaces() ([]string, error) {
+	if !p.peek(Name, "implements") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if _, err := p.skip("&"); err != nil {
+		return nil, err
+	}
+	var interfaces []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, name)
+		if more, err := p.skip("&"); !more || err != nil {
+			return interfaces, err
+		}
+	}
+}
+
+func (p *parser) fieldDefinitions() ([]*FieldDefinition, error) {
+	if open, err := p.skip("{"); !open || err != nil {
+		return nil, err
+	}
+	var fields []*FieldDefinition
+	for {
+		description, err := p.description()
+		if err != nil {
+			return nil, err
+		}
+		field := &FieldDefinition{Description: description, Position: p.tok.Position}
+		if field.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if field.Arguments, err = p.inputValueDefinitions("(", ")"); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if field.Type, err = p.typeReference(); err != nil {
+			return nil, err
+		}
+		if field.Directives, err = p.directives(true); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		if closed, err := p.skip("}"); closed || err != nil {
+			return fields, err
+		}
+	}
+}
+
+// inputValueDefinitions parses the arguments or input fields between the opening and closing
+// punctuators
+func (p *parser) inputValueDefinitions(opening, closing string) ([]*InputValueDefinition, error) {
+	if opened, err := p.skip(opening); !opened || err != nil {
+		return nil, err
+	}
+	var values []*InputValueDefinition
+	for {
+		description, err := p.description()
+		if err != nil {
+			return nil, err
+		}
+		value := &InputValueDefinition{Description: description, Position: p.tok.Position}
+		if value.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		if value.Type, err = p.typeReference(); err != nil {
+			return nil, err
+		}
+		if value.DefaultValue, err = p.defaultValue(); err != nil {
+			return nil, err
+		}
+		if value.Directives, err = p.directives(true); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if closed, err := p.skip(closing); closed || err != nil {
+			return values, err
+		}
+	}
+}
+
+func (p *parser) enumValueDefinitions() ([]*EnumValueDefinition, error) {
+	if open, err := p.skip("{"); !open || err != nil {
+		return nil, err
+	}
+	var values []*EnumValueDefinition
+	for {
+		description, err := p.description()
+		if err != nil {
+			return nil, err
+		}
+		value := &EnumValueDefinition{Description: description, Position: p.tok.Position}
+		if value.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if value.Directives, err = p.directives(true); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if closed, err := p.skip("}"); closed || err != nil {
+			return values, err
+		}
+	}
+}
+
+func (p *parser) unionMembers() ([]string, error) {
+	if equals, err := p.skip("="); !equals || err != nil {
+		return nil, err
+	}
+	if _, err := p.skip("|"); err != nil {
+		return nil, err
+	}
+	var members []string
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, name)
+		if more, err := p.skip("|"); !more || err != nil {
+			return members, err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	doc, err := ParseSchema(`
+schema { query: query_root, mutation: mutation_root }
+
+"""
+Custom directive
+"""
+directive @cached(ttl: Int! = 60, refresh: Boolean) repeatable on QUERY | FIELD
+
+scalar uuid @specifiedBy(url: "https://tools.ietf.org/html/rfc4122")
+
+"columns and relationships of \"users\""
+type users implements Node & Named {
+  id: uuid!
+  "the name"
+  name: String @deprecated(reason: "use full_name")
+  posts(limit: Int, order_by: [posts_order_by!] = []): [posts!]!
+}
+
+input users_bool_exp { _and: [users_bool_exp!], id: uuid_comparison_exp }
+enum user_role { ADMIN, USER @deprecated }
+union SearchResult = | users | posts
+interface Node { id: uuid! }
+
+extend type users { role: user_role! }
+extend enum user_role { GUEST }
+`)
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+	if doc.Schema == nil || doc.Schema.Query != "query_root" || doc.Schema.Mutation != "mutation_root" || doc.Schema.Subscription != "" {
+		t.Errorf("schema = %+v", doc.Schema)
+	}
+
+	if len(doc.Directives) != 1 {
+		t.Fatalf("directives = %+v", doc.Directives)
+	}
+	cached := doc.Directives[0]
+	if cached.Name != "cached" || cached.Description != "Custom directive" || !cached.Repeatable || len(cached.Locations) != 2 || len(cached.Arguments) != 2 {
+		t.Errorf("directive = %+v", cached)
+	}
+	if ttl := cached.Arguments[0]; ttl.Type.String() != "Int!" || ttl.DefaultValue == nil || ttl.DefaultValue.Raw != "60" {
+		t.Errorf("ttl argument = %+v", ttl)
+	}
+
+	if len(doc.Types) != 6 {
+		t.Fatalf("types = %d, want 6", len(doc.Types))
+	}
+	users := doc.Types[1]
+	if users.Kind != KindObject || users.Name != "users" || users.Description != `columns and relationships of "users"` {
+		t.Errorf("users = %s %q: %q", users.Kind, users.Name, users.Description)
+	}
+	if len(users.Interfaces) != 2 || users.Interfaces[1] != "Named" {
+		t.Errorf("interfaces = %v", users.Interfaces)
+	}
+	if len(users.Fields) != 4 || users.Fields[3].Name != "role" {
+		t.Fatalf("fields = %d, want 4 with the extension", len(users.Fields))
+	}
+	if name := users.Fields[1]; name.Description != "the name" || len(name.Directives) != 1 || name.Directives[0].Argument("reason") == nil {
+		t.Errorf("name = %+v", name)
+	}
+	if posts := users.Fields[2]; posts.Type.String() != "[posts!]!" || len(posts.Arguments) != 2 || posts.Arguments[1].DefaultValue.Kind != ListValue {
+		t.Errorf("posts = %+v", posts)
+	}
+
+	if input := doc.Types[2]; input.Kind != KindInputObject || len(input.InputFields) != 2 || input.InputFields[0].Type.NamedType() != "users_bool_exp" {
+		t.Errorf("input = %+v", input)
+	}
+	if enum := doc.Types[3]; enum.Kind != KindEnum || len(enum.EnumValues) != 3 || len(enum.EnumValues[1].Directives) != 1 {
+		t.Errorf("enum = %+v", enum)
+	}
+	if union := doc.Types[4]; union.Kind != KindUnion || len(union.Types) != 2 || union.Types[0] != "users" {
+		t.Errorf("union = %+v", union)
+	}
+	if node := doc.Types[5]; node.Kind != KindInterface || len(node.Fields) != 1 {
+		t.Errorf("interface = %+v", node)
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := []struct {
+		sdl  string
+		want string
+	}{
+		{"type User { }", `syntax error at 1:13: expected Name, found "}"`},
+		{"type User { id ID }", `syntax error at 1:16: expected ":", found "ID"`},
+		{"scalar uuid\nscalar uuid", `syntax error at 2:1: type "uuid" defined more than once`},
+		{"extend type User { id: ID }", `syntax error at 1:8: cannot extend undefined OBJECT "User"`},
+		{"schema { root: Query }", `syntax error at 1:10: unknown operation type "root"`},
+		{"directive @cached on", "syntax error at 1:21: expected Name, found <EOF>"},
+		{"query { users }", `syntax error at 1:1: unexpected "query"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseSchema(tt.sdl)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseSchema(%q) error = %v, want %s", tt.sdl, err, tt.want)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`"plain"`, "plain"},
+		{`"a \"b\" \\ \/ \n\t"`, "a \"b\" \\ / \n\t"},
+		{`"caf\u00e9"`, "café"},
+		{`"""block "quoted" \""" string"""`, `block "quoted" """ string`},
+		{"\"\"\"\n    first\n      indented\n\n    last\n  \"\"\"", "first\n  indented\n\nlast"},
+		{"ENUM", "ENUM"},
+	}
+	for _, tt := range tests {
+		if got := Unquote(tt.raw); got != tt.want {
+			t.Errorf("Unquote(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
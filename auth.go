@@ -0,0 +1,281 @@
+package gql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// invalidJWTCode is the extensions.code Hasura answers with for expired or invalid tokens
+const invalidJWTCode = "invalid-jwt"
+
+// AuthProvider supplies the credentials sent with every request. When the server rejects
+// them - with a 401 or an invalid-jwt GraphQL error - Refresh is called and the operation
+// is sent once more.
+type AuthProvider interface {
+	// Headers returns the headers carrying the credentials
+	Headers(ctx context.Context) (map[string]string, error)
+	// Refresh replaces credentials the server rejected. Providers that can't refresh return an error.
+	Refresh(ctx context.Context) error
+}
+
+// SetAuthProvider authenticates every request with provider. Headers passed to a call
+// explicitly take precedence over the provider's.
+func (b *BaseClient) SetAuthProvider(provider AuthProvider) {
+	b.auth_provider = provider
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL auth provider updated",
+		Pairs:   map[string]interface{}{"provider": fmt.Sprintf("%T", provider)},
+	})
+}
+
+// WithAuth authenticates every request with provider, as SetAuthProvider does
+func WithAuth(provider AuthProvider) Option {
+	return func(b *BaseClient) error {
+		if provider == nil {
+			return errors.New("invalid auth provider: nil")
+		}
+		b.auth_provider = provider
+		return nil
+	}
+}
+
+// authenticate adds the provider's credentials to the operation and, when the server rejects
+// them, refreshes the credentials and sends the operation once more. Operations with file uploads
+// aren't sent again - their readers were consumed by the first attempt - and fail with the
+// rejection, leaving the refreshed credentials for the next call.
+func (b *BaseClient) authenticate(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		provider := b.auth_provider
+		if provider == nil {
+			return next(ctx, req)
+		}
+
+		for attempt := 0; ; attempt++ {
+			headers, err := b.authHeaders(ctx, req.Headers)
+			if err != nil {
+				return nil, err
+			}
+			authenticated := *req
+			authenticated.Headers = headers
+
+			response, err := next(ctx, &authenticated)
+			if attempt > 0 || !authRejected(response, err) {
+				return response, err
+			}
+
+			b.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Credentials rejected, refreshing",
+				Pairs:   map[string]interface{}{"operation": req.OperationName},
+			})
+			if refreshErr := provider.Refresh(ctx); refreshErr != nil {
+				b.Logger.Error(&libpack_logger.LogMessage{
+					Message: "Can't refresh credentials",
+					Pairs:   map[string]interface{}{"error": refreshErr.Error()},
+				})
+				return response, err
+			}
+			if containsUpload(req.Variables) {
+				return response, err
+			}
+		}
+	}
+}
+
+// authHeaders merges the provider's credentials into a copy of headers. Headers set by the
+// caller win, compared case-insensitively.
+func (b *BaseClient) authHeaders(ctx context.Context, headers map[string]interface{}) (map[string]interface{}, error) {
+	if b.auth_provider == nil {
+		return headers, nil
+	}
+	credentials, err := b.auth_provider.Headers(ctx)
+	if err != nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Can't get credentials",
+			Pairs:   map[string]interface{}{"error": err.Error()},
+		})
+		return nil, fmt.Errorf("can't get credentials: %w", err)
+	}
+
+	return mergeHeaders(headers, credentials), nil
+}
+
+// authRejected reports whether the server refused the credentials
+func authRejected(response *Response, err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusUnauthorized {
+		return true
+	}
+	var errs Errors
+	if errors.As(err, &errs) && errs.HasCode(invalidJWTCode) {
+		return true
+	}
+	return response != nil && response.Result != nil && response.Result.Errors.HasCode(invalidJWTCode)
+}
+
+// headerAuth sends fixed headers and can't be refreshed
+type headerAuth map[string]string
+
+func (h headerAuth) Headers(context.Context) (map[string]string, error) {
+	return h, nil
+}
+
+func (h headerAuth) Refresh(context.Context) error {
+	return errors.New("static credentials can't be refreshed")
+}
+
+// NewBearerAuth sends a fixed bearer token
+func NewBearerAuth(token string) AuthProvider {
+	return headerAuth{"Authorization": "Bearer " + token}
+}
+
+// NewHasuraAdminSecretAuth sends the Hasura admin secret
+func NewHasuraAdminSecretAuth(secret string) AuthProvider {
+	return headerAuth{"x-hasura-admin-secret": secret}
+}
+
+// tokenAuth sends a bearer token fetched on demand and fetches a new one when it's about
+// to expire or the server rejects it
+type tokenAuth struct {
+	expiry time.Time
+	fetch  func(ctx context.Context) (string, time.Time, error)
+	token  string
+	leeway time.Duration
+	mu     sync.Mutex
+}
+
+func (t *tokenAuth) Headers(ctx context.Context) (map[string]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token == "" || (!t.expiry.IsZero() && time.Now().Add(t.leeway).After(t.expiry)) {
+		if err := t.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{"Authorization": "Bearer " + t.token}, nil
+}
+
+func (t *tokenAuth) Refresh(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.refresh(ctx)
+}
+
+func (t *tokenAuth) refresh(ctx context.Context) error {
+	token, expiry, err := t.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return errors.New("empty token")
+	}
+	t.token, t.expiry = token, expiry
+	return nil
+}
+
+// NewJWTAuth sends the JWT returned by fetch as a bearer token. The token is fetched again
+// leeway before the expiry in its exp claim, and whenever the server rejects it.
+func NewJWTAuth(fetch func(ctx context.Context) (string, error), leeway time.Duration) AuthProvider {
+	return &tokenAuth{
+		leeway: leeway,
+		fetch: func(ctx context.Context) (string, time.Time, error) {
+			token, err := fetch(ctx)
+			if err != nil {
+				return "", time.Time{}, err
+			}
+			return token, jwtExpiry(token), nil
+		},
+	}
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it. Tokens without one never expire.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return time.Time{}
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil || exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(exp), 0)
+}
+
+// ClientCredentials configures the OAuth2 client credentials grant
+type ClientCredentials struct {
+	HTTPClient   *http.Client // Defaults to http.DefaultClient
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Leeway       time.Duration // How long before its expiry a token is replaced
+}
+
+// NewClientCredentialsAuth sends access tokens obtained with the OAuth2 client credentials
+// grant, fetching a new one before the current one expires
+func NewClientCredentialsAuth(config ClientCredentials) AuthProvider {
+	return &tokenAuth{leeway: config.Leeway, fetch: config.token}
+}
+
+// token requests an access token from the token endpoint, authenticating with HTTP basic auth
+func (c ClientCredentials) token(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("can't create token request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("can't request token: %w", err)
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ExpiresIn        int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("can't decode token response (%s): %w", response.Status, err)
+	}
+	if response.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token request failed (%s): %s %s", response.Status, body.Error, body.ErrorDescription)
+	}
+
+	expiry := jwtExpiry(body.AccessToken)
+	if body.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return body.AccessToken, expiry, nil
+}
@@ -0,0 +1,258 @@
+package gql
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newAuthServer accepts requests whose Authorization header is "Bearer " + valid. Others are
+// answered with a 401, or with Hasura's invalid-jwt error when hasuraStyle is set.
+func newAuthServer(valid string, hasuraStyle bool) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Header.Get("Authorization") == "Bearer "+valid:
+			w.Write([]byte(`{"data":{"viewer":{"login":"alice"}}}`))
+		case hasuraStyle:
+			w.Write([]byte(`{"errors":[{"message":"Could not verify JWT: JWTExpired","extensions":{"code":"invalid-jwt","path":"$"}}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errors":[{"message":"unauthorized"}]}`))
+		}
+	}))
+	return server, &requests
+}
+
+// newTokenServer is a stand-in for an OAuth2 token endpoint handing out numbered tokens
+func newTokenServer(expiresIn int) (*httptest.Server, *int32) {
+	var issued int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "s3cret" || r.PostFormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d,"scope":%q}`, n, expiresIn, r.PostFormValue("scope"))
+	}))
+	return server, &issued
+}
+
+// testJWT builds an unsigned token expiring at exp
+func testJWT(name string, exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":%q,"exp":%d}`, name, exp.Unix())))
+	return "eyJhbGciOiJub25lIn0." + payload + ".sig"
+}
+
+// sequenceFetcher returns the tokens in order, repeating the last one
+func sequenceFetcher(tokens ...string) (func(context.Context) (string, error), *int32) {
+	var fetched int32
+	return func(context.Context) (string, error) {
+		n := int(atomic.AddInt32(&fetched, 1))
+		return tokens[min(n, len(tokens))-1], nil
+	}, &fetched
+}
+
+func (suite *Tests) TestBaseClient_AuthProvider() {
+	suite.T().Run("should send static credentials", func(t *testing.T) {
+		var received http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Clone()
+			w.Write([]byte(`{"data":{"viewer":{"login":"alice"}}}`))
+		}))
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewBearerAuth("abc"))
+		_, err := b.Query(`query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal("Bearer abc", received.Get("Authorization"))
+
+		_, err = b.Query(`query { viewer { login } }`, nil, map[string]interface{}{"authorization": "Bearer mine"})
+		assert.NoError(err)
+		assert.Equal("Bearer mine", received.Get("Authorization"))
+
+		b.SetAuthProvider(NewHasuraAdminSecretAuth("admin"))
+		_, err = b.Query(`query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal("admin", received.Get("X-Hasura-Admin-Secret"))
+	})
+
+	suite.T().Run("should refresh and retry once on 401", func(t *testing.T) {
+		server, requests := newAuthServer("fresh", false)
+		defer server.Close()
+
+		fetch, fetched := sequenceFetcher("stale", "fresh")
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewJWTAuth(fetch, time.Minute))
+		data, err := b.Query(`query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"viewer":{"login":"alice"}}`, data)
+		assert.Equal(int32(2), atomic.LoadInt32(fetched))
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should refresh and retry once on invalid-jwt errors", func(t *testing.T) {
+		server, requests := newAuthServer("fresh", true)
+		defer server.Close()
+
+		fetch, _ := sequenceFetcher("stale", "fresh")
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewJWTAuth(fetch, time.Minute))
+		result, err := b.QueryPartial(context.Background(), `query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.False(result.HasErrors())
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should give up after one refresh", func(t *testing.T) {
+		server, requests := newAuthServer("never", false)
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetries(3, 0))
+		assert.NoError(err)
+		fetch, fetched := sequenceFetcher("stale")
+		b.SetAuthProvider(NewJWTAuth(fetch, time.Minute))
+		_, err = b.Query(`query { viewer { login } }`, nil, nil)
+		var statusErr *httpStatusError
+		assert.True(errors.As(err, &statusErr))
+		assert.Equal(http.StatusUnauthorized, statusErr.code)
+		assert.Equal(int32(2), atomic.LoadInt32(fetched))
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should refresh but not resend uploads", func(t *testing.T) {
+		server, requests := newAuthServer("fresh", false)
+		defer server.Close()
+
+		fetch, fetched := sequenceFetcher("stale", "fresh")
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewJWTAuth(fetch, time.Minute))
+		_, err := b.MutateContext(context.Background(), `mutation ($image: Upload!) { uploadImage(image: $image) { id } }`, map[string]interface{}{
+			"image": Upload{Filename: "cat.png", Reader: strings.NewReader("PNG")},
+		}, nil)
+		var statusErr *httpStatusError
+		assert.True(errors.As(err, &statusErr))
+		assert.Equal(http.StatusUnauthorized, statusErr.code)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+
+		// The next call goes out with the refreshed credentials
+		assert.Equal(int32(2), atomic.LoadInt32(fetched))
+		_, err = b.Query(`query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should not retry static credentials", func(t *testing.T) {
+		server, requests := newAuthServer("valid", false)
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewBearerAuth("wrong"))
+		_, err := b.Query(`query { viewer { login } }`, nil, nil)
+		assert.Error(err)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should refresh JWTs before they expire", func(t *testing.T) {
+		expiring := testJWT("expiring", time.Now().Add(10*time.Second))
+		lasting := testJWT("lasting", time.Now().Add(time.Hour))
+		var used []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			used = append(used, r.Header.Get("Authorization"))
+			w.Write([]byte(`{"data":{"viewer":{"login":"alice"}}}`))
+		}))
+		defer server.Close()
+
+		fetch, fetched := sequenceFetcher(expiring, lasting)
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewJWTAuth(fetch, time.Minute))
+		for i := 0; i < 3; i++ {
+			_, err := b.Query(`query { viewer { login } }`, nil, nil)
+			assert.NoError(err)
+		}
+		// The first token is within the leeway of its expiry, so it's replaced before the next request
+		assert.Equal([]string{"Bearer " + expiring, "Bearer " + lasting, "Bearer " + lasting}, used)
+		assert.Equal(int32(2), atomic.LoadInt32(fetched))
+	})
+
+	suite.T().Run("should use OAuth2 client credentials", func(t *testing.T) {
+		tokens, issued := newTokenServer(3600)
+		defer tokens.Close()
+		server, _ := newAuthServer("token-1", false)
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewClientCredentialsAuth(ClientCredentials{
+			TokenURL:     tokens.URL,
+			ClientID:     "client",
+			ClientSecret: "s3cret",
+			Scopes:       []string{"read", "write"},
+		}))
+		for i := 0; i < 2; i++ {
+			_, err := b.Query(`query { viewer { login } }`, nil, nil)
+			assert.NoError(err)
+		}
+		assert.Equal(int32(1), atomic.LoadInt32(issued))
+	})
+
+	suite.T().Run("should fetch new client credentials tokens when they expire", func(t *testing.T) {
+		tokens, issued := newTokenServer(30)
+		defer tokens.Close()
+		var used []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			used = append(used, r.Header.Get("Authorization"))
+			w.Write([]byte(`{"data":{"viewer":{"login":"alice"}}}`))
+		}))
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewClientCredentialsAuth(ClientCredentials{
+			TokenURL:     tokens.URL,
+			ClientID:     "client",
+			ClientSecret: "s3cret",
+			Leeway:       time.Minute,
+		}))
+		for i := 0; i < 2; i++ {
+			_, err := b.Query(`query { viewer { login } }`, nil, nil)
+			assert.NoError(err)
+		}
+		// Tokens expiring within the leeway are replaced before every request
+		assert.Equal([]string{"Bearer token-1", "Bearer token-2"}, used)
+		assert.Equal(int32(2), atomic.LoadInt32(issued))
+	})
+
+	suite.T().Run("should fail when credentials can't be obtained", func(t *testing.T) {
+		tokens, _ := newTokenServer(3600)
+		defer tokens.Close()
+
+		b := CreateTestClient()
+		b.SetAuthProvider(NewClientCredentialsAuth(ClientCredentials{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "wrong"}))
+		_, err := b.Query(`query { viewer { login } }`, nil, nil)
+		assert.ErrorContains(err, "invalid_client")
+	})
+}
+
+func TestJWTExpiry(t *testing.T) {
+	exp := time.Unix(1900000000, 0)
+	if got := jwtExpiry(testJWT("alice", exp)); !got.Equal(exp) {
+		t.Errorf("jwtExpiry() = %v, want %v", got, exp)
+	}
+	for _, token := range []string{"", "opaque-token", "a.!!!.c", "a." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`)) + ".c"} {
+		if got := jwtExpiry(token); !got.IsZero() {
+			t.Errorf("jwtExpiry(%q) = %v, want zero", token, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// autoBatcher collects concurrent queries into batches, one pending batch per header set
+type autoBatcher struct {
+	pending map[string]*pendingBatch
+	mu      sync.Mutex
+}
+
+// pendingBatch is a batch still open for queries sharing the same headers
+type pendingBatch struct {
+	headers map[string]interface{}
+	timer   *time.Timer
+	calls   []*batchedCall
+	retries bool
+}
+
+type batchedCall struct {
+	ctx   context.Context
+	done  chan batchedOutcome
+	query []byte
+}
+
+type batchedOutcome struct {
+	err    error
+	result QueryResult
+}
+
+// SetAutoBatching collects queries made within window of each other, with identical headers,
+// into a single batched request of at most size operations (0 for no limit). A window of 0
+// turns auto-batching off.
+func (b *BaseClient) SetAutoBatching(window time.Duration, size int) {
+	b.auto_batch_window = window
+	b.auto_batch_size = size
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL auto-batching setting updated",
+		Pairs:   map[string]interface{}{"window": window.String(), "size": size},
+	})
+}
+
+// executeAutoBatched queues the query into the batch of its header set and waits for its
+// own result, which is then handled exactly like a response from executeResult
+func (qe *QueryExecutor) executeAutoBatched(ctx context.Context) (*QueryResult, error) {
+	call := &batchedCall{ctx: ctx, query: qe.Query, done: make(chan batchedOutcome, 1)}
+	qe.enqueue(call)
+
+	var outcome batchedOutcome
+	select {
+	case outcome = <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if outcome.err != nil {
+		// GraphQL errors of a single operation still carry a usable response in partial mode
+		if !qe.AllowPartial || len(outcome.result.Errors) == 0 {
+			return nil, outcome.err
+		}
+	}
+
+	result := outcome.result
+	if qe.CacheKey != "no-cache" && len(result.Errors) == 0 {
+		qe.cache.Set(qe.CacheKey, result.Data, qe.CacheTTL)
+	}
+	return &result, nil
+}
+
+// enqueue adds the call to the pending batch of its header set, which is sent once the
+// window closes or it's full
+func (qe *QueryExecutor) enqueue(call *batchedCall) {
+	b := qe.BaseClient
+	batcher := &b.auto_batcher
+	key := headersKey(qe.Headers)
+
+	batcher.mu.Lock()
+	batch, ok := batcher.pending[key]
+	if !ok {
+		if batcher.pending == nil {
+			batcher.pending = make(map[string]*pendingBatch)
+		}
+		batch = &pendingBatch{headers: qe.Headers}
+		batcher.pending[key] = batch
+		batch.timer = time.AfterFunc(b.auto_batch_window, func() {
+			batcher.mu.Lock()
+			if batcher.pending[key] != batch {
+				// Already sent because it filled up
+				batcher.mu.Unlock()
+				return
+			}
+			delete(batcher.pending, key)
+			batcher.mu.Unlock()
+			b.flushBatch(batch)
+		})
+	}
+	batch.calls = append(batch.calls, call)
+	batch.retries = batch.retries || qe.Retries
+
+	full := b.auto_batch_size > 0 && len(batch.calls) >= b.auto_batch_size
+	if full {
+		batch.timer.Stop()
+		delete(batcher.pending, key)
+	}
+	batcher.mu.Unlock()
+
+	if full {
+		go b.flushBatch(batch)
+	}
+}
+
+// flushBatch sends the collected calls and hands every caller its own result. The request
+// carries the context values of the first call and is only cancelled once all of the callers
+// have given up.
+func (b *BaseClient) flushBatch(batch *pendingBatch) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(batch.calls[0].ctx))
+	defer cancel()
+	remaining := int32(len(batch.calls))
+	for _, call := range batch.calls {
+		stop := context.AfterFunc(call.ctx, func() {
+			if atomic.AddInt32(&remaining, -1) == 0 {
+				cancel()
+			}
+		})
+		defer stop()
+	}
+
+	qe := &QueryExecutor{
+		BaseClient: b,
+		Headers:    batch.headers,
+		CacheKey:   "no-cache",
+		Retries:    batch.retries,
+	}
+
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Sending auto-batched queries",
+		Pairs:   map[string]interface{}{"operations": len(batch.calls)},
+	})
+
+	// A lone query goes out as a plain request
+	if len(batch.calls) == 1 {
+		qe.Query = batch.calls[0].query
+		qe.AllowPartial = true
+		result, err := qe.executeResult(ctx)
+		var outcome batchedOutcome
+		switch {
+		case err != nil:
+			outcome.err = err
+		case len(result.Errors) > 0:
+			outcome = batchedOutcome{result: *result, err: fmt.Errorf("error executing query: %w", result.Errors)}
+		case result.Data == nil:
+			// Same as an unbatched query: null data without errors is a failure, not an empty result
+			outcome.err = errors.New("error executing query: no data")
+		default:
+			outcome.result = *result
+		}
+		batch.calls[0].done <- outcome
+		return
+	}
+
+	operations := make([][]byte, len(batch.calls))
+	for i, call := range batch.calls {
+		operations[i] = call.query
+	}
+	qe.Query = batchBody(operations)
+	responses, err := qe.executeBatch(ctx, len(batch.calls))
+	for i, call := range batch.calls {
+		if err != nil {
+			call.done <- batchedOutcome{err: err}
+			continue
+		}
+		result, itemErr := batchItemResult(responses[i])
+		call.done <- batchedOutcome{result: result, err: itemErr}
+	}
+}
@@ -0,0 +1,210 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// autoBatchServer answers plain and batched requests with the login of the Authorization
+// header and the queried field, recording the size of every request
+type autoBatchServer struct {
+	*httptest.Server
+	sizes []int
+	mu    sync.Mutex
+}
+
+func newAutoBatchServer() *autoBatchServer {
+	server := &autoBatchServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		answer := func(operation Query) string {
+			return fmt.Sprintf(`{"data":{"user":%q,"query":%q}}`, r.Header.Get("Authorization"), operation.Query)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		var operations []Query
+		if json.Unmarshal(raw, &operations) != nil {
+			var operation Query
+			json.Unmarshal(raw, &operation)
+			server.record(0)
+			w.Write([]byte(answer(operation)))
+			return
+		}
+		server.record(len(operations))
+		responses := make([]json.RawMessage, len(operations))
+		for i, operation := range operations {
+			responses[i] = json.RawMessage(answer(operation))
+		}
+		body, _ := json.Marshal(responses)
+		w.Write(body)
+	}))
+	return server
+}
+
+func (s *autoBatchServer) record(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sizes = append(s.sizes, size)
+}
+
+func (s *autoBatchServer) requests() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.sizes...)
+}
+
+// queryConcurrently runs the queries at the same time and returns their results in order
+func queryConcurrently(b *BaseClient, queries []string, headers []map[string]interface{}) ([]any, []error) {
+	results := make([]any, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = b.QueryContext(context.Background(), queries[i], nil, headers[i])
+		}(i)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func (suite *Tests) TestBaseClient_AutoBatching() {
+	suite.T().Run("should send concurrent queries as one batch", func(t *testing.T) {
+		server := newAutoBatchServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server.Server)
+		b.SetOutput("mapstring")
+		b.SetAutoBatching(50*time.Millisecond, 20)
+
+		queries := []string{"query { a }", "query { b }", "query { c }"}
+		headers := make([]map[string]interface{}, len(queries))
+		results, errs := queryConcurrently(b, queries, headers)
+		for i, query := range queries {
+			assert.NoError(errs[i])
+			assert.Equal(minifyGraphQLQuery(query), results[i].(map[string]interface{})["query"])
+		}
+		assert.Equal([]int{3}, server.requests())
+	})
+
+	suite.T().Run("should send the batch with the context values of the first call", func(t *testing.T) {
+		server := newAutoBatchServer()
+		defer server.Close()
+
+		var ids []any
+		var mu sync.Mutex
+		b := newBatchTestClient(server.Server)
+		b.SetAutoBatching(50*time.Millisecond, 2)
+		b.UseTransport(recordRequestIDs(&ids, &mu))
+
+		var wg sync.WaitGroup
+		for _, id := range []string{"req-1", "req-2"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				ctx := context.WithValue(context.Background(), requestIDKey{}, id)
+				_, err := b.QueryContext(ctx, "query { "+strings.ReplaceAll(id, "-", "")+" }", nil, nil)
+				assert.NoError(err)
+			}()
+			time.Sleep(10 * time.Millisecond)
+		}
+		wg.Wait()
+		assert.Equal([]int{2}, server.requests())
+		assert.Equal([]any{"req-1"}, ids)
+	})
+
+	suite.T().Run("should only group queries with identical headers", func(t *testing.T) {
+		server := newAutoBatchServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server.Server)
+		b.SetOutput("mapstring")
+		b.SetAutoBatching(50*time.Millisecond, 20)
+
+		alice := map[string]interface{}{"Authorization": "alice"}
+		bob := map[string]interface{}{"authorization": "bob"}
+		results, errs := queryConcurrently(b,
+			[]string{"query { a }", "query { b }", "query { c }", "query { d }"},
+			[]map[string]interface{}{alice, bob, alice, bob},
+		)
+		for i, user := range []string{"alice", "bob", "alice", "bob"} {
+			assert.NoError(errs[i])
+			assert.Equal(user, results[i].(map[string]interface{})["user"])
+		}
+		assert.ElementsMatch([]int{2, 2}, server.requests())
+	})
+
+	suite.T().Run("should send a batch once it's full", func(t *testing.T) {
+		server := newAutoBatchServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server.Server)
+		b.SetAutoBatching(time.Minute, 2)
+
+		_, errs := queryConcurrently(b,
+			[]string{"query { a }", "query { b }", "query { c }", "query { d }"},
+			make([]map[string]interface{}, 4),
+		)
+		for _, err := range errs {
+			assert.NoError(err)
+		}
+		assert.Equal([]int{2, 2}, server.requests())
+	})
+
+	suite.T().Run("should send lone queries and mutations as plain requests", func(t *testing.T) {
+		server := newAutoBatchServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server.Server)
+		b.SetAutoBatching(5*time.Millisecond, 20)
+
+		_, err := b.Query("query { a }", nil, nil)
+		assert.NoError(err)
+		_, errs := queryConcurrently(b,
+			[]string{"mutation { a }", "mutation { b }"},
+			make([]map[string]interface{}, 2),
+		)
+		for _, err := range errs {
+			assert.NoError(err)
+		}
+		assert.Equal([]int{0, 0, 0}, server.requests())
+	})
+
+	suite.T().Run("should fail a lone query answered with null data", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":null}`))
+		}))
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		_, unbatched := b.Query("query { a }", nil, nil)
+		b.SetAutoBatching(5*time.Millisecond, 20)
+		_, batched := b.Query("query { a }", nil, nil)
+		assert.EqualError(batched, "error executing query: no data")
+		assert.Equal(unbatched, batched)
+	})
+
+	suite.T().Run("should return to a cancelled caller without waiting for the batch", func(t *testing.T) {
+		server := newAutoBatchServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server.Server)
+		b.SetAutoBatching(time.Minute, 20)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := b.QueryContext(ctx, "query { a }", nil, nil)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+	})
+}
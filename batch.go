@@ -0,0 +1,188 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// BatchItem is one operation of a batched request
+type BatchItem struct {
+	Variables     map[string]interface{}
+	Query         string
+	OperationName string // Operation to run when the document holds several
+}
+
+// BatchResult is the outcome of one batched operation. Err is set when that operation failed,
+// either with GraphQL errors (also kept in Errors, next to any partial data) or without data.
+type BatchResult struct {
+	Err error
+	QueryResult
+}
+
+// Batch sends several operations in a single HTTP request as a JSON array and returns their
+// results in the same order. Cached queries are answered without being sent; when all of them
+// are, no request is made at all. The returned error covers the request as a whole - failures
+// of single operations are reported in their BatchResult.
+func (b *BaseClient) Batch(ctx context.Context, items []BatchItem, headers map[string]interface{}) ([]BatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	headers, err := b.authHeaders(ctx, b.sessionHeaders(ctx, headers))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(items))
+	cacheKeys := make([]string, len(items))
+	pending := make([]int, 0, len(items))
+	operations := make([][]byte, 0, len(items))
+	enableRetries := b.retries_enable
+
+	for i, item := range items {
+		enableCache, itemRetries, cleanedVariables := processFlags(item.Variables, headers)
+		if containsUpload(cleanedVariables) {
+			results[i].Err = errors.New("file uploads can't be batched")
+			continue
+		}
+
+		req := newRequest(item.Query, item.OperationName, cleanedVariables, headers)
+		if err := b.validateOperation(req, cleanedVariables); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		doc, _ := req.parse()
+		compiledQuery := b.compileQuery(item.Query, cleanedVariables, req.OperationName, doc)
+		if compiledQuery == nil || compiledQuery.JsonQuery == nil {
+			results[i].Err = fmt.Errorf("can't compile query")
+			continue
+		}
+
+		compiledQuery.OperationType = req.OperationType
+		if (enableCache || b.cache_global) && compiledQuery.OperationType == OperationQuery {
+			cacheKeys[i] = b.cacheKey(ctx, compiledQuery, headers)
+		}
+		if cacheKeys[i] != "" {
+			if cachedValue := b.cacheLookup(cacheKeys[i]); cachedValue != nil {
+				results[i].Data = cachedValue
+				continue
+			}
+		}
+
+		enableRetries = enableRetries || itemRetries
+		pending = append(pending, i)
+		operations = append(operations, compiledQuery.JsonQuery)
+	}
+
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Batch compiled",
+		Pairs:   map[string]interface{}{"operations": len(items), "to_send": len(pending)},
+	})
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	q := &QueryExecutor{
+		BaseClient: b,
+		Query:      batchBody(operations),
+		Headers:    headers,
+		CacheKey:   "no-cache",
+		CacheTTL:   b.cacheTTL(),
+		Retries:    enableRetries,
+	}
+	responses, err := q.executeBatch(ctx, len(pending))
+	if err != nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Error executing batch",
+			Pairs:   map[string]interface{}{"error": err.Error(), "operations": len(pending)},
+		})
+		return nil, err
+	}
+
+	for n, i := range pending {
+		results[i].QueryResult, results[i].Err = batchItemResult(responses[n])
+		if results[i].Err == nil && cacheKeys[i] != "" {
+			b.cache.Set(cacheKeys[i], results[i].Data, q.CacheTTL)
+		}
+	}
+	return results, nil
+}
+
+// batchBody joins the JSON of several operations into the array body of a batched request
+func batchBody(operations [][]byte) []byte {
+	return append(append([]byte{'['}, bytes.Join(operations, []byte{','})...), ']')
+}
+
+// executeBatch sends the batch and returns one response per operation. A batch is retried
+// when any of its operations failed with a retryable error.
+func (qe *QueryExecutor) executeBatch(ctx context.Context, size int) ([]queryResults, error) {
+	var raw json.RawMessage
+	var responses []queryResults
+	err := qe.roundTrip(ctx, func() any {
+		raw, responses = nil, nil
+		return &raw
+	}, func() error {
+		// Servers reject a batch as a whole with a single response
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+			var single queryResults
+			if err := json.Unmarshal(trimmed, &single); err != nil || len(single.Errors) == 0 {
+				return retry.Unrecoverable(errors.New("error executing batch: server didn't return a list of results"))
+			}
+			if isRetryableError(single.Errors, qe.retries_patterns) {
+				return fmt.Errorf("%w: %w", errRetryableQuery, single.Errors)
+			}
+			return retry.Unrecoverable(fmt.Errorf("error executing batch: %w", single.Errors))
+		}
+
+		if err := json.Unmarshal(raw, &responses); err != nil {
+			return retry.Unrecoverable(fmt.Errorf("error unmarshalling batch response: %w", err))
+		}
+		if len(responses) != size {
+			return retry.Unrecoverable(fmt.Errorf("error executing batch: got %d results for %d operations", len(responses), size))
+		}
+		for _, response := range responses {
+			if isRetryableError(response.Errors, qe.retries_patterns) {
+				qe.Logger.Warning(&libpack_logger.LogMessage{
+					Message: "Retryable GraphQL error detected in batch",
+					Pairs:   map[string]interface{}{"errors": response.Errors},
+				})
+				return fmt.Errorf("%w: %w", errRetryableQuery, response.Errors)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// batchItemResult turns one entry of a batch response into its result, the same way
+// a partial query would, with the error executeResult returns for it
+func batchItemResult(response queryResults) (QueryResult, error) {
+	result := QueryResult{
+		Errors:     response.Errors,
+		Extensions: response.Extensions,
+	}
+	if !isNullJSON(response.Data) {
+		var dataBuf bytes.Buffer
+		if err := json.Compact(&dataBuf, response.Data); err != nil {
+			return result, fmt.Errorf("error compacting query result: %w", err)
+		}
+		result.Data = dataBuf.Bytes()
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		return result, fmt.Errorf("error executing query: %w", result.Errors)
+	case result.Data == nil:
+		return result, errors.New("error executing query: no data")
+	}
+	return result, nil
+}
@@ -0,0 +1,152 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+// newBatchServer answers every operation of a batch with respond, counting the requests
+func newBatchServer(respond func(query string) string) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		raw, _ := io.ReadAll(r.Body)
+		var operations []Query
+		if err := json.Unmarshal(raw, &operations); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"expected a batch"}]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("["))
+		for i, operation := range operations {
+			if i > 0 {
+				w.Write([]byte(","))
+			}
+			w.Write([]byte(respond(operation.Query)))
+		}
+		w.Write([]byte("]"))
+	}))
+	return server, &requests
+}
+
+func newBatchTestClient(server *httptest.Server) *BaseClient {
+	b := NewConnection()
+	b.SetEndpoint(server.URL)
+	b.SetHTTPClient(server.Client())
+	return b
+}
+
+func (suite *Tests) TestBaseClient_Batch() {
+	suite.T().Run("should map results back to their operations", func(t *testing.T) {
+		server, requests := newBatchServer(func(query string) string {
+			switch query {
+			case "query{viewer{login}}":
+				return `{"data":{"viewer":{"login":"alice"}}}`
+			case "query{dragons{name}}":
+				return `{"data":{"dragons":null},"errors":[{"message":"dragons are asleep","extensions":{"code":"unavailable"}}]}`
+			}
+			return `{"data":null}`
+		})
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		results, err := b.Batch(context.Background(), []BatchItem{
+			{Query: "query { viewer { login } }"},
+			{Query: "query { dragons { name } }"},
+			{Query: "query { nothing }"},
+		}, nil)
+		assert.NoError(err)
+		assert.Len(results, 3)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+
+		assert.NoError(results[0].Err)
+		assert.JSONEq(`{"viewer":{"login":"alice"}}`, string(results[0].Data))
+
+		var gqlErrs Errors
+		assert.True(errors.As(results[1].Err, &gqlErrs))
+		assert.True(gqlErrs.HasCode("unavailable"))
+		assert.JSONEq(`{"dragons":null}`, string(results[1].Data))
+
+		assert.EqualError(results[2].Err, "error executing query: no data")
+	})
+
+	suite.T().Run("should serve cached queries without sending them", func(t *testing.T) {
+		server, requests := newBatchServer(func(query string) string {
+			return `{"data":{"viewer":{"login":"cached"}}}`
+		})
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		items := []BatchItem{{Query: "query { viewer { login } }", Variables: map[string]interface{}{"gqlcache": true}}}
+		_, err := b.Batch(context.Background(), items, nil)
+		assert.NoError(err)
+
+		results, err := b.Batch(context.Background(), items, nil)
+		assert.NoError(err)
+		assert.JSONEq(`{"viewer":{"login":"cached"}}`, string(results[0].Data))
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+
+		// Batched and single requests share the cache
+		data, err := b.Query("query { viewer { login } }", map[string]interface{}{"gqlcache": true}, nil)
+		assert.NoError(err)
+		assert.Equal(`{"viewer":{"login":"cached"}}`, data)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should fail when the server rejects the batch", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"errors":[{"message":"batching is disabled","extensions":{"code":"batch-disabled"}}]}`))
+		}))
+		defer server.Close()
+
+		_, err := newBatchTestClient(server).Batch(context.Background(), []BatchItem{{Query: "query { viewer { login } }"}}, nil)
+		var gqlErrs Errors
+		assert.True(errors.As(err, &gqlErrs))
+		assert.True(gqlErrs.HasCode("batch-disabled"))
+	})
+
+	suite.T().Run("should fail when results don't match the operations", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"data":{"viewer":{"login":"alice"}}}]`))
+		}))
+		defer server.Close()
+
+		_, err := newBatchTestClient(server).Batch(context.Background(), []BatchItem{
+			{Query: "query { viewer { login } }"},
+			{Query: "query { dragons { name } }"},
+		}, nil)
+		assert.ErrorContains(err, "got 1 results for 2 operations")
+	})
+
+	suite.T().Run("should report operations that can't be sent", func(t *testing.T) {
+		server, requests := newBatchServer(func(query string) string {
+			return `{"data":{"viewer":{"login":"alice"}}}`
+		})
+		defer server.Close()
+
+		results, err := newBatchTestClient(server).Batch(context.Background(), []BatchItem{
+			{Query: ""},
+			{Query: "query { viewer { login } }"},
+		}, nil)
+		assert.NoError(err)
+		assert.Error(results[0].Err)
+		assert.NoError(results[1].Err)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should fail on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := CreateTestClient().Batch(ctx, []BatchItem{{Query: "query { viewer { login } }"}}, nil)
+		assert.ErrorIs(err, context.Canceled)
+	})
+}
@@ -0,0 +1,113 @@
+package gql
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// defaultCacheKeyHeaders decide who a response was fetched for - the caller's credentials
+// and the Hasura session
+var defaultCacheKeyHeaders = []string{"authorization", "x-hasura-*"}
+
+// hasuraCacheKeyHeaders are part of every cache key, whatever headers or function are set
+var hasuraCacheKeyHeaders = []string{hasuraHeaderPrefix + "*"}
+
+// CacheKeyFunc returns the key a query is cached under. Queries with the same key share cached
+// responses; an empty key leaves the query uncached. headers include the auth provider's. The
+// Hasura session is added to the key by the client in any case.
+type CacheKeyFunc func(ctx context.Context, query *Query, headers map[string]interface{}) string
+
+// SetCacheKeyHeaders sets the request headers that are part of the cache key, so responses
+// fetched with different credentials are kept apart. Names are case-insensitive, a trailing *
+// matches any name with that prefix. The default is Authorization and x-hasura-*; call it
+// without arguments to share cached responses regardless of headers. The x-hasura-* session
+// headers are always part of the key, so a role never receives rows cached for another one.
+func (b *BaseClient) SetCacheKeyHeaders(headers ...string) {
+	b.cache_key_headers = normaliseCacheKeyHeaders(headers)
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL cache key headers updated",
+		Pairs:   map[string]interface{}{"cache_key_headers": b.cache_key_headers},
+	})
+}
+
+// SetCacheKeyFunc replaces the way cache keys are built, cache key headers included. Pass nil
+// to restore the default.
+func (b *BaseClient) SetCacheKeyFunc(fn CacheKeyFunc) {
+	b.cache_key_func = fn
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL cache key function updated",
+		Pairs:   map[string]interface{}{"custom": fn != nil},
+	})
+}
+
+// WithCacheKeyHeaders sets the request headers that are part of the cache key, as SetCacheKeyHeaders does
+func WithCacheKeyHeaders(headers ...string) Option {
+	return func(b *BaseClient) error {
+		b.cache_key_headers = normaliseCacheKeyHeaders(headers)
+		return nil
+	}
+}
+
+// WithCacheKeyFunc replaces the way cache keys are built, as SetCacheKeyFunc does
+func WithCacheKeyFunc(fn CacheKeyFunc) Option {
+	return func(b *BaseClient) error {
+		b.cache_key_func = fn
+		return nil
+	}
+}
+
+// normaliseCacheKeyHeaders lowercases the names, never returning nil so an empty list isn't
+// mistaken for the default
+func normaliseCacheKeyHeaders(headers []string) []string {
+	normalised := make([]string, 0, len(headers))
+	for _, header := range headers {
+		if header = strings.ToLower(strings.TrimSpace(header)); header != "" {
+			normalised = append(normalised, header)
+		}
+	}
+	return normalised
+}
+
+// cacheKey identifies a cached response: the operation and the headers saying who it was
+// fetched for, so callers with different credentials or roles never see each other's rows.
+// The Hasura session decides which rows come back whatever the key is otherwise built from,
+// so its headers are always part of it.
+func (b *BaseClient) cacheKey(ctx context.Context, query *Query, headers map[string]interface{}) string {
+	if b.cache_key_func != nil {
+		key := b.cache_key_func(ctx, query, headers)
+		if session := headersKey(scopedHeaders(headers, hasuraCacheKeyHeaders)); key != "" && session != "" {
+			return key + ":" + session
+		}
+		return key
+	}
+	names := b.cache_key_headers
+	if names == nil {
+		names = defaultCacheKeyHeaders
+	}
+	if scope := headersKey(scopedHeaders(headers, slices.Concat(hasuraCacheKeyHeaders, names))); scope != "" {
+		return calculateHash(query) + ":" + scope
+	}
+	return calculateHash(query)
+}
+
+// scopedHeaders picks the headers matching names, where a trailing * matches a prefix
+func scopedHeaders(headers map[string]interface{}, names []string) map[string]interface{} {
+	var scoped map[string]interface{}
+	for header, value := range headers {
+		lower := strings.ToLower(header)
+		for _, name := range names {
+			prefix, wildcard := strings.CutSuffix(name, "*")
+			if lower == name || (wildcard && strings.HasPrefix(lower, prefix)) {
+				if scoped == nil {
+					scoped = make(map[string]interface{})
+				}
+				scoped[header] = value
+				break
+			}
+		}
+	}
+	return scoped
+}
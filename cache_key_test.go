@@ -0,0 +1,153 @@
+package gql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTenantServer answers with the bearer token and tenant the request was made with
+func newTenantServer() (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"token":"` + r.Header.Get("Authorization") + `","tenant":"` + r.Header.Get("X-Tenant") + `"}}`))
+	}))
+	return server, &requests
+}
+
+func (suite *Tests) TestBaseClient_CacheKey() {
+	query := `query { me { id } }`
+
+	suite.T().Run("should keep cached responses apart per Authorization header", func(t *testing.T) {
+		server, requests := newTenantServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute))
+		assert.NoError(err)
+		for _, token := range []string{"Bearer a", "Bearer b", "Bearer a", "Bearer b"} {
+			data, err := b.Query(query, nil, map[string]interface{}{"Authorization": token})
+			assert.NoError(err)
+			assert.Equal(`{"token":"`+token+`","tenant":""}`, data)
+		}
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should scope by the auth provider's credentials", func(t *testing.T) {
+		server, requests := newTenantServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute))
+		assert.NoError(err)
+		b.SetAuthProvider(NewBearerAuth("a"))
+		_, err = b.Query(query, nil, nil)
+		assert.NoError(err)
+		b.SetAuthProvider(NewBearerAuth("b"))
+		data, err := b.Query(query, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"token":"Bearer b","tenant":""}`, data)
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should use the declared headers", func(t *testing.T) {
+		server, requests := newTenantServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute), WithCacheKeyHeaders("X-Tenant"))
+		assert.NoError(err)
+		for _, headers := range []map[string]interface{}{
+			{"x-tenant": "one", "Authorization": "Bearer a"},
+			{"X-Tenant": "one", "Authorization": "Bearer b"}, // Authorization is no longer part of the key
+			{"X-Tenant": "two", "Authorization": "Bearer a"},
+		} {
+			_, err := b.Query(query, nil, headers)
+			assert.NoError(err)
+		}
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+
+		// Without any headers, everyone shares the cache
+		b.SetCacheKeyHeaders()
+		for _, tenant := range []string{"three", "four"} {
+			data, err := b.Query(query, nil, map[string]interface{}{"X-Tenant": tenant})
+			assert.NoError(err)
+			assert.Equal(`{"token":"","tenant":"three"}`, data)
+		}
+		assert.Equal(int32(3), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should use a custom cache key function", func(t *testing.T) {
+		server, requests := newTenantServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute))
+		assert.NoError(err)
+		b.SetCacheKeyFunc(func(ctx context.Context, query *Query, headers map[string]interface{}) string {
+			tenant, _ := headers["X-Tenant"].(string)
+			if tenant == "" {
+				return "" // Not cached
+			}
+			return tenant + ":" + calculateHash(query)
+		})
+		for _, tenant := range []string{"one", "one", "", ""} {
+			_, err := b.Query(query, nil, map[string]interface{}{"X-Tenant": tenant})
+			assert.NoError(err)
+		}
+		assert.Equal(int32(3), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should keep Hasura sessions apart whatever the cache key is built from", func(t *testing.T) {
+		server, requests := newHasuraServer()
+		defer server.Close()
+
+		for name, configure := range map[string]func(b *BaseClient){
+			"declared headers": func(b *BaseClient) { b.SetCacheKeyHeaders("authorization") },
+			"no headers":       func(b *BaseClient) { b.SetCacheKeyHeaders() },
+			"key function": func(b *BaseClient) {
+				b.SetCacheKeyFunc(func(ctx context.Context, query *Query, headers map[string]interface{}) string {
+					return calculateHash(query)
+				})
+			},
+		} {
+			atomic.StoreInt32(requests, 0)
+			b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute))
+			assert.NoError(err)
+			configure(b)
+
+			admin := b.WithHasuraSession(HasuraSession{Role: "admin"})
+			user := b.WithHasuraSession(HasuraSession{Role: "user"})
+			for _, client := range []*BaseClient{admin, user, admin, user} {
+				var result struct{ Role string }
+				assert.NoError(client.QueryInto(context.Background(), query, nil, nil, &result))
+				assert.Equal(client.hasura_session.Role, result.Role, name)
+			}
+			assert.Equal(int32(2), atomic.LoadInt32(requests), name)
+		}
+	})
+
+	suite.T().Run("should read the cache key headers from the environment", func(t *testing.T) {
+		t.Setenv("KEYED_GRAPHQL_CACHE_KEY_HEADERS", "X-Tenant, X-Region-*")
+		b, err := New(WithEnv("KEYED"))
+		assert.NoError(err)
+		assert.Equal([]string{"x-tenant", "x-region-*"}, b.cache_key_headers)
+	})
+}
+
+func TestScopedHeaders(t *testing.T) {
+	headers := map[string]interface{}{
+		"Authorization":    "Bearer a",
+		"X-Hasura-Role":    "user",
+		"x-hasura-user-id": "42",
+		"X-Request-Id":     "abc",
+	}
+	scoped := scopedHeaders(headers, defaultCacheKeyHeaders)
+	if len(scoped) != 3 || scoped["X-Request-Id"] != nil {
+		t.Errorf("scopedHeaders() = %v, want Authorization and the x-hasura-* headers", scoped)
+	}
+	if scoped := scopedHeaders(headers, []string{}); scoped != nil {
+		t.Errorf("scopedHeaders() = %v, want nil without names", scoped)
+	}
+}
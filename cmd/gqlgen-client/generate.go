@@ -0,0 +1,660 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	gql "github.com/lukaszraczylo/go-simple-graphql"
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+)
+
+// defaultScalars maps the built-in scalars and the custom ones of Hasura to Go types. Types
+// outside the universe block are written as package.Type, or import/path.Type.
+var defaultScalars = map[string]string{
+	"Int":         "int",
+	"Float":       "float64",
+	"String":      "string",
+	"Boolean":     "bool",
+	"ID":          "string",
+	"smallint":    "int",
+	"bigint":      "int64",
+	"float8":      "float64",
+	"numeric":     "json.Number",
+	"uuid":        "string",
+	"date":        "string",
+	"time":        "string",
+	"timetz":      "string",
+	"timestamp":   "string",
+	"timestamptz": "time.Time",
+	"json":        "json.RawMessage",
+	"jsonb":       "json.RawMessage",
+}
+
+// standardPackages resolves the package names used by the default scalar types
+var standardPackages = map[string]string{
+	"json": "encoding/json",
+	"time": "time",
+	"big":  "math/big",
+}
+
+// initialisms are written in upper case in Go identifiers
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true, "json": true,
+	"sql": true, "uri": true, "url": true, "uuid": true,
+}
+
+// config tunes the generated code
+type config struct {
+	Package string
+	Scalars map[string]string // Go types of scalars, on top of defaultScalars
+}
+
+// source is a file of GraphQL operations and fragments
+type source struct {
+	Name    string
+	Content string
+}
+
+// fragment is a fragment definition and the source it was defined in
+type fragment struct {
+	*ast.FragmentDefinition
+	src *source
+}
+
+// operation is an operation definition and the source it was defined in
+type operation struct {
+	*ast.OperationDefinition
+	src *source
+}
+
+// generator writes the Go code of a set of operations
+type generator struct {
+	schema    *gql.Schema
+	scalars   map[string]string
+	fragments map[string]*fragment
+	imports   map[string]bool
+	names     map[string]bool   // Package level identifiers already declared
+	types     map[string]string // Go names of the generated enums and input objects
+	decls     []string          // Type declarations, in the order they were generated
+	warned    map[string]bool
+	warnings  []string
+}
+
+// generate returns the formatted Go code of a client for the operations of sources. Warnings
+// point at scalars the configuration doesn't map, which are left as raw JSON.
+func generate(schema *gql.Schema, sources []*source, cfg config) ([]byte, []string, error) {
+	g := &generator{
+		schema:    schema,
+		scalars:   make(map[string]string, len(defaultScalars)+len(cfg.Scalars)),
+		fragments: make(map[string]*fragment),
+		imports:   map[string]bool{"context": true, "github.com/lukaszraczylo/go-simple-graphql": true},
+		names:     map[string]bool{"Client": true, "NewClient": true},
+		types:     make(map[string]string),
+		warned:    make(map[string]bool),
+	}
+	for name, goType := range defaultScalars {
+		g.scalars[name] = goType
+	}
+	for name, goType := range cfg.Scalars {
+		g.scalars[name] = goType
+	}
+
+	operations, err := g.collect(sources)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var methods bytes.Buffer
+	for _, op := range operations {
+		if err := g.operation(&methods, op); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s %s: %w", op.src.Name, op.Operation, op.Name, err)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by gqlgen-client. DO NOT EDIT.\n\npackage %s\n\nimport (\n", cfg.Package)
+	var standard, external []string
+	for importPath := range g.imports {
+		if strings.Contains(strings.SplitN(importPath, "/", 2)[0], ".") {
+			external = append(external, importPath)
+		} else {
+			standard = append(standard, importPath)
+		}
+	}
+	sort.Strings(standard)
+	sort.Strings(external)
+	for _, importPath := range standard {
+		fmt.Fprintf(&out, "\t%q\n", importPath)
+	}
+	out.WriteString("\n")
+	for _, importPath := range external {
+		if importPath == "github.com/lukaszraczylo/go-simple-graphql" {
+			fmt.Fprintf(&out, "\tgql %q\n", importPath)
+			continue
+		}
+		fmt.Fprintf(&out, "\t%q\n", importPath)
+	}
+	out.WriteString(`)
+
+// Client executes the operations of this package
+type Client struct {
+	*gql.BaseClient
+}
+
+// NewClient wraps a configured client, which executes the operations with its endpoint,
+// credentials, cache and retries
+func NewClient(client *gql.BaseClient) *Client {
+	return &Client{BaseClient: client}
+}
+`)
+	out.Write(methods.Bytes())
+	for _, decl := range g.decls {
+		out.WriteString("\n" + decl)
+	}
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't format the generated code: %w", err)
+	}
+	return formatted, g.warnings, nil
+}
+
+// collect parses the sources, indexing the fragments of all of them by name
+func (g *generator) collect(sources []*source) ([]*operation, error) {
+	var operations []*operation
+	defined := make(map[string]string)
+	for _, src := range sources {
+		doc, err := ast.Parse(src.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src.Name, err)
+		}
+		for _, definition := range doc.Fragments {
+			if previous, ok := g.fragments[definition.Name]; ok {
+				return nil, fmt.Errorf("%s: fragment %s is already defined in %s", src.Name, definition.Name, previous.src.Name)
+			}
+			g.fragments[definition.Name] = &fragment{FragmentDefinition: definition, src: src}
+		}
+		for _, definition := range doc.Operations {
+			if definition.Name == "" {
+				return nil, fmt.Errorf("%s:%s: operations need a name to generate a method", src.Name, definition.Position)
+			}
+			if previous, ok := defined[definition.Name]; ok {
+				return nil, fmt.Errorf("%s: operation %s is already defined in %s", src.Name, definition.Name, previous)
+			}
+			defined[definition.Name] = src.Name
+			operations = append(operations, &operation{OperationDefinition: definition, src: src})
+		}
+	}
+	if len(operations) == 0 {
+		return nil, errors.New("no operations found")
+	}
+	return operations, nil
+}
+
+// document returns the operation as written followed by the fragments it uses
+func (g *generator) document(op *operation) (string, error) {
+	parts := []string{op.src.Content[op.Offset:op.End]}
+	seen := make(map[string]bool)
+	var spreads func(set ast.SelectionSet) error
+	spreads = func(set ast.SelectionSet) error {
+		for _, s := range set {
+			switch s := s.(type) {
+			case *ast.Field:
+				if err := spreads(s.SelectionSet); err != nil {
+					return err
+				}
+			case *ast.InlineFragment:
+				if err := spreads(s.SelectionSet); err != nil {
+					return err
+				}
+			case *ast.FragmentSpread:
+				if seen[s.Name] {
+					continue
+				}
+				seen[s.Name] = true
+				f, ok := g.fragments[s.Name]
+				if !ok {
+					return fmt.Errorf("unknown fragment %s", s.Name)
+				}
+				parts = append(parts, f.src.Content[f.Offset:f.End])
+				if err := spreads(f.SelectionSet); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := spreads(op.SelectionSet); err != nil {
+		return "", err
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+func (g *generator) operation(w *bytes.Buffer, op *operation) error {
+	doc, err := g.document(op)
+	if err != nil {
+		return err
+	}
+	// Values are only known at run time, so every variable counts as provided
+	provided := make(map[string]interface{}, len(op.VariableDefinitions))
+	for _, definition := range op.VariableDefinitions {
+		provided[definition.Variable] = true
+	}
+	if problems := g.schema.Validate(doc, provided); len(problems) > 0 {
+		var failures gql.ValidationErrors
+		for _, problem := range problems {
+			if !problem.Warning {
+				failures = append(failures, problem)
+			}
+		}
+		if len(failures) > 0 {
+			return failures
+		}
+	}
+
+	name := goName(op.Name)
+	document := g.declare(name + "Document")
+	fmt.Fprintf(w, "\n// %s is the %s %s\nconst %s = %s\n", document, op.Name, op.Operation, document, goString(doc))
+
+	variables, err := g.variables(name, op)
+	if err != nil {
+		return err
+	}
+	response := g.declare(name + "Response")
+	root := g.schema.RootType(op.Operation)
+	if err := g.object(response, fmt.Sprintf("is the data returned by the %s %s", op.Name, op.Operation), root, []ast.SelectionSet{op.SelectionSet}); err != nil {
+		return err
+	}
+
+	params, args := "ctx context.Context", "nil"
+	if variables != "" {
+		params, args = params+", vars "+variables, "vars.variables()"
+	}
+	if op.Operation == ast.Subscription {
+		fmt.Fprintf(w, `
+// %[1]s subscribes to the %[2]s subscription. Each event's data decodes into a %[3]s.
+func (c *Client) %[1]s(%[4]s) (<-chan gql.SubscriptionEvent, error) {
+	return c.Subscribe(ctx, %[5]s, %[6]s, nil)
+}
+`, name, op.Name, response, params, document, args)
+		return nil
+	}
+	fmt.Fprintf(w, `
+// %[1]s executes the %[2]s %[3]s
+func (c *Client) %[1]s(%[4]s) (*%[5]s, error) {
+	var resp %[5]s
+	if err := c.QueryInto(ctx, %[6]s, %[7]s, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+`, name, op.Name, op.Operation, params, response, document, args)
+	return nil
+}
+
+// variables declares the variables struct of the operation and its map builder. It returns an
+// empty name when the operation has no variables.
+func (g *generator) variables(name string, op *operation) (string, error) {
+	if len(op.VariableDefinitions) == 0 {
+		return "", nil
+	}
+	structName := g.declare(name + "Variables")
+	var fields, builder, optional strings.Builder
+	used := make(map[string]bool)
+	for _, definition := range op.VariableDefinitions {
+		ref := typeRef(definition.Type)
+		if definition.DefaultValue != nil && ref.Kind == gql.KindNonNull {
+			ref = ref.OfType // The server falls back to the default
+		}
+		goType, err := g.goType(ref, g.inputType)
+		if err != nil {
+			return "", err
+		}
+		field := uniqueName(goName(definition.Variable), used)
+		if ref.Kind == gql.KindNonNull {
+			fmt.Fprintf(&fields, "\t%s %s `json:%q`\n", field, goType, definition.Variable)
+			fmt.Fprintf(&builder, "\t\t%q: v.%s,\n", definition.Variable, field)
+			continue
+		}
+		fmt.Fprintf(&fields, "\t%s %s `json:%q`\n", field, goType, definition.Variable+",omitempty")
+		fmt.Fprintf(&optional, "\tif v.%s != nil {\n\t\tvars[%q] = v.%s\n\t}\n", field, definition.Variable, field)
+	}
+	g.decls = append(g.decls, fmt.Sprintf(`// %[1]s are the variables of the %[2]s %[3]s. Nil ones are left out.
+type %[1]s struct {
+%[4]s}
+
+func (v %[1]s) variables() map[string]interface{} {
+	vars := map[string]interface{}{
+%[5]s	}
+%[6]s	return vars
+}
+`, structName, op.Name, op.Operation, fields.String(), builder.String(), optional.String()))
+	return structName, nil
+}
+
+// selectedField is a field of a selection set, merged across the fragments selecting it
+type selectedField struct {
+	definition *gql.Field // Nil for __typename
+	key        string
+	selections []ast.SelectionSet
+	optional   bool // Not selected on every object the parent can be
+}
+
+// object declares the struct of a selection set on t, nesting structs for its composite fields
+func (g *generator) object(name, doc string, t *gql.SchemaType, sets []ast.SelectionSet) error {
+	var fields []*selectedField
+	byKey := make(map[string]*selectedField)
+	for _, set := range sets {
+		if err := g.selectFields(t, set, false, &fields, byKey); err != nil {
+			return err
+		}
+	}
+
+	slot := len(g.decls)
+	g.decls = append(g.decls, "")
+	var body strings.Builder
+	used := make(map[string]bool)
+	for _, field := range fields {
+		goField := uniqueName(goName(field.key), used)
+		goType := "string"
+		if field.definition != nil {
+			var err error
+			goType, err = g.goType(field.definition.Type, func(typeName string) (string, error) {
+				named := g.schema.Type(typeName)
+				if named == nil || (named.Kind != gql.KindObject && named.Kind != gql.KindInterface && named.Kind != gql.KindUnion) {
+					return g.inputType(typeName)
+				}
+				nested := g.declare(name + goField)
+				return nested, g.object(nested, "is the "+field.key+" field of "+name, named, field.selections)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if field.optional && !nilable(goType) {
+			goType = "*" + goType
+		}
+		tag := field.key
+		if field.optional {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&body, "\t%s %s `json:%q`\n", goField, goType, tag)
+	}
+	g.decls[slot] = fmt.Sprintf("// %s %s\ntype %s struct {\n%s}\n", name, doc, name, body.String())
+	return nil
+}
+
+// selectFields adds the fields of set, selected on t, flattening fragments into them
+func (g *generator) selectFields(t *gql.SchemaType, set ast.SelectionSet, optional bool, fields *[]*selectedField, byKey map[string]*selectedField) error {
+	for _, s := range set {
+		switch s := s.(type) {
+		case *ast.Field:
+			conditional := optional || hasCondition(s.Directives)
+			if field, ok := byKey[s.ResponseKey()]; ok {
+				field.optional = field.optional && conditional
+				field.selections = append(field.selections, s.SelectionSet)
+				continue
+			}
+			field := &selectedField{key: s.ResponseKey(), selections: []ast.SelectionSet{s.SelectionSet}, optional: conditional}
+			if s.Name != "__typename" {
+				if field.definition = t.Field(s.Name); field.definition == nil {
+					return fmt.Errorf("unknown field %s on %s", s.Name, t.Name)
+				}
+			}
+			byKey[field.key] = field
+			*fields = append(*fields, field)
+		case *ast.FragmentSpread:
+			f := g.fragments[s.Name]
+			condition := g.schema.Type(f.TypeCondition)
+			if condition == nil {
+				return fmt.Errorf("unknown type %s", f.TypeCondition)
+			}
+			partial := optional || hasCondition(s.Directives) || !g.always(t, condition)
+			if err := g.selectFields(condition, f.SelectionSet, partial, fields, byKey); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			condition := t
+			if s.TypeCondition != "" {
+				if condition = g.schema.Type(s.TypeCondition); condition == nil {
+					return fmt.Errorf("unknown type %s", s.TypeCondition)
+				}
+			}
+			partial := optional || hasCondition(s.Directives) || !g.always(t, condition)
+			if err := g.selectFields(condition, s.SelectionSet, partial, fields, byKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// always reports whether a fragment on condition applies to every object of type t
+func (g *generator) always(t, condition *gql.SchemaType) bool {
+	if t.Name == condition.Name {
+		return true
+	}
+	if t.Kind != gql.KindObject {
+		return false
+	}
+	for _, possible := range condition.PossibleTypes {
+		if possible.NamedType() == t.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// inputType returns the Go type of a scalar, enum or input object, declaring it when needed
+func (g *generator) inputType(typeName string) (string, error) {
+	t := g.schema.Type(typeName)
+	if t == nil {
+		return "", fmt.Errorf("unknown type %s", typeName)
+	}
+	switch t.Kind {
+	case gql.KindScalar:
+		return g.scalar(typeName), nil
+	case gql.KindEnum:
+		return g.enum(t), nil
+	case gql.KindInputObject:
+		return g.input(t)
+	}
+	return "", fmt.Errorf("%s is not an input type", typeName)
+}
+
+// scalar returns the Go type of a scalar, importing its package
+func (g *generator) scalar(name string) string {
+	goType, ok := g.scalars[name]
+	if !ok {
+		if !g.warned[name] {
+			g.warned[name] = true
+			g.warnings = append(g.warnings, fmt.Sprintf("scalar %s has no Go type, using json.RawMessage - map it with -scalar %s=Type", name, name))
+		}
+		goType = "json.RawMessage"
+	}
+	dot := strings.LastIndex(goType, ".")
+	if dot < 0 {
+		return goType
+	}
+	importPath := goType[:dot]
+	if standard, ok := standardPackages[importPath]; ok {
+		importPath = standard
+	}
+	g.imports[importPath] = true
+	return path.Base(goType[:dot]) + goType[dot:]
+}
+
+func (g *generator) enum(t *gql.SchemaType) string {
+	if name, ok := g.types[t.Name]; ok {
+		return name
+	}
+	name := g.declare(goName(t.Name))
+	g.types[t.Name] = name
+
+	var values strings.Builder
+	for _, value := range t.EnumValues {
+		if value.IsDeprecated {
+			fmt.Fprintf(&values, "\t// Deprecated: %s\n", value.DeprecationReason)
+		}
+		fmt.Fprintf(&values, "\t%s %s = %q\n", g.declare(name+goName(value.Name)), name, value.Name)
+	}
+	g.decls = append(g.decls, fmt.Sprintf("%s\ntype %s string\n\nconst (\n%s)\n", typeDoc(name, t), name, values.String()))
+	return name
+}
+
+func (g *generator) input(t *gql.SchemaType) (string, error) {
+	if name, ok := g.types[t.Name]; ok {
+		return name, nil
+	}
+	name := g.declare(goName(t.Name))
+	g.types[t.Name] = name
+
+	slot := len(g.decls)
+	g.decls = append(g.decls, "")
+	var body strings.Builder
+	used := make(map[string]bool)
+	for _, field := range t.InputFields {
+		goType, err := g.goType(field.Type, g.inputType)
+		if err != nil {
+			return "", err
+		}
+		tag := field.Name
+		if !field.Type.IsNonNull() {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&body, "\t%s %s `json:%q`\n", uniqueName(goName(field.Name), used), goType, tag)
+	}
+	g.decls[slot] = fmt.Sprintf("%s\ntype %s struct {\n%s}\n", typeDoc(name, t), name, body.String())
+	return name, nil
+}
+
+// goType returns the Go type of a type reference, with named types resolved by named. Nullable
+// values are pointers, unless their Go type can already be nil.
+func (g *generator) goType(ref *gql.TypeRef, named func(string) (string, error)) (string, error) {
+	nonNull := ref.Kind == gql.KindNonNull
+	if nonNull {
+		ref = ref.OfType
+	}
+	if ref.Kind == gql.KindList {
+		elem, err := g.goType(ref.OfType, named)
+		return "[]" + elem, err
+	}
+	goType, err := named(ref.Name)
+	if err != nil {
+		return "", err
+	}
+	if !nonNull && !nilable(goType) {
+		goType = "*" + goType
+	}
+	return goType, nil
+}
+
+// declare reserves a package level identifier, numbering it when it's taken
+func (g *generator) declare(name string) string {
+	return uniqueName(name, g.names)
+}
+
+// uniqueName marks name as used, numbering it when it already was
+func uniqueName(name string, used map[string]bool) string {
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// hasCondition reports whether @include or @skip may leave the selection out
+func hasCondition(directives []*ast.Directive) bool {
+	for _, d := range directives {
+		if d.Name == "include" || d.Name == "skip" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeDoc is the comment of a generated schema type: its description, or its GraphQL name
+func typeDoc(name string, t *gql.SchemaType) string {
+	doc := fmt.Sprintf("// %s is the %s type", name, t.Name)
+	if description := strings.TrimSpace(t.Description); description != "" {
+		doc += ".\n// " + strings.ReplaceAll(description, "\n", "\n// ")
+	}
+	return doc
+}
+
+// typeRef converts a type written in an operation into the schema's representation
+func typeRef(t *ast.Type) *gql.TypeRef {
+	ref := &gql.TypeRef{Name: t.Name}
+	if t.Elem != nil {
+		ref = &gql.TypeRef{Kind: gql.KindList, OfType: typeRef(t.Elem)}
+	}
+	if t.NonNull {
+		ref = &gql.TypeRef{Kind: gql.KindNonNull, OfType: ref}
+	}
+	return ref
+}
+
+// nilable reports whether values of the Go type can be nil, so they need no pointer
+func nilable(goType string) bool {
+	return strings.HasPrefix(goType, "[]") || strings.HasPrefix(goType, "*") || strings.HasPrefix(goType, "map[") ||
+		goType == "json.RawMessage" || goType == "interface{}" || goType == "any"
+}
+
+// goString quotes s as a Go string literal, raw when possible
+func goString(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// goName turns a GraphQL name into an exported Go identifier: users_bool_exp becomes
+// UsersBoolExp and userIds becomes UserIDs
+func goName(name string) string {
+	var words []string
+	start := -1
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '_':
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+			}
+			start = -1
+		case start < 0:
+			start = i
+		case unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]):
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+
+	var b strings.Builder
+	for _, word := range words {
+		lower := strings.ToLower(word)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(word))
+			continue
+		}
+		if stem := strings.TrimSuffix(lower, "s"); stem != lower && initialisms[stem] {
+			b.WriteString(strings.ToUpper(stem) + "s")
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	if b.Len() == 0 || unicode.IsDigit(rune(b.String()[0])) {
+		return "X" + b.String()
+	}
+	return b.String()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gql "github.com/lukaszraczylo/go-simple-graphql"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func testSchema(t *testing.T) *gql.Schema {
+	t.Helper()
+	schema, err := gql.LoadSchema("testdata/schema.graphql")
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	return schema
+}
+
+func TestGenerate(t *testing.T) {
+	sources, err := readSources([]string{"testdata/queries/*.graphql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, warnings, err := generate(testSchema(t), sources, config{Package: "api", Scalars: map[string]string{"citext": "string"}})
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("generate() warnings = %v", warnings)
+	}
+
+	golden := filepath.Join("testdata", "client.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, code, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, want) {
+		t.Errorf("generate() differs from %s, run go test -update to refresh it:\n%s", golden, code)
+	}
+}
+
+func TestGenerate_Scalars(t *testing.T) {
+	sources := []*source{{Name: "users.graphql", Content: `query Karma { users { email karma balance } }`}}
+
+	code, warnings, err := generate(testSchema(t), sources, config{Package: "api"})
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "scalar citext has no Go type") {
+		t.Errorf("warnings = %v, want one for citext", warnings)
+	}
+	for _, want := range []string{"Email   json.RawMessage", "Karma   int64", "Balance *json.Number"} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generated code is missing %q", want)
+		}
+	}
+
+	code, _, err = generate(testSchema(t), sources, config{Package: "api", Scalars: map[string]string{
+		"citext":  "string",
+		"bigint":  "big.Int",
+		"numeric": "github.com/shopspring/decimal.Decimal",
+	}})
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	for _, want := range []string{`"math/big"`, `"github.com/shopspring/decimal"`, "Email   string", "Karma   big.Int", "Balance *decimal.Decimal"} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("generated code is missing %q", want)
+		}
+	}
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []*source
+		want    string
+	}{
+		{
+			name:    "anonymous operation",
+			sources: []*source{{Name: "a.graphql", Content: `{ users { id } }`}},
+			want:    "a.graphql:1:1: operations need a name to generate a method",
+		},
+		{
+			name: "duplicate operation",
+			sources: []*source{
+				{Name: "a.graphql", Content: `query Users { users { id } }`},
+				{Name: "b.graphql", Content: `query Users { users { name } }`},
+			},
+			want: "b.graphql: operation Users is already defined in a.graphql",
+		},
+		{
+			name: "duplicate fragment",
+			sources: []*source{
+				{Name: "a.graphql", Content: `fragment F on users { id }`},
+				{Name: "b.graphql", Content: `fragment F on users { name }`},
+			},
+			want: "b.graphql: fragment F is already defined in a.graphql",
+		},
+		{
+			name:    "unknown fragment",
+			sources: []*source{{Name: "a.graphql", Content: `query Users { users { ...Missing } }`}},
+			want:    "a.graphql: query Users: unknown fragment Missing",
+		},
+		{
+			name:    "invalid operation",
+			sources: []*source{{Name: "a.graphql", Content: `query Users { users { nme } }`}},
+			want:    `a.graphql: query Users: invalid operation: 1:23: Cannot query field "nme" on type "users". Did you mean "name"?`,
+		},
+		{
+			name:    "syntax error",
+			sources: []*source{{Name: "a.graphql", Content: `query Users { users { id }`}},
+			want:    "a.graphql: syntax error at 1:27: expected Name, found <EOF>",
+		},
+		{
+			name:    "fragments only",
+			sources: []*source{{Name: "a.graphql", Content: `fragment F on users { id }`}},
+			want:    "no operations found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := generate(testSchema(t), tt.sources, config{Package: "api"})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("generate() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"users":          "Users",
+		"users_bool_exp": "UsersBoolExp",
+		"_and":           "And",
+		"__typename":     "Typename",
+		"created_at":     "CreatedAt",
+		"userId":         "UserID",
+		"user_ids":       "UserIDs",
+		"GetUserByID":    "GetUserByID",
+		"uuid":           "UUID",
+		"2fa_enabled":    "X2faEnabled",
+	}
+	for name, want := range tests {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-api")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "client_gen.go")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"-schema", "testdata/schema.graphql", "-out", out, "testdata/queries/*.graphql"}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	code, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "package my_api\n") {
+		t.Errorf("the package isn't named after the output directory:\n%s", code[:100])
+	}
+	if !strings.Contains(stderr.String(), "warning: scalar citext") {
+		t.Errorf("stderr = %q, want a warning about citext", stderr.String())
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"testdata/queries/*.graphql"}, "-schema is required"},
+		{[]string{"-schema", "testdata/schema.graphql"}, "no operation files given"},
+		{[]string{"-schema", "testdata/schema.graphql", "testdata/queries/*.graphql"}, "-package is required when writing to standard output"},
+		{[]string{"-schema", "testdata/schema.graphql", "-package", "api", "testdata/missing/*.graphql"}, `no files match "testdata/missing/*.graphql"`},
+		{[]string{"-scalar", "uuid"}, `invalid value "uuid" for flag -scalar: expected name=GoType, got "uuid"`},
+	} {
+		if err := run(tt.args, &stdout, &stderr); err == nil || err.Error() != tt.want {
+			t.Errorf("run(%q) error = %v, want %s", tt.args, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Command gqlgen-client generates a typed client from GraphQL operation files and a schema.
+//
+// For every named operation it writes the document as a constant, a struct of its variables,
+// a struct of its response and a method on Client running it through gql.BaseClient:
+//
+//	gqlgen-client -schema schema.graphql -out api/client_gen.go -scalar numeric=github.com/shopspring/decimal.Decimal 'queries/*.graphql'
+//
+// The schema is an introspection result, as saved by gql.Schema.Save, or SDL. Operation
+// arguments are files or glob patterns, so it can run from go:generate without a shell.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gql "github.com/lukaszraczylo/go-simple-graphql"
+)
+
+// scalarFlags collects the repeated -scalar name=GoType flags
+type scalarFlags map[string]string
+
+func (s scalarFlags) String() string {
+	pairs := make([]string, 0, len(s))
+	for name, goType := range s {
+		pairs = append(pairs, name+"="+goType)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (s scalarFlags) Set(value string) error {
+	name, goType, ok := strings.Cut(value, "=")
+	if !ok || name == "" || goType == "" {
+		return fmt.Errorf("expected name=GoType, got %q", value)
+	}
+	s[name] = goType
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "gqlgen-client:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("gqlgen-client", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	schemaPath := flags.String("schema", "", "schema file: an introspection result (JSON) or SDL")
+	out := flags.String("out", "", "file to write, standard output when empty")
+	pkg := flags.String("package", "", "package of the generated code, named after the directory of -out by default")
+	scalars := scalarFlags{}
+	flags.Var(scalars, "scalar", "Go type of a scalar as name=Type, name=package.Type or name=import/path.Type, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *schemaPath == "" {
+		return errors.New("-schema is required")
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no operation files given")
+	}
+	if *pkg == "" {
+		if *out == "" {
+			return errors.New("-package is required when writing to standard output")
+		}
+		dir, err := filepath.Abs(filepath.Dir(*out))
+		if err != nil {
+			return err
+		}
+		*pkg = strings.ReplaceAll(filepath.Base(dir), "-", "_")
+	}
+
+	schema, err := gql.LoadSchema(*schemaPath)
+	if err != nil {
+		return err
+	}
+	sources, err := readSources(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	code, warnings, err := generate(schema, sources, config{Package: *pkg, Scalars: scalars})
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintln(stderr, "gqlgen-client: warning:", warning)
+	}
+	if *out == "" {
+		_, err = stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(*out, code, 0o644)
+}
+
+// readSources reads the operation files, expanding glob patterns
+func readSources(patterns []string) ([]*source, error) {
+	var sources []*source
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, &source{Name: path, Content: string(content)})
+		}
+	}
+	return sources, nil
+}
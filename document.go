@@ -0,0 +1,104 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+)
+
+// Document is a GraphQL document holding one or more operations. It is parsed once by
+// ParseDocument and its operations are then run by name, which is sent as operationName.
+type Document struct {
+	operations map[string]string // Operation type by name
+	doc        *ast.Document
+	query      string
+	names      []string
+}
+
+// ParseDocument parses a document so its operations can be run with QueryOperation. Operation
+// names must be unique; an anonymous operation has to be the only one in the document.
+func ParseDocument(query string) (*Document, error) {
+	doc, err := ast.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Operations) == 0 {
+		return nil, errors.New("document holds no operations")
+	}
+
+	d := &Document{
+		operations: make(map[string]string, len(doc.Operations)),
+		doc:        doc,
+		query:      query,
+		names:      make([]string, 0, len(doc.Operations)),
+	}
+	for _, op := range doc.Operations {
+		if op.Name == "" && len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("%s: anonymous operation must be the only one in the document", op.Position)
+		}
+		if _, ok := d.operations[op.Name]; ok {
+			return nil, fmt.Errorf("%s: operation %s is already defined", op.Position, op.Name)
+		}
+		d.operations[op.Name] = op.Operation
+		d.names = append(d.names, op.Name)
+	}
+	return d, nil
+}
+
+// Operations returns the names of the document's operations in the order they're defined
+func (d *Document) Operations() []string {
+	return append([]string(nil), d.names...)
+}
+
+// request prepares the named operation without parsing the document again. The name may only
+// be left empty when the document holds a single operation.
+func (d *Document) request(operationName string, variables map[string]interface{}, headers map[string]interface{}) (*Request, error) {
+	if operationName == "" && len(d.names) == 1 {
+		operationName = d.names[0]
+	}
+	operationType, ok := d.operations[operationName]
+	if !ok {
+		if operationName == "" {
+			return nil, errors.New("document holds several operations, name the one to run")
+		}
+		return nil, fmt.Errorf("unknown operation %s", operationName)
+	}
+	return &Request{
+		Query:         d.query,
+		Variables:     variables,
+		Headers:       headers,
+		OperationName: operationName,
+		OperationType: operationType,
+		parsed:        &parsedQuery{doc: d.doc, query: d.query},
+	}, nil
+}
+
+// QueryOperation runs the operation named operationName from doc. Caching, retries and batching
+// follow the type of that operation, and responses are cached per operation name.
+func (b *BaseClient) QueryOperation(ctx context.Context, doc *Document, operationName string, variables map[string]interface{}, headers map[string]interface{}) (any, error) {
+	req, err := doc.request(operationName, variables, headers)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := b.query(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return b.decodeResponse(rv)
+}
+
+// QueryOperationInto runs the operation named operationName from doc and unmarshals its data
+// straight into dst, which must be a pointer
+func (b *BaseClient) QueryOperationInto(ctx context.Context, doc *Document, operationName string, variables map[string]interface{}, headers map[string]interface{}, dst any) error {
+	req, err := doc.request(operationName, variables, headers)
+	if err != nil {
+		return err
+	}
+	rv, err := b.query(ctx, req)
+	if err != nil {
+		return err
+	}
+	return b.decodeResponseInto(rv, dst)
+}
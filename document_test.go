@@ -0,0 +1,120 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+const testDocument = `
+fragment Login on User { login }
+
+query Viewer { viewer { ...Login } }
+
+query Users($limit: Int) { users(limit: $limit) { ...Login } }
+
+mutation Logout { logout }
+`
+
+// newOperationServer answers with the operation name it was asked to run, counting requests
+func newOperationServer() (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		raw, _ := io.ReadAll(r.Body)
+		var operation Query
+		json.Unmarshal(raw, &operation)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":{"operation":%q}}`, operation.OperationName)
+	}))
+	return server, &requests
+}
+
+func TestParseDocument(t *testing.T) {
+	doc, err := ParseDocument(testDocument)
+	if err != nil {
+		t.Fatalf("ParseDocument() error = %v", err)
+	}
+	if got := fmt.Sprint(doc.Operations()); got != "[Viewer Users Logout]" {
+		t.Errorf("Operations() = %s, want [Viewer Users Logout]", got)
+	}
+
+	tests := map[string]string{
+		`query A { a `:                 "syntax error at 1:13: expected Name, found <EOF>",
+		`fragment F on User { id }`:    "document holds no operations",
+		`query A { a } query A { b }`:  "1:15: operation A is already defined",
+		`query A { a } { b }`:          "1:15: anonymous operation must be the only one in the document",
+		`query { a } mutation B { b }`: "1:1: anonymous operation must be the only one in the document",
+	}
+	for query, want := range tests {
+		if _, err := ParseDocument(query); err == nil || err.Error() != want {
+			t.Errorf("ParseDocument(%q) error = %v, want %s", query, err, want)
+		}
+	}
+}
+
+func (suite *Tests) TestBaseClient_QueryOperation() {
+	doc, err := ParseDocument(testDocument)
+	assert.NoError(err)
+
+	suite.T().Run("should send the name of the operation", func(t *testing.T) {
+		server, _ := newOperationServer()
+		defer server.Close()
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()))
+		assert.NoError(err)
+
+		var seen *Request
+		b.Use(func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				seen = req
+				return next(ctx, req)
+			}
+		})
+
+		var result struct{ Operation string }
+		assert.NoError(b.QueryOperationInto(context.Background(), doc, "Users", map[string]interface{}{"limit": 1}, nil, &result))
+		assert.Equal("Users", result.Operation)
+		assert.Equal(OperationQuery, seen.OperationType)
+
+		_, err = b.QueryOperation(context.Background(), doc, "Logout", nil, nil)
+		assert.NoError(err)
+		assert.Equal("Logout", seen.OperationName)
+		assert.Equal(OperationMutation, seen.OperationType)
+	})
+
+	suite.T().Run("should cache every operation of the document on its own", func(t *testing.T) {
+		server, requests := newOperationServer()
+		defer server.Close()
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute))
+		assert.NoError(err)
+
+		for _, name := range []string{"Viewer", "Users", "Viewer", "Users"} {
+			var result struct{ Operation string }
+			assert.NoError(b.QueryOperationInto(context.Background(), doc, name, nil, nil, &result))
+			assert.Equal(name, result.Operation)
+		}
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+
+		// Mutations of the same document never are
+		for i := 0; i < 2; i++ {
+			_, err := b.QueryOperation(context.Background(), doc, "Logout", nil, nil)
+			assert.NoError(err)
+		}
+		assert.Equal(int32(4), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should reject operations the document doesn't hold", func(t *testing.T) {
+		b := NewConnection()
+		_, err := b.QueryOperation(context.Background(), doc, "Missing", nil, nil)
+		assert.EqualError(err, "unknown operation Missing")
+		_, err = b.QueryOperation(context.Background(), doc, "", nil, nil)
+		assert.EqualError(err, "document holds several operations, name the one to run")
+	})
+}
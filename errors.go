@@ -0,0 +1,123 @@
+package gql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Location points at the line and column of the query document an error refers to
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is a single entry of the "errors" array returned by the server
+type GraphQLError struct {
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Locations  []Location             `json:"locations,omitempty"`
+}
+
+func (e GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%s (path: %s)", e.Message, strings.Join(parts, "."))
+}
+
+// Code returns extensions.code, e.g. Hasura's "constraint-violation" or "validation-failed"
+func (e GraphQLError) Code() string {
+	if code, ok := e.Extensions["code"].(string); ok {
+		return code
+	}
+	return ""
+}
+
+// UnmarshalJSON tolerates servers that send the message as a nested object
+// ({"message": {"message": "...", "extensions": {...}}}) or as a non-string value
+func (e *GraphQLError) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Extensions map[string]interface{} `json:"extensions"`
+		Message    json.RawMessage        `json:"message"`
+		Path       []interface{}          `json:"path"`
+		Locations  []Location             `json:"locations"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*e = GraphQLError{
+		Extensions: raw.Extensions,
+		Path:       raw.Path,
+		Locations:  raw.Locations,
+	}
+
+	if isNullJSON(raw.Message) {
+		return nil
+	}
+
+	var msg interface{}
+	if err := json.Unmarshal(raw.Message, &msg); err != nil {
+		return err
+	}
+	switch m := msg.(type) {
+	case string:
+		e.Message = m
+	case map[string]interface{}:
+		if nested, ok := m["message"].(string); ok {
+			e.Message = nested
+		}
+		if e.Extensions == nil {
+			if ext, ok := m["extensions"].(map[string]interface{}); ok {
+				e.Extensions = ext
+			}
+		}
+	default:
+		e.Message = fmt.Sprint(m)
+	}
+	return nil
+}
+
+// Errors is the list of errors returned by the server. Failed queries wrap it,
+// so it can be retrieved with errors.As.
+type Errors []GraphQLError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// HasCode reports whether any of the errors carries the given extensions.code
+func (e Errors) HasCode(code string) bool {
+	for _, err := range e {
+		if err.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Codes returns the distinct extensions.code values in order of appearance
+func (e Errors) Codes() []string {
+	codes := make([]string, 0, len(e))
+	seen := make(map[string]bool, len(e))
+	for _, err := range e {
+		code := err.Code()
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes
+}
@@ -0,0 +1,156 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func (suite *Tests) TestGraphQLError_UnmarshalJSON() {
+	tests := []struct {
+		name     string
+		input    string
+		wantMsg  string
+		wantCode string
+		wantPath []interface{}
+		wantLocs []Location
+	}{
+		{
+			name:     "full error",
+			input:    `{"message":"Cannot query field","path":["user",0,"name"],"locations":[{"line":1,"column":9}],"extensions":{"code":"validation-failed"}}`,
+			wantMsg:  "Cannot query field",
+			wantCode: "validation-failed",
+			wantPath: []interface{}{"user", float64(0), "name"},
+			wantLocs: []Location{{Line: 1, Column: 9}},
+		},
+		{
+			name:    "message only",
+			input:   `{"message":"Field not found"}`,
+			wantMsg: "Field not found",
+		},
+		{
+			name:     "nested message object",
+			input:    `{"message":{"message":"postgres error","extensions":{"code":"postgres-error"}}}`,
+			wantMsg:  "postgres error",
+			wantCode: "postgres-error",
+		},
+		{
+			name:    "non-string message",
+			input:   `{"message":42}`,
+			wantMsg: "42",
+		},
+		{
+			name:    "null message",
+			input:   `{"message":null}`,
+			wantMsg: "",
+		},
+	}
+	for _, tt := range tests {
+		suite.T().Run(tt.name, func(t *testing.T) {
+			var got GraphQLError
+			err := json.Unmarshal([]byte(tt.input), &got)
+			assert.NoError(err)
+			assert.Equal(tt.wantMsg, got.Message)
+			assert.Equal(tt.wantCode, got.Code())
+			assert.Equal(tt.wantPath, got.Path)
+			assert.Equal(tt.wantLocs, got.Locations)
+		})
+	}
+}
+
+func (suite *Tests) TestErrors() {
+	errs := Errors{
+		{Message: "Uniqueness violation", Extensions: map[string]interface{}{"code": "constraint-violation"}},
+		{Message: "Cannot query field", Path: []interface{}{"user", 1}, Extensions: map[string]interface{}{"code": "validation-failed"}},
+		{Message: "Other uniqueness violation", Extensions: map[string]interface{}{"code": "constraint-violation"}},
+	}
+
+	suite.T().Run("should join messages", func(t *testing.T) {
+		assert.Equal("Uniqueness violation; Cannot query field (path: user.1); Other uniqueness violation", errs.Error())
+	})
+
+	suite.T().Run("should find codes", func(t *testing.T) {
+		assert.True(errs.HasCode("constraint-violation"))
+		assert.True(errs.HasCode("validation-failed"))
+		assert.False(errs.HasCode("invalid-jwt"))
+		assert.Equal([]string{"constraint-violation", "validation-failed"}, errs.Codes())
+	})
+
+	suite.T().Run("should return empty code without extensions", func(t *testing.T) {
+		assert.Empty(GraphQLError{Message: "plain"}.Code())
+	})
+}
+
+func (suite *Tests) TestQueryExecutor_executeQuery_structuredErrors() {
+	suite.T().Run("should expose errors via errors.As", func(t *testing.T) {
+		errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"errors":[{"message":"Uniqueness violation","extensions":{"path":"$.selectionSet.insert_users.args.objects","code":"constraint-violation"}}]}`))
+		}))
+		defer errorServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(errorServer.URL)
+		b.SetHTTPClient(errorServer.Client())
+
+		_, err := b.Query("mutation { insert_users(objects: {}) { affected_rows } }", nil, nil)
+		assert.Error(err)
+		assert.Contains(err.Error(), "error executing query")
+
+		var gqlErrs Errors
+		assert.True(errors.As(err, &gqlErrs))
+		assert.True(gqlErrs.HasCode("constraint-violation"))
+		assert.Equal("Uniqueness violation", gqlErrs[0].Message)
+	})
+
+	suite.T().Run("should expose errors of HTTP error responses", func(t *testing.T) {
+		errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Cannot query field \"nope\"","extensions":{"code":"GRAPHQL_VALIDATION_FAILED"}}]}`))
+		}))
+		defer errorServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(errorServer.URL)
+		b.SetHTTPClient(errorServer.Client())
+
+		_, err := b.Query("query { nope }", nil, nil)
+		assert.ErrorContains(err, "HTTP error - status code: 400 Bad Request")
+
+		var gqlErrs Errors
+		assert.True(errors.As(err, &gqlErrs))
+		assert.True(gqlErrs.HasCode("GRAPHQL_VALIDATION_FAILED"))
+
+		result, err := b.QueryPartial(context.Background(), "query { nope }", nil, nil)
+		assert.Error(err)
+		assert.Nil(result)
+	})
+
+	suite.T().Run("should expose retryable errors after retries", func(t *testing.T) {
+		errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"errors":[{"message":"postgres connection lost","extensions":{"code":"unexpected"}}]}`))
+		}))
+		defer errorServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(errorServer.URL)
+		b.SetHTTPClient(errorServer.Client())
+		b.retries_enable = true
+		b.retries_number = 2
+		b.retries_delay = 0
+
+		_, err := b.Query("query { viewer { login } }", nil, nil)
+		assert.Error(err)
+		assert.Contains(err.Error(), "retryable error executing query")
+
+		var gqlErrs Errors
+		assert.True(errors.As(err, &gqlErrs))
+		assert.Equal([]string{"unexpected"}, gqlErrs.Codes())
+	})
+}
@@ -3,6 +3,7 @@ package gql
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -32,37 +33,23 @@ func max(a, b int) int {
 	return b
 }
 
+// errRetryableQuery marks GraphQL errors that matched a retry pattern
+var errRetryableQuery = errors.New("retryable error executing query")
+
 // isRetryableError checks if GraphQL errors contain patterns that indicate a transient failure
 // that should be retried (e.g., database connection issues, timeouts, transaction conflicts)
-func isRetryableError(errors []struct {
-	Message interface{} `json:"message"`
-}, patterns []string) bool {
+func isRetryableError(errors Errors, patterns []string) bool {
 	if len(errors) == 0 || len(patterns) == 0 {
 		return false
 	}
 
 	for _, err := range errors {
-		// Try to extract error message as string
-		var msgStr string
-		switch msg := err.Message.(type) {
-		case string:
-			msgStr = msg
-		case map[string]interface{}:
-			// Handle nested error structures (e.g., {"message": "...", "extensions": {...}})
-			if nestedMsg, ok := msg["message"].(string); ok {
-				msgStr = nestedMsg
-			}
-		default:
-			// If we can't convert to string, try fmt.Sprint as fallback
-			msgStr = fmt.Sprint(msg)
-		}
-
-		if msgStr == "" {
+		if err.Message == "" {
 			continue
 		}
 
 		// Check if error message contains any of the configured retry patterns (case-insensitive)
-		msgLower := strings.ToLower(msgStr)
+		msgLower := strings.ToLower(err.Message)
 		for _, pattern := range patterns {
 			if strings.Contains(msgLower, strings.ToLower(pattern)) {
 				return true
@@ -76,7 +63,100 @@ func isRetryableError(errors []struct {
 // Note: defaultClient removed - all clients should be created via NewConnection()
 // which properly configures HTTP/2 transport with correct settings
 
-func (qe *QueryExecutor) executeQuery() ([]byte, error) {
+// executeResult sends the query and returns the whole response. With AllowPartial set,
+// GraphQL errors are handed back alongside the data instead of failing the call.
+func (qe *QueryExecutor) executeResult(ctx context.Context) (*QueryResult, error) {
+	var queryResult queryResults
+	err := qe.roundTrip(ctx, func() any {
+		// Drop anything left over from a previous attempt
+		queryResult = queryResults{}
+		return &queryResult
+	}, func() error {
+		// Check for GraphQL errors in the response
+		if len(queryResult.Errors) > 0 {
+			// Determine if this is a retryable error based on configured patterns
+			if isRetryableError(queryResult.Errors, qe.retries_patterns) {
+				// Log as warning since we'll retry
+				qe.Logger.Warning(&libpack_logger.LogMessage{
+					Message: "Retryable GraphQL error detected",
+					Pairs:   map[string]interface{}{"errors": queryResult.Errors},
+				})
+				// Return error to trigger retry mechanism
+				return fmt.Errorf("%w: %w", errRetryableQuery, queryResult.Errors)
+			}
+
+			// Partial results are handed back to the caller together with the errors
+			if qe.AllowPartial {
+				qe.Logger.Debug(&libpack_logger.LogMessage{
+					Message: "GraphQL errors returned alongside data",
+					Pairs:   map[string]interface{}{"errors": queryResult.Errors, "has_data": !isNullJSON(queryResult.Data)},
+				})
+				return nil
+			}
+
+			// Non-retryable error - log and fail immediately without retrying
+			qe.Logger.Error(&libpack_logger.LogMessage{
+				Message: "Non-retryable GraphQL error",
+				Pairs:   map[string]interface{}{"errors": queryResult.Errors},
+			})
+			// Use retry.Unrecoverable to skip remaining retry attempts
+			return retry.Unrecoverable(fmt.Errorf("error executing query: %w", queryResult.Errors))
+		}
+
+		// Check for null data in the response
+		if isNullJSON(queryResult.Data) {
+			qe.Logger.Error(&libpack_logger.LogMessage{
+				Message: "GraphQL query returned no data",
+				Pairs:   map[string]interface{}{"error": "data field is null"},
+			})
+			// Null data is typically not retryable (indicates query structure issue)
+			return retry.Unrecoverable(errors.New("error executing query: no data"))
+		}
+
+		return nil
+	})
+	if err != nil {
+		// Retryable GraphQL errors that outlived all attempts still carry a usable response
+		if !qe.AllowPartial || !errors.Is(err, errRetryableQuery) {
+			qe.Logger.Error(&libpack_logger.LogMessage{
+				Message: "Query execution failed after retries",
+				Pairs:   map[string]interface{}{"error": err.Error()},
+			})
+			return nil, err
+		}
+	}
+
+	result := &QueryResult{
+		Errors:     queryResult.Errors,
+		Extensions: queryResult.Extensions,
+	}
+	if isNullJSON(queryResult.Data) {
+		return result, nil
+	}
+
+	// At this point, we have successfully executed the query and validated the response
+	// Compact the raw data into a fresh slice - it must not alias the pooled response buffers
+	var dataBuf bytes.Buffer
+	if err := json.Compact(&dataBuf, queryResult.Data); err != nil {
+		qe.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Error compacting query result",
+			Pairs:   map[string]interface{}{"error": err.Error(), "data": string(queryResult.Data)},
+		})
+		return nil, fmt.Errorf("error compacting query result: %w. Data: %s", err, queryResult.Data)
+	}
+	result.Data = dataBuf.Bytes()
+
+	// Responses carrying errors are never cached
+	if qe.CacheKey != "no-cache" && len(result.Errors) == 0 {
+		qe.cache.Set(qe.CacheKey, result.Data, qe.CacheTTL)
+	}
+
+	return result, nil
+}
+
+// roundTrip sends the request, retrying as configured, and unmarshals every response into
+// the value decodeInto returns. check then inspects the decoded response and decides whether to retry.
+func (qe *QueryExecutor) roundTrip(ctx context.Context, decodeInto func() any, check func() error) error {
 	// Reuse buffer from pool to avoid allocations
 	buf := bufferPool.Get().(*bytes.Buffer)
 	defer bufferPool.Put(buf)
@@ -84,13 +164,22 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 
 	buf.Write(qe.Query)
 
-	httpRequest, err := http.NewRequest(http.MethodPost, qe.endpoint, nil)
+	method, target := http.MethodPost, qe.endpoint
+	if qe.Method == http.MethodGet {
+		method = http.MethodGet
+		var err error
+		if target, err = getRequestURL(qe.endpoint, qe.Query); err != nil {
+			return err
+		}
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, method, target, nil)
 	if err != nil {
 		qe.Logger.Error(&libpack_logger.LogMessage{
 			Message: "Can't create HTTP request",
 			Pairs:   map[string]interface{}{"error": err.Error()},
 		})
-		return nil, fmt.Errorf("can't create HTTP request: %w", err)
+		return fmt.Errorf("can't create HTTP request: %w", err)
 	}
 
 	for key, value := range qe.Headers {
@@ -107,56 +196,25 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 	httpRequest.Header.Set("Accept-Encoding", "identity")
 
 	retriesMax := 1
-	if qe.Retries {
+	if qe.Retries && len(qe.Uploads) == 0 {
 		retriesMax = qe.retries_number
 	}
 
-	var queryResult queryResults
-	err = retry.Do(
+	return retry.Do(
 		func() error {
-			// Reset buffer before each retry
-			buf.Reset()
-			buf.Write(qe.Query)
-
-			// Comprehensive request body analysis for trailing garbage debugging
-			requestBody := buf.Bytes()
-			qe.Logger.Debug(&libpack_logger.LogMessage{
-				Message: "Request body analysis",
-				Pairs: map[string]interface{}{
-					"body_size":          len(requestBody),
-					"contains_jsonQuery": bytes.Contains(requestBody, []byte(`"jsonQuery"`)),
-					"contains_base64":    bytes.Contains(requestBody, []byte("eyJ")),
-					"first_100_chars":    string(requestBody[:min(100, len(requestBody))]),
-					"last_50_chars":      string(requestBody[max(0, len(requestBody)-50):]),
-					"is_valid_utf8":      utf8.Valid(requestBody),
-					"has_null_bytes":     bytes.Contains(requestBody, []byte{0}),
-					"has_control_chars":  hasControlChars(requestBody),
-				},
-			})
-
-			// Validate request body structure
-			if err := validateRequestBody(requestBody, qe.Logger); err != nil {
-				qe.Logger.Error(&libpack_logger.LogMessage{
-					Message: "Request body validation failed",
-					Pairs:   map[string]interface{}{"error": err.Error()},
-				})
-				return fmt.Errorf("request body validation failed: %w", err)
+			dst := decodeInto()
+
+			switch {
+			case len(qe.Uploads) > 0:
+				qe.setUploadBody(httpRequest)
+			case method == http.MethodGet:
+				// Everything travels in the URL
+			default:
+				if err := qe.setJSONBody(httpRequest, buf); err != nil {
+					return err
+				}
 			}
 
-			// Set the body of the request (plain JSON, no compression)
-			httpRequest.Body = io.NopCloser(bytes.NewReader(requestBody))
-			httpRequest.ContentLength = int64(len(requestBody))
-
-			// Debug log to confirm request is sent as plain JSON
-			qe.Logger.Debug(&libpack_logger.LogMessage{
-				Message: "Sending GraphQL request as plain JSON",
-				Pairs: map[string]interface{}{
-					"content_length":       httpRequest.ContentLength,
-					"content_type":         httpRequest.Header.Get("Content-Type"),
-					"compression_disabled": true,
-				},
-			})
-
 			// Client should always be set via NewConnection(), but check for safety
 			if qe.client == nil {
 				qe.Logger.Error(&libpack_logger.LogMessage{
@@ -166,8 +224,12 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 				return fmt.Errorf("HTTP client not initialized")
 			}
 
-			httpResponse, err := qe.client.Do(httpRequest)
+			httpResponse, err := qe.roundTripper()(httpRequest)
 			if err != nil {
+				// Cancelled or expired context will not recover on the next attempt
+				if ctx.Err() != nil {
+					return retry.Unrecoverable(err)
+				}
 				return err
 			}
 			defer func() {
@@ -176,7 +238,13 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 			}()
 
 			if httpResponse.StatusCode < http.StatusOK || httpResponse.StatusCode >= http.StatusMultipleChoices {
-				return fmt.Errorf("HTTP error - status code: %s for %s", httpResponse.Status, httpRequest.URL)
+				statusErr := newHTTPStatusError(httpResponse)
+				statusErr.url = httpRequest.URL.String()
+				// Rejected credentials are refreshed once by the auth provider instead
+				if statusErr.code == http.StatusUnauthorized && qe.auth_provider != nil {
+					return retry.Unrecoverable(statusErr)
+				}
+				return statusErr
 			}
 
 			// Log Content-Encoding header for debugging
@@ -346,7 +414,7 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 			})
 
 			// Unmarshal the final processed data
-			err = json.Unmarshal(finalData, &queryResult)
+			err = json.Unmarshal(finalData, dst)
 			if err != nil {
 				qe.Logger.Error(&libpack_logger.LogMessage{
 					Message: "JSON unmarshaling failed",
@@ -361,39 +429,7 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 				return fmt.Errorf("error unmarshalling HTTP response: %w", err)
 			}
 
-			// Check for GraphQL errors in the response
-			if len(queryResult.Errors) > 0 {
-				// Determine if this is a retryable error based on configured patterns
-				if isRetryableError(queryResult.Errors, qe.retries_patterns) {
-					// Log as warning since we'll retry
-					qe.Logger.Warning(&libpack_logger.LogMessage{
-						Message: "Retryable GraphQL error detected",
-						Pairs:   map[string]interface{}{"errors": queryResult.Errors},
-					})
-					// Return error to trigger retry mechanism
-					return fmt.Errorf("retryable error executing query: %s", queryResult.Errors)
-				}
-
-				// Non-retryable error - log and fail immediately without retrying
-				qe.Logger.Error(&libpack_logger.LogMessage{
-					Message: "Non-retryable GraphQL error",
-					Pairs:   map[string]interface{}{"errors": queryResult.Errors},
-				})
-				// Use retry.Unrecoverable to skip remaining retry attempts
-				return retry.Unrecoverable(fmt.Errorf("error executing query: %s", queryResult.Errors))
-			}
-
-			// Check for null data in the response
-			if queryResult.Data == nil {
-				qe.Logger.Error(&libpack_logger.LogMessage{
-					Message: "GraphQL query returned no data",
-					Pairs:   map[string]interface{}{"error": "data field is null"},
-				})
-				// Null data is typically not retryable (indicates query structure issue)
-				return retry.Unrecoverable(errors.New("error executing query: no data"))
-			}
-
-			return nil
+			return check()
 		},
 		retry.OnRetry(func(n uint, err error) {
 			qe.Logger.Warning(&libpack_logger.LogMessage{
@@ -401,36 +437,81 @@ func (qe *QueryExecutor) executeQuery() ([]byte, error) {
 				Pairs:   map[string]interface{}{"error": err.Error(), "attempt": n + 1},
 			})
 		}),
+		retry.Context(ctx),
 		retry.Attempts(uint(retriesMax)),
 		retry.DelayType(retry.BackOffDelay),
 		retry.Delay(time.Duration(qe.retries_delay)),
 		retry.MaxDelay(10*time.Second),
 		retry.LastErrorOnly(true),
 	)
-	if err != nil {
+}
+
+// setJSONBody attaches the query as a plain JSON body, validated before it is sent
+func (qe *QueryExecutor) setJSONBody(httpRequest *http.Request, buf *bytes.Buffer) error {
+	// Reset buffer before each retry
+	buf.Reset()
+	buf.Write(qe.Query)
+
+	// Comprehensive request body analysis for trailing garbage debugging
+	requestBody := buf.Bytes()
+	qe.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Request body analysis",
+		Pairs: map[string]interface{}{
+			"body_size":          len(requestBody),
+			"contains_jsonQuery": bytes.Contains(requestBody, []byte(`"jsonQuery"`)),
+			"contains_base64":    bytes.Contains(requestBody, []byte("eyJ")),
+			"first_100_chars":    string(requestBody[:min(100, len(requestBody))]),
+			"last_50_chars":      string(requestBody[max(0, len(requestBody)-50):]),
+			"is_valid_utf8":      utf8.Valid(requestBody),
+			"has_null_bytes":     bytes.Contains(requestBody, []byte{0}),
+			"has_control_chars":  hasControlChars(requestBody),
+		},
+	})
+
+	// Validate request body structure
+	if err := validateRequestBody(requestBody, qe.Logger); err != nil {
 		qe.Logger.Error(&libpack_logger.LogMessage{
-			Message: "Query execution failed after retries",
+			Message: "Request body validation failed",
 			Pairs:   map[string]interface{}{"error": err.Error()},
 		})
-		return nil, err
+		return fmt.Errorf("request body validation failed: %w", err)
 	}
 
-	// At this point, we have successfully executed the query and validated the response
-	// Marshal queryResult.Data
-	jsonData, err := json.Marshal(queryResult.Data)
-	if err != nil {
-		qe.Logger.Error(&libpack_logger.LogMessage{
-			Message: "Error marshalling query result",
-			Pairs:   map[string]interface{}{"error": err.Error(), "data": queryResult.Data},
-		})
-		return nil, fmt.Errorf("error marshalling query result: %w. Data: %s", err, queryResult.Data)
-	}
+	// Set the body of the request (plain JSON, no compression)
+	httpRequest.Body = io.NopCloser(bytes.NewReader(requestBody))
+	httpRequest.ContentLength = int64(len(requestBody))
 
-	if qe.CacheKey != "no-cache" {
-		qe.cache.Set(qe.CacheKey, jsonData, qe.CacheTTL)
-	}
+	// Debug log to confirm request is sent as plain JSON
+	qe.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Sending GraphQL request as plain JSON",
+		Pairs: map[string]interface{}{
+			"content_length":       httpRequest.ContentLength,
+			"content_type":         httpRequest.Header.Get("Content-Type"),
+			"compression_disabled": true,
+		},
+	})
+
+	return nil
+}
+
+// setUploadBody attaches the multipart form streaming the files. The body can only be read once,
+// which is why requests with uploads are never retried.
+func (qe *QueryExecutor) setUploadBody(httpRequest *http.Request) {
+	body, contentType := qe.uploadBody()
+	httpRequest.Body = body
+	httpRequest.ContentLength = -1
+	httpRequest.Header.Set("Content-Type", contentType)
+
+	qe.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Sending GraphQL multipart upload request",
+		Pairs:   map[string]interface{}{"files": len(qe.Uploads), "content_type": contentType},
+	})
+}
 
-	return jsonData, nil
+// isNullJSON reports whether a raw JSON value is missing or an explicit null
+func isNullJSON(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
 }
 
 // hasControlChars checks if the byte slice contains control characters that might cause parsing issues
@@ -456,14 +537,25 @@ func validateRequestBody(data []byte, logger *libpack_logger.Logger) error {
 		return fmt.Errorf("request body contains null bytes")
 	}
 
-	// Check if it's valid JSON
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(data, &jsonData); err != nil {
-		return fmt.Errorf("request body is not valid JSON: %w", err)
+	// Check if it's valid JSON - a single operation or a batch of them
+	var operations []map[string]interface{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(data, &operations); err != nil {
+			return fmt.Errorf("request body is not valid JSON: %w", err)
+		}
+	} else {
+		var jsonData map[string]interface{}
+		if err := json.Unmarshal(data, &jsonData); err != nil {
+			return fmt.Errorf("request body is not valid JSON: %w", err)
+		}
+		operations = append(operations, jsonData)
 	}
 
 	// Check for jsonQuery field (this should never be present)
-	if _, exists := jsonData["jsonQuery"]; exists {
+	for _, jsonData := range operations {
+		if _, exists := jsonData["jsonQuery"]; !exists {
+			continue
+		}
 		logger.Error(&libpack_logger.LogMessage{
 			Message: "CRITICAL: jsonQuery field found in request body - this causes trailing garbage",
 			Pairs: map[string]interface{}{
@@ -3,12 +3,23 @@ package gql
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 )
 
+// executeQuery runs executeResult without a deadline and returns the data alone, as the
+// transport tests only look at the raw bytes
+func (qe *QueryExecutor) executeQuery() ([]byte, error) {
+	result, err := qe.executeResult(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
 func (suite *Tests) TestQueryExecutor_executeQuery() {
 	suite.T().Run("should execute query successfully", func(t *testing.T) {
 		client := CreateTestClient()
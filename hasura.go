@@ -0,0 +1,98 @@
+package gql
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// hasuraHeaderPrefix starts the name of every Hasura session header
+const hasuraHeaderPrefix = "x-hasura-"
+
+// HasuraSession describes who an operation runs as on Hasura. It's sent as x-hasura-* headers,
+// which Hasura trusts when the admin secret is present or its webhook / JWT mode allows it.
+type HasuraSession struct {
+	Vars        map[string]string // Extra session variables, the x-hasura- prefix is added when missing
+	Role        string
+	UserID      string
+	AdminSecret string
+}
+
+// Headers renders the session as x-hasura-* headers, leaving out empty values
+func (s HasuraSession) Headers() map[string]string {
+	headers := make(map[string]string, len(s.Vars)+3)
+	for name, value := range s.Vars {
+		name = strings.ToLower(name)
+		if !strings.HasPrefix(name, hasuraHeaderPrefix) {
+			name = hasuraHeaderPrefix + name
+		}
+		if value != "" {
+			headers[name] = value
+		}
+	}
+	if s.Role != "" {
+		headers["x-hasura-role"] = s.Role
+	}
+	if s.UserID != "" {
+		headers["x-hasura-user-id"] = s.UserID
+	}
+	if s.AdminSecret != "" {
+		headers["x-hasura-admin-secret"] = s.AdminSecret
+	}
+	return headers
+}
+
+type hasuraSessionKey struct{}
+
+// ContextWithHasuraSession runs the operations using ctx as session, in place of the client's own
+func ContextWithHasuraSession(ctx context.Context, session HasuraSession) context.Context {
+	return context.WithValue(ctx, hasuraSessionKey{}, &session)
+}
+
+// WithHasuraSession returns a client sending session with every operation. It shares the
+// connections, cache and settings of b; cached responses are kept apart per session.
+func (b *BaseClient) WithHasuraSession(session HasuraSession) *BaseClient {
+	derived := *b
+	session.Vars = maps.Clone(session.Vars)
+	derived.hasura_session = &session
+	// Middleware added to either client must not end up in the other one's backing array
+	derived.middlewares = slices.Clip(b.middlewares)
+	derived.transport_middlewares = slices.Clip(b.transport_middlewares)
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL Hasura session attached",
+		Pairs:   map[string]interface{}{"role": session.Role, "user_id": session.UserID},
+	})
+	return &derived
+}
+
+// hasuraSession returns the session of the operation - the one in ctx, otherwise the client's
+func (b *BaseClient) hasuraSession(ctx context.Context) *HasuraSession {
+	if session, ok := ctx.Value(hasuraSessionKey{}).(*HasuraSession); ok {
+		return session
+	}
+	return b.hasura_session
+}
+
+// sessionHeaders merges the session headers into a copy of headers. Headers set by the caller win.
+func (b *BaseClient) sessionHeaders(ctx context.Context, headers map[string]interface{}) map[string]interface{} {
+	session := b.hasuraSession(ctx)
+	if session == nil {
+		return headers
+	}
+	return mergeHeaders(headers, session.Headers())
+}
+
+// withHasuraSession adds the session headers to the operation
+func (b *BaseClient) withHasuraSession(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if b.hasuraSession(ctx) == nil {
+			return next(ctx, req)
+		}
+		scoped := *req
+		scoped.Headers = b.sessionHeaders(ctx, req.Headers)
+		return next(ctx, &scoped)
+	}
+}
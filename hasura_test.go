@@ -0,0 +1,103 @@
+package gql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHasuraServer answers with the role and user the request was made as
+func newHasuraServer() (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"role":"` + r.Header.Get("X-Hasura-Role") + `","user":"` + r.Header.Get("X-Hasura-User-Id") + `"}}`))
+	}))
+	return server, &requests
+}
+
+func (suite *Tests) TestBaseClient_HasuraSession() {
+	suite.T().Run("should send the session of a derived client", func(t *testing.T) {
+		server, _ := newHasuraServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		user := b.WithHasuraSession(HasuraSession{Role: "user", UserID: "42"})
+		data, err := user.Query(`query { me { id } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"role":"user","user":"42"}`, data)
+
+		// The parent keeps sending no session
+		data, err = b.Query(`query { me { id } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"role":"","user":""}`, data)
+	})
+
+	suite.T().Run("should prefer the session in the context", func(t *testing.T) {
+		server, _ := newHasuraServer()
+		defer server.Close()
+
+		user := newBatchTestClient(server).WithHasuraSession(HasuraSession{Role: "user", UserID: "42"})
+		ctx := ContextWithHasuraSession(context.Background(), HasuraSession{Role: "editor", UserID: "7"})
+		data, err := user.QueryContext(ctx, `query { me { id } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"role":"editor","user":"7"}`, data)
+
+		// Headers passed explicitly win over the session
+		data, err = user.Query(`query { me { id } }`, nil, map[string]interface{}{"X-Hasura-Role": "auditor"})
+		assert.NoError(err)
+		assert.Equal(`{"role":"auditor","user":"42"}`, data)
+	})
+
+	suite.T().Run("should keep cached responses apart per session", func(t *testing.T) {
+		server, requests := newHasuraServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithCache(true, time.Minute))
+		assert.NoError(err)
+		for _, role := range []string{"user", "manager", "user", "manager"} {
+			data, err := b.WithHasuraSession(HasuraSession{Role: role}).Query(`query { me { id } }`, nil, nil)
+			assert.NoError(err)
+			assert.Equal(`{"role":"`+role+`","user":""}`, data)
+		}
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+
+		// Batches look up the same entries
+		results, err := b.WithHasuraSession(HasuraSession{Role: "manager"}).Batch(context.Background(), []BatchItem{{Query: `query { me { id } }`}}, nil)
+		assert.NoError(err)
+		assert.NoError(results[0].Err)
+		assert.JSONEq(`{"role":"manager","user":""}`, string(results[0].Data))
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+}
+
+func TestHasuraSessionHeaders(t *testing.T) {
+	got := HasuraSession{
+		Role:        "user",
+		UserID:      "42",
+		AdminSecret: "s3cret",
+		Vars:        map[string]string{"Org-Id": "7", "x-hasura-team": "blue", "empty": ""},
+	}.Headers()
+	want := map[string]string{
+		"x-hasura-role":         "user",
+		"x-hasura-user-id":      "42",
+		"x-hasura-admin-secret": "s3cret",
+		"x-hasura-org-id":       "7",
+		"x-hasura-team":         "blue",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Headers() = %v, want %v", got, want)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("Headers()[%q] = %q, want %q", name, got[name], value)
+		}
+	}
+	if len((HasuraSession{}).Headers()) != 0 {
+		t.Error("Headers() of an empty session should be empty")
+	}
+}
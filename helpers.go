@@ -4,6 +4,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/goccy/go-json"
 	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
@@ -22,6 +25,63 @@ func calculateHash(query *Query) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
+// headersKey returns a stable fingerprint of a header set, so work sharing the same
+// credentials can be grouped together. Header names are compared case-insensitively.
+func headersKey(headers map[string]interface{}) string {
+	if len(headers) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(headers))
+	normalised := make(map[string]string, len(headers))
+	for k, v := range headers {
+		lower := strings.ToLower(k)
+		keys = append(keys, lower)
+		normalised[lower] = fmt.Sprint(v)
+	}
+	sort.Strings(keys)
+
+	hash := fnv.New64a()
+	for _, k := range keys {
+		hash.Write([]byte(k))
+		hash.Write([]byte{0})
+		hash.Write([]byte(normalised[k]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// mergeHeaders returns a copy of headers with extra added. Headers already set win, compared
+// case-insensitively.
+func mergeHeaders(headers map[string]interface{}, extra map[string]string) map[string]interface{} {
+	merged := make(map[string]interface{}, len(headers)+len(extra))
+	set := make(map[string]bool, len(headers))
+	for key, value := range headers {
+		merged[key] = value
+		set[strings.ToLower(key)] = true
+	}
+	for key, value := range extra {
+		if !set[strings.ToLower(key)] {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// inflightKey identifies queries that can share one request: the same operation, sent with
+// the same headers and expecting the same kind of result
+func inflightKey(query *Query, headers map[string]interface{}, allowPartial bool) string {
+	return fmt.Sprintf("%s:%s:%t", calculateHash(query), headersKey(headers), allowPartial)
+}
+
+// cacheTTL is how long responses are cached for. Clients not built by NewConnection get the default.
+func (b *BaseClient) cacheTTL() time.Duration {
+	if b.cache_ttl <= 0 {
+		return 5 * time.Second
+	}
+	return b.cache_ttl
+}
+
 func (b *BaseClient) cacheLookup(hash string) []byte {
 	obj, _ := b.cache.Get(hash)
 	return obj
@@ -52,3 +112,18 @@ func (b *BaseClient) decodeResponse(response []byte) (any, error) {
 		return nil, fmt.Errorf("can't decode response - unknown response type specified")
 	}
 }
+
+// decodeResponseInto unmarshals the response data into dst, bypassing the configured output type
+func (b *BaseClient) decodeResponseInto(response []byte, dst any) error {
+	if dst == nil {
+		return fmt.Errorf("can't decode response - destination is nil")
+	}
+	if err := json.Unmarshal(response, dst); err != nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Can't decode response into destination",
+			Pairs:   map[string]interface{}{"error": err.Error(), "destination": fmt.Sprintf("%T", dst)},
+		})
+		return fmt.Errorf("can't decode response into %T: %w", dst, err)
+	}
+	return nil
+}
@@ -167,3 +167,46 @@ func (suite *Tests) TestBaseClient_decodeResponse_errors() {
 		assert.Contains(err.Error(), "unknown response type")
 	})
 }
+
+func (suite *Tests) TestBaseClient_decodeResponseInto() {
+	type viewer struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+
+	suite.T().Run("should decode into struct", func(t *testing.T) {
+		client := CreateTestClient()
+
+		var got viewer
+		err := client.decodeResponseInto([]byte(`{"viewer":{"login":"lukaszraczylo"}}`), &got)
+		assert.NoError(err)
+		assert.Equal("lukaszraczylo", got.Viewer.Login)
+	})
+
+	suite.T().Run("should ignore configured output type", func(t *testing.T) {
+		client := CreateTestClient()
+		client.SetOutput("byte")
+
+		var got viewer
+		err := client.decodeResponseInto([]byte(`{"viewer":{"login":"lukaszraczylo"}}`), &got)
+		assert.NoError(err)
+		assert.Equal("lukaszraczylo", got.Viewer.Login)
+	})
+
+	suite.T().Run("should fail on nil destination", func(t *testing.T) {
+		client := CreateTestClient()
+
+		err := client.decodeResponseInto([]byte(`{}`), nil)
+		assert.Error(err)
+		assert.Contains(err.Error(), "destination is nil")
+	})
+
+	suite.T().Run("should fail on mismatched types", func(t *testing.T) {
+		client := CreateTestClient()
+
+		var got viewer
+		err := client.decodeResponseInto([]byte(`{"viewer":{"login":42}}`), &got)
+		assert.Error(err)
+	})
+}
@@ -2,14 +2,24 @@ package gql
 
 import (
 	"crypto/tls"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/goccy/go-json"
 	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
 	"golang.org/x/net/http2"
 )
 
+// tlsClientConfig returns the TLS settings shared by the HTTP/2 transport and subscription sockets
+func (b *BaseClient) tlsClientConfig() *tls.Config {
+	return &tls.Config{
+		InsecureSkipVerify: true, // TODO: Make this configurable via environment variable
+	}
+}
+
 func (b *BaseClient) createHttpClient() (http_client *http.Client) {
 	if strings.HasPrefix(b.endpoint, "http://") {
 		// Use HTTP/1.1 for http:// endpoints
@@ -43,13 +53,9 @@ func (b *BaseClient) createHttpClient() (http_client *http.Client) {
 		})
 	} else if strings.HasPrefix(b.endpoint, "https://") {
 		// Use HTTP/2 for https:// endpoints
-		tlsClientConfig := &tls.Config{
-			InsecureSkipVerify: true, // TODO: Make this configurable via environment variable
-		}
-
 		http2Transport := &http2.Transport{
 			AllowHTTP:          true,
-			TLSClientConfig:    tlsClientConfig,
+			TLSClientConfig:    b.tlsClientConfig(),
 			ReadIdleTimeout:    30 * time.Second, // Close idle connections after 30s
 			PingTimeout:        10 * time.Second, // Detect dead connections with PING
 			WriteByteTimeout:   10 * time.Second, // Timeout for write operations
@@ -80,3 +86,71 @@ func (b *BaseClient) createHttpClient() (http_client *http.Client) {
 
 	return http_client
 }
+
+// streamingClient shares the regular transport - and with it the HTTP/2 connections the
+// queries use - but drops the overall timeout, which would cut long-lived streams
+func (b *BaseClient) streamingClient() (*http.Client, error) {
+	if b.client == nil {
+		return nil, fmt.Errorf("HTTP client not initialized")
+	}
+	client := *b.client
+	client.Timeout = 0
+	return &client, nil
+}
+
+// httpStatusError is returned when the server answers with a non-2xx status. GraphQL errors
+// from the response body can be retrieved with errors.As.
+type httpStatusError struct {
+	status string
+	url    string
+	errors Errors
+	code   int
+}
+
+func (e *httpStatusError) Error() string {
+	msg := fmt.Sprintf("HTTP error - status code: %s", e.status)
+	if e.url != "" {
+		msg += " for " + e.url
+	}
+	if len(e.errors) > 0 {
+		msg += ": " + e.errors.Error()
+	}
+	return msg
+}
+
+func (e *httpStatusError) Unwrap() error {
+	if len(e.errors) == 0 {
+		return nil
+	}
+	return e.errors
+}
+
+// permanent reports whether repeating the request can't help
+func (e *httpStatusError) permanent() bool {
+	return e.code >= 400 && e.code < 500 && e.code != http.StatusRequestTimeout && e.code != http.StatusTooManyRequests
+}
+
+// newHTTPStatusError reads the GraphQL errors many servers put in the body of 4xx responses
+func newHTTPStatusError(response *http.Response) *httpStatusError {
+	statusErr := &httpStatusError{status: response.Status, code: response.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	var result queryResults
+	if json.Unmarshal(body, &result) == nil {
+		statusErr.errors = result.Errors
+	}
+	return statusErr
+}
+
+// doStreamingRequest sends the request through the transport middleware and turns any non-2xx
+// answer into an httpStatusError
+func (b *BaseClient) doStreamingRequest(client *http.Client, request *http.Request) (*http.Response, error) {
+	response, err := b.transport(client.Do)(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices {
+		return response, nil
+	}
+	defer response.Body.Close()
+	return nil, newHTTPStatusError(response)
+}
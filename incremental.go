@@ -0,0 +1,439 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// incrementalAccept asks for multipart incremental delivery, falling back to a plain JSON response
+const incrementalAccept = "multipart/mixed; deferSpec=20220824, application/json"
+
+var errIncrementalUnfinished = errors.New("incremental response ended before the final payload")
+
+// IncrementalPatch is a single deferred fragment (Data) or a batch of streamed list items (Items)
+// together with the location in the result it belongs to
+type IncrementalPatch struct {
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+	Data       json.RawMessage        `json:"data,omitempty"`
+	Label      string                 `json:"label,omitempty"`
+	Items      []json.RawMessage      `json:"items,omitempty"`
+	Path       []interface{}          `json:"path"`
+	Errors     Errors                 `json:"errors,omitempty"`
+}
+
+// IncrementalResult is one part of an incrementally delivered response. Data always holds
+// everything received so far merged into one document, so each part can be rendered as-is.
+type IncrementalResult struct {
+	Err        error // Set on the last part when the response broke off; Data keeps what arrived before
+	Extensions map[string]interface{}
+	Data       json.RawMessage
+	Patches    []IncrementalPatch // Patches carried by this part, empty for the initial payload
+	Errors     Errors             // Errors carried by this part, including those of its patches
+	HasNext    bool
+}
+
+// incrementalPayload covers both the initial payload and subsequent ones, in the current
+// "incremental" format as well as the older one with a single patch at the top level
+type incrementalPayload struct {
+	Extensions  map[string]interface{} `json:"extensions"`
+	HasNext     *bool                  `json:"hasNext"`
+	Data        json.RawMessage        `json:"data"`
+	Label       string                 `json:"label"`
+	Incremental []IncrementalPatch     `json:"incremental"`
+	Items       []json.RawMessage      `json:"items"`
+	Path        []interface{}          `json:"path"`
+	Errors      Errors                 `json:"errors"`
+}
+
+func (p *incrementalPayload) empty() bool {
+	return p.HasNext == nil && len(p.Data) == 0 && p.Incremental == nil && p.Path == nil && p.Errors == nil
+}
+
+// QueryIncremental executes a query using @defer or @stream and yields the initial payload
+// followed by every incremental part as the server sends them. Servers that don't support
+// incremental delivery answer with a single result. The channel is closed after the last part;
+// cancel ctx to abandon the response early. Results are neither cached nor retried.
+func (b *BaseClient) QueryIncremental(ctx context.Context, query string, variables map[string]interface{}, headers map[string]interface{}) (<-chan IncrementalResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	headers, err := b.authHeaders(ctx, b.sessionHeaders(ctx, headers))
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, cleanedVariables := processFlags(variables, headers)
+	req := newRequest(query, "", cleanedVariables, headers)
+	if err := b.validateOperation(req, cleanedVariables); err != nil {
+		return nil, err
+	}
+	doc, _ := req.parse()
+	compiledQuery := b.compileQuery(query, cleanedVariables, req.OperationName, doc)
+	if compiledQuery == nil || compiledQuery.JsonQuery == nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Can't compile query",
+			Pairs:   map[string]interface{}{"error": "query is empty"},
+		})
+		return nil, fmt.Errorf("can't compile query")
+	}
+
+	client, err := b.streamingClient()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint, bytes.NewReader(compiledQuery.JsonQuery))
+	if err != nil {
+		return nil, fmt.Errorf("can't create HTTP request: %w", err)
+	}
+	for key, value := range headers {
+		request.Header.Set(key, fmt.Sprint(value))
+	}
+	if request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	request.Header.Set("Accept", incrementalAccept)
+	request.Header.Set("Accept-Encoding", "identity")
+
+	response, err := b.doStreamingRequest(client, request)
+	if err != nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Error executing query",
+			Pairs:   map[string]interface{}{"error": err.Error()},
+		})
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+
+	parts := make(chan IncrementalResult, subscriptionBufferSize)
+	go b.readIncremental(ctx, response, parts)
+	return parts, nil
+}
+
+// QueryIncrementalMerged executes a query using @defer or @stream, waits for the last part and
+// returns the merged result. Like QueryPartial, GraphQL errors are returned alongside the data.
+func (b *BaseClient) QueryIncrementalMerged(ctx context.Context, query string, variables map[string]interface{}, headers map[string]interface{}) (*QueryResult, error) {
+	parts, err := b.QueryIncremental(ctx, query, variables, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryResult{}
+	for part := range parts {
+		if part.Err != nil {
+			return nil, part.Err
+		}
+		result.Data = part.Data
+		result.Errors = append(result.Errors, part.Errors...)
+		for key, value := range part.Extensions {
+			if result.Extensions == nil {
+				result.Extensions = make(map[string]interface{})
+			}
+			result.Extensions[key] = value
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *BaseClient) readIncremental(ctx context.Context, response *http.Response, parts chan<- IncrementalResult) {
+	defer close(parts)
+	defer response.Body.Close()
+
+	send := func(part IncrementalResult) bool {
+		select {
+		case parts <- part:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	merger := &incrementalMerger{}
+	fail := func(err error) {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Incremental response failed",
+			Pairs:   map[string]interface{}{"error": err.Error()},
+		})
+		send(IncrementalResult{Data: merger.encoded, Err: err})
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType != "multipart/mixed" {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			fail(fmt.Errorf("can't read response: %w", err))
+			return
+		}
+		var payload incrementalPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			fail(fmt.Errorf("can't decode response: %w", err))
+			return
+		}
+		payload.HasNext = nil
+		part, err := merger.apply(&payload)
+		if err != nil {
+			fail(err)
+			return
+		}
+		send(part)
+		return
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		boundary = "-"
+	}
+	reader := multipart.NewReader(response.Body, boundary)
+	for {
+		section, err := reader.NextPart()
+		if err == io.EOF {
+			fail(errIncrementalUnfinished)
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(fmt.Errorf("can't read incremental part: %w", err))
+			}
+			return
+		}
+
+		body, err := io.ReadAll(section)
+		if err != nil {
+			if ctx.Err() == nil {
+				fail(fmt.Errorf("can't read incremental part: %w", err))
+			}
+			return
+		}
+		var payload incrementalPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			fail(fmt.Errorf("can't decode incremental part: %w", err))
+			return
+		}
+		// Some servers send empty parts as heartbeats
+		if payload.empty() {
+			continue
+		}
+
+		part, err := merger.apply(&payload)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if !send(part) || !part.HasNext {
+			return
+		}
+	}
+}
+
+// incrementalMerger folds the incremental parts into the initial payload
+type incrementalMerger struct {
+	data    interface{}
+	encoded json.RawMessage
+	started bool
+}
+
+func (m *incrementalMerger) apply(payload *incrementalPayload) (IncrementalResult, error) {
+	part := IncrementalResult{
+		Extensions: payload.Extensions,
+		HasNext:    payload.HasNext != nil && *payload.HasNext,
+	}
+
+	patches := payload.Incremental
+	switch {
+	case !m.started:
+		m.started = true
+		if !isNullJSON(payload.Data) {
+			data, err := decodeIncrementalJSON(payload.Data)
+			if err != nil {
+				return part, fmt.Errorf("can't decode initial payload: %w", err)
+			}
+			m.data = data
+		}
+		part.Errors = payload.Errors
+	case payload.Path != nil:
+		// Pre-2022 format: the patch sits at the top level and the errors belong to it
+		patches = []IncrementalPatch{{
+			Extensions: payload.Extensions,
+			Data:       payload.Data,
+			Label:      payload.Label,
+			Items:      payload.Items,
+			Path:       payload.Path,
+			Errors:     payload.Errors,
+		}}
+	default:
+		part.Errors = payload.Errors
+	}
+
+	for _, patch := range patches {
+		if err := m.merge(patch); err != nil {
+			return part, err
+		}
+		part.Errors = append(part.Errors, patch.Errors...)
+	}
+	part.Patches = patches
+
+	encoded, err := json.Marshal(m.data)
+	if err != nil {
+		return part, fmt.Errorf("can't encode merged result: %w", err)
+	}
+	m.encoded = encoded
+	part.Data = encoded
+	return part, nil
+}
+
+// merge applies a deferred fragment or streamed items at the patch path
+func (m *incrementalMerger) merge(patch IncrementalPatch) error {
+	if m.data == nil {
+		m.data = map[string]interface{}{}
+	}
+
+	if patch.Items != nil {
+		if len(patch.Path) == 0 {
+			return fmt.Errorf("streamed items without a list index in path")
+		}
+		index, ok := pathIndex(patch.Path[len(patch.Path)-1])
+		if !ok {
+			return fmt.Errorf("streamed items path %v doesn't end with a list index", patch.Path)
+		}
+		listPath := patch.Path[:len(patch.Path)-1]
+		current, err := resolvePath(m.data, listPath)
+		if err != nil {
+			return err
+		}
+		list, _ := current.([]interface{})
+		for i, raw := range patch.Items {
+			item, err := decodeIncrementalJSON(raw)
+			if err != nil {
+				return fmt.Errorf("can't decode streamed item: %w", err)
+			}
+			if position := index + i; position < len(list) {
+				list[position] = item
+			} else {
+				list = append(list, item)
+			}
+		}
+		return m.set(listPath, list)
+	}
+
+	if isNullJSON(patch.Data) {
+		return nil
+	}
+	fragment, err := decodeIncrementalJSON(patch.Data)
+	if err != nil {
+		return fmt.Errorf("can't decode deferred fragment: %w", err)
+	}
+	fields, ok := fragment.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("deferred fragment at %v is not an object", patch.Path)
+	}
+	current, err := resolvePath(m.data, patch.Path)
+	if err != nil {
+		return err
+	}
+	target, ok := current.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("deferred fragment target at %v is not an object", patch.Path)
+	}
+	mergeObjects(target, fields)
+	return nil
+}
+
+// set replaces the value at path, which is needed when appending grows a list
+func (m *incrementalMerger) set(path []interface{}, value interface{}) error {
+	if len(path) == 0 {
+		m.data = value
+		return nil
+	}
+	parent, err := resolvePath(m.data, path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		key, ok := path[len(path)-1].(string)
+		if !ok {
+			return fmt.Errorf("invalid path %v", path)
+		}
+		container[key] = value
+	case []interface{}:
+		index, ok := pathIndex(path[len(path)-1])
+		if !ok || index >= len(container) {
+			return fmt.Errorf("invalid path %v", path)
+		}
+		container[index] = value
+	default:
+		return fmt.Errorf("invalid path %v", path)
+	}
+	return nil
+}
+
+func resolvePath(root interface{}, path []interface{}) (interface{}, error) {
+	current := root
+	for _, segment := range path {
+		switch container := current.(type) {
+		case map[string]interface{}:
+			key, ok := segment.(string)
+			if !ok {
+				return nil, fmt.Errorf("path %v doesn't match the result", path)
+			}
+			current = container[key]
+		case []interface{}:
+			index, ok := pathIndex(segment)
+			if !ok || index >= len(container) {
+				return nil, fmt.Errorf("path %v doesn't match the result", path)
+			}
+			current = container[index]
+		default:
+			return nil, fmt.Errorf("path %v doesn't match the result", path)
+		}
+	}
+	return current, nil
+}
+
+func pathIndex(segment interface{}) (int, bool) {
+	switch v := segment.(type) {
+	case float64:
+		return int(v), v >= 0
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil && i >= 0
+	case int:
+		return v, v >= 0
+	}
+	return 0, false
+}
+
+// mergeObjects deep-merges src into dst, so fragments extend objects delivered earlier
+func mergeObjects(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if srcObject, ok := value.(map[string]interface{}); ok {
+			if dstObject, ok := dst[key].(map[string]interface{}); ok {
+				mergeObjects(dstObject, srcObject)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// decodeIncrementalJSON keeps numbers as json.Number so merging doesn't lose precision
+func decodeIncrementalJSON(raw json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,174 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newIncrementalServer answers with the given parts as multipart/mixed, flushing after each one
+func newIncrementalServer(parts ...string) (*httptest.Server, chan string) {
+	accepts := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case accepts <- r.Header.Get("Accept"):
+		default:
+		}
+		writer := multipart.NewWriter(w)
+		writer.SetBoundary("-")
+		w.Header().Set("Content-Type", `multipart/mixed; boundary="-"; deferSpec=20220824`)
+		w.WriteHeader(http.StatusOK)
+		for _, part := range parts {
+			section, _ := writer.CreatePart(map[string][]string{"Content-Type": {"application/json; charset=utf-8"}})
+			section.Write([]byte(part))
+			w.(http.Flusher).Flush()
+		}
+		writer.Close()
+	}))
+	return server, accepts
+}
+
+func newIncrementalTestClient(server *httptest.Server) *BaseClient {
+	b := NewConnection()
+	b.SetEndpoint(server.URL)
+	b.SetHTTPClient(server.Client())
+	return b
+}
+
+const incrementalQuery = `query { user { id ... @defer { name } friends @stream(initialCount: 1) { id } } }`
+
+func (suite *Tests) TestBaseClient_QueryIncremental() {
+	suite.T().Run("should yield initial payload and merged patches", func(t *testing.T) {
+		server, accepts := newIncrementalServer(
+			`{"data":{"user":{"id":1,"friends":[{"id":2}]}},"hasNext":true}`,
+			`{"incremental":[{"data":{"name":"Alice"},"path":["user"],"label":"profile"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":3},{"id":4}],"path":["user","friends",1]}],"hasNext":false}`,
+		)
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		parts, err := b.QueryIncremental(context.Background(), incrementalQuery, nil, nil)
+		assert.NoError(err)
+
+		var received []IncrementalResult
+		for part := range parts {
+			assert.NoError(part.Err)
+			received = append(received, part)
+		}
+		assert.Len(received, 3)
+		assert.Contains(<-accepts, "multipart/mixed")
+
+		assert.JSONEq(`{"user":{"id":1,"friends":[{"id":2}]}}`, string(received[0].Data))
+		assert.Empty(received[0].Patches)
+		assert.True(received[0].HasNext)
+
+		assert.JSONEq(`{"user":{"id":1,"name":"Alice","friends":[{"id":2}]}}`, string(received[1].Data))
+		assert.Equal("profile", received[1].Patches[0].Label)
+		assert.Equal([]interface{}{"user"}, received[1].Patches[0].Path)
+
+		assert.JSONEq(`{"user":{"id":1,"name":"Alice","friends":[{"id":2},{"id":3},{"id":4}]}}`, string(received[2].Data))
+		assert.False(received[2].HasNext)
+	})
+
+	suite.T().Run("should support patches at the top level", func(t *testing.T) {
+		server, _ := newIncrementalServer(
+			`{"data":{"user":{"id":1}},"hasNext":true}`,
+			`{"data":{"name":"Alice"},"path":["user"],"errors":[{"message":"avatar unavailable"}],"hasNext":false}`,
+		)
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		result, err := b.QueryIncrementalMerged(context.Background(), incrementalQuery, nil, nil)
+		assert.NoError(err)
+		assert.JSONEq(`{"user":{"id":1,"name":"Alice"}}`, string(result.Data))
+		assert.Equal("avatar unavailable", result.Errors[0].Message)
+	})
+
+	suite.T().Run("should skip heartbeat parts and keep number precision", func(t *testing.T) {
+		server, _ := newIncrementalServer(
+			`{"data":{"counter":{"value":12345678901234567890}},"hasNext":true}`,
+			`{}`,
+			`{"incremental":[{"data":{"total":0.1},"path":["counter"]}],"hasNext":false}`,
+		)
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		result, err := b.QueryIncrementalMerged(context.Background(), incrementalQuery, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"counter":{"total":0.1,"value":12345678901234567890}}`, string(result.Data))
+	})
+
+	suite.T().Run("should accept a plain JSON response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"user":{"id":1,"name":"Alice"}}}`))
+		}))
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		parts, err := b.QueryIncremental(context.Background(), incrementalQuery, nil, nil)
+		assert.NoError(err)
+
+		part := <-parts
+		assert.JSONEq(`{"user":{"id":1,"name":"Alice"}}`, string(part.Data))
+		assert.False(part.HasNext)
+		_, ok := <-parts
+		assert.False(ok)
+	})
+
+	suite.T().Run("should report a response that ends early", func(t *testing.T) {
+		server, _ := newIncrementalServer(`{"data":{"user":{"id":1}},"hasNext":true}`)
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		parts, err := b.QueryIncremental(context.Background(), incrementalQuery, nil, nil)
+		assert.NoError(err)
+
+		<-parts
+		last := <-parts
+		assert.ErrorIs(last.Err, errIncrementalUnfinished)
+		assert.JSONEq(`{"user":{"id":1}}`, string(last.Data))
+
+		_, err = b.QueryIncrementalMerged(context.Background(), incrementalQuery, nil, nil)
+		assert.ErrorIs(err, errIncrementalUnfinished)
+	})
+
+	suite.T().Run("should reject patches that don't fit the result", func(t *testing.T) {
+		server, _ := newIncrementalServer(
+			`{"data":{"user":null},"hasNext":true}`,
+			`{"incremental":[{"data":{"name":"Alice"},"path":["user"]}],"hasNext":false}`,
+		)
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		_, err := b.QueryIncrementalMerged(context.Background(), incrementalQuery, nil, nil)
+		assert.Error(err)
+	})
+
+	suite.T().Run("should return GraphQL errors of failed requests", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Unknown directive \"@defer\"","extensions":{"code":"validation-failed"}}]}`))
+		}))
+		defer server.Close()
+
+		b := newIncrementalTestClient(server)
+		_, err := b.QueryIncremental(context.Background(), incrementalQuery, nil, nil)
+		assert.Error(err)
+
+		var gqlErrs Errors
+		assert.True(errors.As(err, &gqlErrs))
+		assert.True(gqlErrs.HasCode("validation-failed"))
+	})
+
+	suite.T().Run("should fail on cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := CreateTestClient().QueryIncremental(ctx, incrementalQuery, nil, nil)
+		assert.ErrorIs(err, context.Canceled)
+	})
+}
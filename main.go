@@ -5,7 +5,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/gookit/goutil/envutil"
 	cache "github.com/lukaszraczylo/go-simple-graphql/cache"
 	logging "github.com/lukaszraczylo/go-simple-graphql/logging"
 )
@@ -26,44 +25,44 @@ func parseRetryPatterns(patterns string) []string {
 	return result
 }
 
+// NewConnection builds a client configured from the environment. Invalid values are logged
+// and the defaults used instead; use New with WithEnv to have them returned as an error.
 func NewConnection() (b *BaseClient) {
-	// Read LOG_LEVEL environment variable
-	logLevelStr := envutil.Getenv("LOG_LEVEL", "info")
-	logLevel := logging.GetLogLevel(logLevelStr)
-
-	// Create logger and set the log level from environment
-	logger := logging.New()
-	logger.SetMinLogLevel(logLevel)
+	b = newBaseClient()
+	withEnv("", false)(b)
+	b.start()
+	return b
+}
 
-	// Log the LOG_LEVEL configuration for validation
-	logger.Info(&logging.LogMessage{
-		Message: "Logger initialized with LOG_LEVEL configuration",
-		Pairs: map[string]interface{}{
-			"LOG_LEVEL_env_var": logLevelStr,
-			"parsed_log_level":  logLevel,
-			"level_name":        logging.LevelNames[logLevel],
-			"default_min_level": logging.LEVEL_INFO,
-		},
-	})
+// newBaseClient returns a client with the default settings, not yet started
+func newBaseClient() *BaseClient {
+	return &BaseClient{
+		endpoint:               "https://api.github.com/graphql",
+		responseType:           "string",
+		Logger:                 logging.New(),
+		cache_ttl:              5 * time.Second,
+		retries_delay:          250 * time.Millisecond,
+		retries_number:         3,
+		retries_patterns:       parseRetryPatterns("postgres,connection,timeout,transaction,could not,temporarily unavailable,deadlock"),
+		minify_queries:         true, // Default: enabled for production efficiency
+		auto_batch_size:        20,
+		pool_size:              5,
+		pool_warmup_query:      "query{__typename}",
+		pool_health_interval:   30 * time.Second,
+		pool_stop:              make(chan bool, 1),
+		subscription_keepalive: 15 * time.Second,
+		subscription_protocol:  SubscriptionProtocolAuto,
+		subscription_transport: SubscriptionTransportWebSocket,
+		clientState:            &clientState{},
+	}
+}
 
-	b = &BaseClient{
-		endpoint:             envutil.Getenv("GRAPHQL_ENDPOINT", "https://api.github.com/graphql"),
-		responseType:         envutil.Getenv("GRAPHQL_OUTPUT", "string"),
-		Logger:               logger,
-		cache:                cache.New(time.Duration(envutil.GetInt("GRAPHQL_CACHE_TTL", 5)) * time.Second),
-		cache_global:         envutil.GetBool("GRAPHQL_CACHE_ENABLED", false),
-		retries_enable:       envutil.GetBool("GRAPHQL_RETRIES_ENABLE", false),
-		retries_delay:        time.Duration(envutil.GetInt("GRAPHQL_RETRIES_DELAY", 250) * int(time.Millisecond)),
-		retries_number:       envutil.GetInt("GRAPHQL_RETRIES_NUMBER", 3),
-		retries_patterns:     parseRetryPatterns(envutil.Getenv("GRAPHQL_RETRIES_PATTERNS", "postgres,connection,timeout,transaction,could not,temporarily unavailable,deadlock")),
-		minify_queries:       envutil.GetBool("GRAPHQL_MINIFY_QUERIES", true), // Default: enabled for production efficiency
-		pool_warmup_enabled:  envutil.GetBool("GRAPHQL_POOL_WARMUP_ENABLED", false),
-		pool_size:            envutil.GetInt("GRAPHQL_POOL_SIZE", 5),
-		pool_warmup_query:    envutil.Getenv("GRAPHQL_POOL_WARMUP_QUERY", "query{__typename}"),
-		pool_health_interval: time.Duration(envutil.GetInt("GRAPHQL_POOL_HEALTH_INTERVAL", 30)) * time.Second,
-		pool_stop:            make(chan bool, 1),
+// start creates the cache and, unless one was provided, the HTTP client, then warms up the pool
+func (b *BaseClient) start() {
+	b.cache = cache.New(b.cacheTTL())
+	if b.client == nil {
+		b.client = b.createHttpClient()
 	}
-	b.client = b.createHttpClient()
 	b.Logger.Debug(&logging.LogMessage{
 		Message: "Created new GraphQL client connection",
 		Pairs: map[string]interface{}{
@@ -79,8 +78,6 @@ func NewConnection() (b *BaseClient) {
 		b.warmupConnectionPool()
 		b.startPoolHealthMonitor()
 	}
-
-	return b
 }
 
 func (b *BaseClient) SetEndpoint(endpoint string) {
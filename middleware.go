@@ -0,0 +1,158 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Operation types as reported in Request.OperationType
+const (
+	OperationQuery        = "query"
+	OperationMutation     = "mutation"
+	OperationSubscription = "subscription"
+)
+
+// Request is an operation on its way through the middleware chain. Middleware may change
+// the query, variables and headers before passing it on. The operation type decides caching,
+// retries and batching, so middleware replacing the query with another operation sets it too.
+type Request struct {
+	Variables     map[string]interface{}
+	Headers       map[string]interface{}
+	Query         string
+	OperationName string       // Sent as operationName, empty for anonymous operations
+	OperationType string       // OperationQuery, OperationMutation or OperationSubscription, empty when the document doesn't parse
+	AllowPartial  bool         // Set for QueryPartial, where GraphQL errors come back with the data
+	operation     *Operation   // Registry operation the query comes from, already minified and hashed
+	parsed        *parsedQuery // Syntax tree of the query, shared by classification, validation and minification
+}
+
+// parsedQuery is the outcome of parsing a query, kept with the text it belongs to
+type parsedQuery struct {
+	doc   *ast.Document
+	err   error
+	query string
+}
+
+// parse returns the syntax tree of the query. The query is parsed once, and again only when
+// middleware replaced it.
+func (r *Request) parse() (*ast.Document, error) {
+	if r.parsed == nil || r.parsed.query != r.Query {
+		doc, err := ast.Parse(r.Query)
+		r.parsed = &parsedQuery{doc: doc, err: err, query: r.Query}
+	}
+	return r.parsed.doc, r.parsed.err
+}
+
+// Response is the outcome of an operation as seen by the middleware chain
+type Response struct {
+	Result *QueryResult
+	Cached bool // Served from the cache without a request
+}
+
+// Handler runs an operation - the next middleware in the chain, or the client itself
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps the execution of every operation: compilation, the cache lookup, the request
+// with its retries and the handling of the response
+type Middleware func(next Handler) Handler
+
+// RoundTripFunc sends a single HTTP request
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+// TransportMiddleware wraps every HTTP request sent to the endpoint, retries included
+type TransportMiddleware func(next RoundTripFunc) RoundTripFunc
+
+// Use adds middleware wrapping every query and mutation with a single result: Query, Mutate,
+// QueryPartial, QueryInto, QueryOperation, Exec and FetchSchema. Batch, Subscribe and
+// QueryIncremental don't go through it, as they have no single result to hand back; they still
+// send the auth provider's and the Hasura session's headers, and UseTransport middleware sees
+// their HTTP requests. The first one added is the outermost. It is meant to be called while
+// setting the client up, before it's used.
+func (b *BaseClient) Use(mw ...Middleware) {
+	b.middlewares = append(b.middlewares, mw...)
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL middleware added",
+		Pairs:   map[string]interface{}{"middlewares": len(b.middlewares)},
+	})
+}
+
+// UseTransport adds middleware wrapping every HTTP request sent to the endpoint: queries and
+// their retries, batches, incremental responses and SSE subscriptions. Websocket subscriptions
+// connect with a dialer of their own and don't go through it. The first one added is the
+// outermost. It is meant to be called while setting the client up, before it's used.
+func (b *BaseClient) UseTransport(mw ...TransportMiddleware) {
+	b.transport_middlewares = append(b.transport_middlewares, mw...)
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL transport middleware added",
+		Pairs:   map[string]interface{}{"transport_middlewares": len(b.transport_middlewares)},
+	})
+}
+
+// handler returns the middleware chain ending with the Hasura session, authentication and the
+// client's own execution
+func (b *BaseClient) handler() Handler {
+	handler := b.withHasuraSession(b.authenticate(b.executeOperation))
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		handler = b.middlewares[i](handler)
+	}
+	return handler
+}
+
+// roundTripper returns the transport middleware chain ending with the HTTP client
+func (b *BaseClient) roundTripper() RoundTripFunc {
+	return b.transport(b.client.Do)
+}
+
+// transport returns the transport middleware chain ending with next
+func (b *BaseClient) transport(next RoundTripFunc) RoundTripFunc {
+	for i := len(b.transport_middlewares) - 1; i >= 0; i-- {
+		next = b.transport_middlewares[i](next)
+	}
+	return next
+}
+
+// newRequest prepares the operation the document runs - the one named operationName, or the
+// only one when the name is empty
+func newRequest(query, operationName string, variables map[string]interface{}, headers map[string]interface{}) *Request {
+	req := &Request{
+		Query:     query,
+		Variables: variables,
+		Headers:   headers,
+	}
+	doc, _ := req.parse()
+	req.OperationType, req.OperationName = operationInfo(doc, operationName)
+	return req
+}
+
+// execute runs the operation through the middleware chain
+func (b *BaseClient) execute(ctx context.Context, req *Request) (*QueryResult, error) {
+	response, err := b.handler()(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if response == nil || response.Result == nil {
+		return nil, errors.New("middleware returned no result")
+	}
+	return response.Result, nil
+}
+
+// operationInfo classifies the operation the document runs by its syntax tree: the one named
+// operationName, or the only one when the name is empty. Shorthand selection sets are queries,
+// and fragments and comments around the operation don't matter. The type is empty when the
+// document didn't parse (doc is nil) or has no such operation, so it's never mistaken for a
+// query and the server is left to report the problem.
+func operationInfo(doc *ast.Document, operationName string) (operationType, name string) {
+	if doc == nil {
+		return "", operationName
+	}
+	for _, op := range doc.Operations {
+		if (operationName == "" && len(doc.Operations) == 1) || (operationName != "" && op.Name == operationName) {
+			return op.Operation, op.Name
+		}
+	}
+	return "", operationName
+}
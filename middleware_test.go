@@ -0,0 +1,251 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+)
+
+func newMiddlewareTestServer(status ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if int(n) <= len(status) {
+			w.WriteHeader(status[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"viewer":{"login":"` + r.Header.Get("Authorization") + `"}}}`))
+	}))
+	return server, &requests
+}
+
+func (suite *Tests) TestBaseClient_Use() {
+	suite.T().Run("should run middleware in order around the operation", func(t *testing.T) {
+		server, _ := newMiddlewareTestServer()
+		defer server.Close()
+
+		var calls []string
+		var seen *Request
+		var result *Response
+		trace := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return func(ctx context.Context, req *Request) (*Response, error) {
+					calls = append(calls, name+" before")
+					response, err := next(ctx, req)
+					calls = append(calls, name+" after")
+					seen, result = req, response
+					return response, err
+				}
+			}
+		}
+
+		b := newBatchTestClient(server)
+		b.Use(trace("outer"), trace("inner"))
+		_, err := b.Query(`query Viewer($id: ID) { viewer { login } }`, map[string]interface{}{"id": 1}, nil)
+		assert.NoError(err)
+
+		assert.Equal([]string{"outer before", "inner before", "inner after", "outer after"}, calls)
+		assert.Equal("Viewer", seen.OperationName)
+		assert.Equal(OperationQuery, seen.OperationType)
+		assert.Equal(map[string]interface{}{"id": 1}, seen.Variables)
+		assert.JSONEq(`{"viewer":{"login":""}}`, string(result.Result.Data))
+		assert.False(result.Cached)
+	})
+
+	suite.T().Run("should let middleware change the request", func(t *testing.T) {
+		server, _ := newMiddlewareTestServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.Use(func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				req.Headers = map[string]interface{}{"Authorization": "Bearer token"}
+				return next(ctx, req)
+			}
+		})
+		data, err := b.Query(`query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"viewer":{"login":"Bearer token"}}`, data)
+	})
+
+	suite.T().Run("should report cached results and short-circuit", func(t *testing.T) {
+		server, requests := newMiddlewareTestServer()
+		defer server.Close()
+
+		var cached []bool
+		b := newBatchTestClient(server)
+		b.Use(func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				if req.OperationType == OperationMutation {
+					return nil, errors.New("read-only client")
+				}
+				response, err := next(ctx, req)
+				if err == nil {
+					cached = append(cached, response.Cached)
+				}
+				return response, err
+			}
+		})
+
+		variables := map[string]interface{}{"gqlcache": true}
+		_, err := b.Query(`query { viewer { login } }`, variables, nil)
+		assert.NoError(err)
+		_, err = b.Query(`query { viewer { login } }`, variables, nil)
+		assert.NoError(err)
+		assert.Equal([]bool{false, true}, cached)
+
+		_, err = b.MutateContext(context.Background(), `mutation { logout }`, nil, nil)
+		assert.EqualError(err, "read-only client")
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should call transport middleware on every attempt", func(t *testing.T) {
+		server, _ := newMiddlewareTestServer(http.StatusServiceUnavailable)
+		defer server.Close()
+
+		var statuses []int
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetries(3, 0))
+		assert.NoError(err)
+		b.UseTransport(func(next RoundTripFunc) RoundTripFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				response, err := next(r)
+				if err == nil {
+					statuses = append(statuses, response.StatusCode)
+				}
+				return response, err
+			}
+		})
+		_, err = b.Query(`query { viewer { login } }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal([]int{http.StatusServiceUnavailable, http.StatusOK}, statuses)
+	})
+}
+
+func (suite *Tests) TestBaseClient_Use_coverage() {
+	// record counts operations seen by middleware and the Accept header of every HTTP request
+	record := func(b *BaseClient, operations *int32, accepts *[]string, mu *sync.Mutex) {
+		b.Use(func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				atomic.AddInt32(operations, 1)
+				return next(ctx, req)
+			}
+		})
+		b.UseTransport(func(next RoundTripFunc) RoundTripFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				mu.Lock()
+				*accepts = append(*accepts, r.Header.Get("Accept"))
+				mu.Unlock()
+				return next(r)
+			}
+		})
+	}
+
+	suite.T().Run("should send batches through transport middleware only", func(t *testing.T) {
+		server, _ := newBatchServer(func(string) string { return `{"data":{"viewer":{"login":"a"}}}` })
+		defer server.Close()
+
+		var operations int32
+		var accepts []string
+		var mu sync.Mutex
+		b := newBatchTestClient(server)
+		record(b, &operations, &accepts, &mu)
+		_, err := b.Batch(context.Background(), []BatchItem{{Query: `{ viewer { login } }`}, {Query: `{ viewer { id } }`}}, nil)
+		assert.NoError(err)
+		assert.Equal(int32(0), atomic.LoadInt32(&operations))
+		assert.Len(accepts, 1)
+	})
+
+	suite.T().Run("should send incremental responses through transport middleware only", func(t *testing.T) {
+		server, _ := newIncrementalServer(`{"data":{"user":{"id":1}},"hasNext":false}`)
+		defer server.Close()
+
+		var operations int32
+		var accepts []string
+		var mu sync.Mutex
+		b := newIncrementalTestClient(server)
+		record(b, &operations, &accepts, &mu)
+		_, err := b.QueryIncrementalMerged(context.Background(), incrementalQuery, nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(0), atomic.LoadInt32(&operations))
+		assert.Equal([]string{incrementalAccept}, accepts)
+	})
+
+	suite.T().Run("should send SSE subscriptions through transport middleware only", func(t *testing.T) {
+		server := newSSEServer(1)
+		defer server.Close()
+
+		var operations int32
+		var accepts []string
+		var mu sync.Mutex
+		b := newSSETestClient(server, SubscriptionTransportSSE)
+		record(b, &operations, &accepts, &mu)
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.NoError(err)
+		assert.Equal([]string{`{"counter":1}`}, collectCounters(t, events, 2))
+		assert.Equal(int32(0), atomic.LoadInt32(&operations))
+		mu.Lock()
+		assert.Equal([]string{"text/event-stream"}, accepts)
+		mu.Unlock()
+	})
+}
+
+func TestOperationInfo(t *testing.T) {
+	tests := []struct {
+		query         string
+		selected      string
+		operationType string
+		operationName string
+	}{
+		{`{ viewer { login } }`, "", OperationQuery, ""},
+		{`query { viewer { login } }`, "", OperationQuery, ""},
+		{`query Viewer($id: ID = 1) { viewer { login } }`, "", OperationQuery, "Viewer"},
+		{"# leading comment\nmutation  AddUser { addUser { id } }", "", OperationMutation, "AddUser"},
+		{`subscription OnMessage @live { messages { id } }`, "", OperationSubscription, "OnMessage"},
+		{`fragment User on User { id } query Me { me { ...User } }`, "", OperationQuery, "Me"},
+		{`query Search($f: Filter = {name: "{query"}) { search { id } }`, "", OperationQuery, "Search"},
+		{`fragment User on User { id }`, "", "", ""},
+		{`queryX { viewer { login } }`, "", "", ""},
+		{`query { viewer { login }`, "", "", ""},
+		{`query A { a } mutation B { b }`, "", "", ""},
+		{`query A { a } mutation B { b }`, "B", OperationMutation, "B"},
+		{`query A { a } mutation B { b }`, "C", "", "C"},
+		{`query Mutation { mutation }`, "", OperationQuery, "Mutation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			doc, _ := ast.Parse(tt.query)
+			operationType, operationName := operationInfo(doc, tt.selected)
+			if operationType != tt.operationType || operationName != tt.operationName {
+				t.Errorf("operationInfo(%q) = %q %q, want %q %q", tt.selected, operationType, operationName, tt.operationType, tt.operationName)
+			}
+		})
+	}
+}
+
+func TestRequest_parse(t *testing.T) {
+	req := newRequest(`query A { a }`, "", nil, nil)
+	parsed := req.parsed
+	doc, err := req.parse()
+	if err != nil || doc != parsed.doc || req.parsed != parsed {
+		t.Fatalf("parse() = %v, %v, want the tree newRequest parsed", doc, err)
+	}
+
+	// Middleware replacing the query gets it parsed again
+	req.Query = `mutation B { b }`
+	doc, err = req.parse()
+	if err != nil || doc == parsed.doc || doc.Operations[0].Name != "B" {
+		t.Errorf("parse() after replacing the query = %v, %v, want the tree of B", doc, err)
+	}
+
+	req.Query = `query { a`
+	if doc, err := req.parse(); doc != nil || err == nil {
+		t.Errorf("parse() of a broken query = %v, %v, want an error", doc, err)
+	}
+}
@@ -0,0 +1,254 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Operation is a named operation of a registry, together with the fragments it uses
+type Operation struct {
+	Name         string
+	Type         string // OperationQuery, OperationMutation or OperationSubscription
+	File         string // File the operation is defined in
+	Document     string // The operation followed by its fragments, as written
+	minified     string
+	minifiedHash string // persistedQueryHash of minified
+	documentHash string // persistedQueryHash of Document
+}
+
+// Operations is a registry of named operations, loaded once by LoadOperations and run with Exec
+type Operations struct {
+	operations map[string]*Operation
+	names      []string
+}
+
+// LoadOperations parses the .graphql files of fsys matching glob and indexes their operations
+// by name. Fragments may be defined in any of the files. Every operation is minified and hashed
+// here, once, so running it later skips both. Anonymous operations, duplicate names and unknown
+// fragments are reported as errors.
+func LoadOperations(fsys fs.FS, glob string) (*Operations, error) {
+	paths, err := fs.Glob(fsys, glob)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", glob, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files match %q", glob)
+	}
+
+	var definitions []*sourceOperation
+	fragments := make(map[string]*sourceFragment)
+	defined := make(map[string]string)
+	for _, path := range paths {
+		content, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := ast.Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, fragment := range doc.Fragments {
+			if previous, ok := fragments[fragment.Name]; ok {
+				return nil, fmt.Errorf("%s: fragment %s is already defined in %s", path, fragment.Name, previous.file)
+			}
+			fragments[fragment.Name] = &sourceFragment{fragment, path, string(content[fragment.Offset:fragment.End])}
+		}
+		for _, op := range doc.Operations {
+			if op.Name == "" {
+				return nil, fmt.Errorf("%s:%s: operations need a name to be run by it", path, op.Position)
+			}
+			if previous, ok := defined[op.Name]; ok {
+				return nil, fmt.Errorf("%s: operation %s is already defined in %s", path, op.Name, previous)
+			}
+			defined[op.Name] = path
+			definitions = append(definitions, &sourceOperation{op, path, string(content[op.Offset:op.End])})
+		}
+	}
+	if len(definitions) == 0 {
+		return nil, errors.New("no operations found")
+	}
+
+	registry := &Operations{
+		operations: make(map[string]*Operation, len(definitions)),
+		names:      make([]string, 0, len(definitions)),
+	}
+	for _, definition := range definitions {
+		used, err := usedFragments(definition.SelectionSet, fragments)
+		if err != nil {
+			return nil, fmt.Errorf("%s: operation %s: %w", definition.file, definition.Name, err)
+		}
+		doc := &ast.Document{Operations: []*ast.OperationDefinition{definition.OperationDefinition}}
+		parts := []string{definition.text}
+		for _, fragment := range used {
+			doc.Fragments = append(doc.Fragments, fragment.FragmentDefinition)
+			parts = append(parts, fragment.text)
+		}
+
+		op := &Operation{
+			Name:     definition.Name,
+			Type:     definition.Operation,
+			File:     definition.file,
+			Document: strings.Join(parts, "\n\n"),
+			minified: ast.Print(doc),
+		}
+		op.minifiedHash = persistedQueryHash(op.minified)
+		op.documentHash = persistedQueryHash(op.Document)
+		registry.operations[op.Name] = op
+		registry.names = append(registry.names, op.Name)
+	}
+	return registry, nil
+}
+
+// sourceOperation is an operation definition with the file it comes from and its text
+type sourceOperation struct {
+	*ast.OperationDefinition
+	file string
+	text string
+}
+
+// sourceFragment is a fragment definition with the file it comes from and its text
+type sourceFragment struct {
+	*ast.FragmentDefinition
+	file string
+	text string
+}
+
+// usedFragments returns the fragments a selection set spreads, directly or through other
+// fragments, in the order they're first spread
+func usedFragments(set ast.SelectionSet, fragments map[string]*sourceFragment) ([]*sourceFragment, error) {
+	var used []*sourceFragment
+	seen := make(map[string]bool)
+	var walk func(set ast.SelectionSet) error
+	walk = func(set ast.SelectionSet) error {
+		for _, s := range set {
+			switch s := s.(type) {
+			case *ast.Field:
+				if err := walk(s.SelectionSet); err != nil {
+					return err
+				}
+			case *ast.InlineFragment:
+				if err := walk(s.SelectionSet); err != nil {
+					return err
+				}
+			case *ast.FragmentSpread:
+				if seen[s.Name] {
+					continue
+				}
+				seen[s.Name] = true
+				fragment, ok := fragments[s.Name]
+				if !ok {
+					return fmt.Errorf("unknown fragment %s", s.Name)
+				}
+				used = append(used, fragment)
+				if err := walk(fragment.SelectionSet); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return used, walk(set)
+}
+
+// Lookup returns the operation with the given name
+func (o *Operations) Lookup(name string) (*Operation, bool) {
+	op, ok := o.operations[name]
+	return op, ok
+}
+
+// Names returns the names of the operations, in the order of their files and definitions
+func (o *Operations) Names() []string {
+	return append([]string(nil), o.names...)
+}
+
+// SetOperations sets the registry Exec runs operations from
+func (b *BaseClient) SetOperations(operations *Operations) {
+	b.operations = operations
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL operations updated",
+		Pairs:   map[string]interface{}{"operations": len(operations.names)},
+	})
+}
+
+// WithOperations sets the registry Exec runs operations from, as SetOperations does
+func WithOperations(operations *Operations) Option {
+	return func(b *BaseClient) error {
+		if operations == nil {
+			return errors.New("invalid operations: nil")
+		}
+		b.operations = operations
+		return nil
+	}
+}
+
+// Exec runs the named operation of the client's registry. Caching and batching follow its
+// type; headers come from the auth provider and the Hasura session. Subscriptions are run
+// with Subscribe instead.
+func (b *BaseClient) Exec(ctx context.Context, name string, variables map[string]interface{}) (any, error) {
+	req, err := b.operationRequest(name, variables)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := b.query(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return b.decodeResponse(rv)
+}
+
+// ExecInto runs the named operation of the client's registry and unmarshals its data straight
+// into dst, which must be a pointer
+func (b *BaseClient) ExecInto(ctx context.Context, name string, variables map[string]interface{}, dst any) error {
+	req, err := b.operationRequest(name, variables)
+	if err != nil {
+		return err
+	}
+	rv, err := b.query(ctx, req)
+	if err != nil {
+		return err
+	}
+	return b.decodeResponseInto(rv, dst)
+}
+
+// operationRequest prepares a registry operation, already classified, minified and hashed
+func (b *BaseClient) operationRequest(name string, variables map[string]interface{}) (*Request, error) {
+	if b.operations == nil {
+		return nil, errors.New("no operations registry set, see SetOperations")
+	}
+	op, ok := b.operations.operations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %s", name)
+	}
+	if op.Type == OperationSubscription {
+		return nil, fmt.Errorf("operation %s is a subscription, run it with Subscribe", name)
+	}
+	return &Request{
+		Query:         op.Document,
+		Variables:     variables,
+		OperationName: op.Name,
+		OperationType: op.Type,
+		operation:     op,
+	}, nil
+}
+
+// compileOperation builds the request body of a registry operation without minifying it again
+func (b *BaseClient) compileOperation(op *Operation, variables map[string]interface{}) *Query {
+	q := &Query{
+		Query:         op.Document,
+		Variables:     variables,
+		OperationName: op.Name,
+		OperationType: op.Type,
+		persistedHash: op.documentHash,
+	}
+	if b.minify_queries {
+		q.Query, q.persistedHash = op.minified, op.minifiedHash
+	}
+	q.JsonQuery = b.convertToJSON(q)
+	return q
+}
@@ -0,0 +1,198 @@
+package gql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/goccy/go-json"
+)
+
+var testOperations = fstest.MapFS{
+	"queries/fragments.graphql": {Data: []byte(`
+fragment UserFields on users {
+  id
+  ...Contact
+}
+
+fragment Contact on users { email }
+
+fragment Unused on users { name }
+`)},
+	"queries/users.graphql": {Data: []byte(`
+# Users by id
+query GetUserByID($id: uuid!) {
+  users_by_pk(id: $id) { ...UserFields }
+}
+
+mutation RenameUser($id: uuid!, $name: String!) {
+  update_users_by_pk(pk_columns: {id: $id}, _set: {name: $name}) { id }
+}
+`)},
+	"queries/events.graphql": {Data: []byte(`subscription OnUser { users { ...Contact } }`)},
+	"README.md":              {Data: []byte(`not a query`)},
+}
+
+func TestLoadOperations(t *testing.T) {
+	operations, err := LoadOperations(testOperations, "queries/*.graphql")
+	if err != nil {
+		t.Fatalf("LoadOperations() error = %v", err)
+	}
+	names := operations.Names()
+	if len(names) != 3 || names[0] != "OnUser" || names[1] != "GetUserByID" || names[2] != "RenameUser" {
+		t.Errorf("Names() = %v, want [OnUser GetUserByID RenameUser]", names)
+	}
+
+	op, ok := operations.Lookup("GetUserByID")
+	if !ok {
+		t.Fatal("Lookup(GetUserByID) found nothing")
+	}
+	wantDocument := "query GetUserByID($id: uuid!) {\n  users_by_pk(id: $id) { ...UserFields }\n}\n\n" +
+		"fragment UserFields on users {\n  id\n  ...Contact\n}\n\nfragment Contact on users { email }"
+	if op.Type != OperationQuery || op.File != "queries/users.graphql" || op.Document != wantDocument {
+		t.Errorf("Lookup(GetUserByID) = %s %s %q", op.Type, op.File, op.Document)
+	}
+	wantMinified := "query GetUserByID($id: uuid!){users_by_pk(id: $id){...UserFields}} fragment UserFields on users{id ...Contact} fragment Contact on users{email}"
+	if op.minified != wantMinified || op.minifiedHash != persistedQueryHash(wantMinified) || op.documentHash != persistedQueryHash(wantDocument) {
+		t.Errorf("GetUserByID minified to %q, hashes %s %s", op.minified, op.minifiedHash, op.documentHash)
+	}
+	if _, ok := operations.Lookup("Missing"); ok {
+		t.Error("Lookup(Missing) found an operation")
+	}
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		glob  string
+		want  string
+	}{
+		{"no files", testOperations, "missing/*.graphql", `no files match "missing/*.graphql"`},
+		{"bad pattern", testOperations, "[", `invalid pattern "[": syntax error in pattern`},
+		{"fragments only", testOperations, "queries/fragments.graphql", "no operations found"},
+		{
+			name:  "syntax error",
+			files: fstest.MapFS{"a.graphql": {Data: []byte(`query A { a`)}},
+			want:  "a.graphql: syntax error at 1:12: expected Name, found <EOF>",
+		},
+		{
+			name:  "anonymous operation",
+			files: fstest.MapFS{"a.graphql": {Data: []byte(`{ a }`)}},
+			want:  "a.graphql:1:1: operations need a name to be run by it",
+		},
+		{
+			name: "duplicate operation",
+			files: fstest.MapFS{
+				"a.graphql": {Data: []byte(`query A { a }`)},
+				"b.graphql": {Data: []byte(`query A { b }`)},
+			},
+			want: "b.graphql: operation A is already defined in a.graphql",
+		},
+		{
+			name: "duplicate fragment",
+			files: fstest.MapFS{
+				"a.graphql": {Data: []byte(`fragment F on users { id }`)},
+				"b.graphql": {Data: []byte(`fragment F on users { name }`)},
+			},
+			want: "b.graphql: fragment F is already defined in a.graphql",
+		},
+		{
+			name:  "unknown fragment",
+			files: fstest.MapFS{"a.graphql": {Data: []byte(`query A { a { ...on users { ...Missing } } }`)}},
+			want:  "a.graphql: operation A: unknown fragment Missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			glob := tt.glob
+			if glob == "" {
+				glob = "*.graphql"
+			}
+			if _, err := LoadOperations(tt.files, glob); err == nil || err.Error() != tt.want {
+				t.Errorf("LoadOperations() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func (suite *Tests) TestBaseClient_Exec() {
+	operations, err := LoadOperations(testOperations, "queries/*.graphql")
+	assert.NoError(err)
+
+	var sent []Query
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var operation Query
+		json.Unmarshal(raw, &operation)
+		sent = append(sent, operation)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"users_by_pk":{"id":"1","email":"ada@example.com"}}}`))
+	}))
+	defer server.Close()
+
+	suite.T().Run("should send the minified operation with its fragments", func(t *testing.T) {
+		sent = nil
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithOperations(operations))
+		assert.NoError(err)
+
+		var result struct {
+			User struct {
+				ID    string `json:"id"`
+				Email string `json:"email"`
+			} `json:"users_by_pk"`
+		}
+		assert.NoError(b.ExecInto(context.Background(), "GetUserByID", map[string]interface{}{"id": "1"}, &result))
+		assert.Equal("ada@example.com", result.User.Email)
+
+		_, err = b.Exec(context.Background(), "RenameUser", map[string]interface{}{"id": "1", "name": "Ada"})
+		assert.NoError(err)
+
+		op, _ := operations.Lookup("GetUserByID")
+		assert.Len(sent, 2)
+		assert.Equal(op.minified, sent[0].Query)
+		assert.Equal("GetUserByID", sent[0].OperationName)
+		assert.Equal(map[string]interface{}{"id": "1"}, sent[0].Variables)
+		assert.Equal("RenameUser", sent[1].OperationName)
+	})
+
+	suite.T().Run("should send the document as written without minification", func(t *testing.T) {
+		sent = nil
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithOperations(operations))
+		assert.NoError(err)
+		b.SetQueryMinification(false)
+
+		_, err = b.Exec(context.Background(), "GetUserByID", map[string]interface{}{"id": "1"})
+		assert.NoError(err)
+		op, _ := operations.Lookup("GetUserByID")
+		assert.Len(sent, 1)
+		assert.Equal(op.Document, sent[0].Query)
+	})
+
+	suite.T().Run("should cache queries and not mutations", func(t *testing.T) {
+		sent = nil
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithOperations(operations))
+		assert.NoError(err)
+
+		for i := 0; i < 2; i++ {
+			_, err := b.Exec(context.Background(), "GetUserByID", map[string]interface{}{"id": "1", "gqlcache": true})
+			assert.NoError(err)
+			_, err = b.Exec(context.Background(), "RenameUser", map[string]interface{}{"id": "1", "name": "Ada", "gqlcache": true})
+			assert.NoError(err)
+		}
+		assert.Len(sent, 3)
+	})
+
+	suite.T().Run("should reject operations it can't run", func(t *testing.T) {
+		b := NewConnection()
+		_, err := b.Exec(context.Background(), "GetUserByID", nil)
+		assert.EqualError(err, "no operations registry set, see SetOperations")
+
+		b.SetOperations(operations)
+		_, err = b.Exec(context.Background(), "Missing", nil)
+		assert.EqualError(err, "unknown operation Missing")
+		_, err = b.Exec(context.Background(), "OnUser", nil)
+		assert.EqualError(err, "operation OnUser is a subscription, run it with Subscribe")
+	})
+}
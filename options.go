@@ -0,0 +1,315 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gookit/goutil/envutil"
+	"github.com/gookit/goutil/strutil"
+	logging "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Option configures a client built by New. Options are applied in order, so later ones
+// override what earlier ones - including WithEnv - have set.
+type Option func(*BaseClient) error
+
+// New builds a client configured by opts alone - unlike NewConnection it doesn't read the
+// environment unless WithEnv is passed. Invalid settings are returned as an error.
+func New(opts ...Option) (*BaseClient, error) {
+	b := newBaseClient()
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateEndpoint(b.endpoint); err != nil {
+		return nil, err
+	}
+	b.start()
+	return b, nil
+}
+
+// WithEndpoint sets the GraphQL endpoint, which must be an http:// or https:// URL
+func WithEndpoint(endpoint string) Option {
+	return func(b *BaseClient) error {
+		if err := validateEndpoint(endpoint); err != nil {
+			return err
+		}
+		b.endpoint = endpoint
+		return nil
+	}
+}
+
+// WithCache sets how long query results are cached for and whether every query is cached,
+// not only the ones flagged with gqlcache
+func WithCache(enabled bool, ttl time.Duration) Option {
+	return func(b *BaseClient) error {
+		if ttl <= 0 {
+			return fmt.Errorf("invalid cache TTL %s: must be positive", ttl)
+		}
+		b.cache_global = enabled
+		b.cache_ttl = ttl
+		return nil
+	}
+}
+
+// WithRetries enables retries of every query, making up to attempts attempts with exponential
+// backoff starting at delay. When patterns are given, they replace the GraphQL error patterns
+// that are retried.
+func WithRetries(attempts int, delay time.Duration, patterns ...string) Option {
+	return func(b *BaseClient) error {
+		if attempts < 1 {
+			return fmt.Errorf("invalid number of retries %d: must be at least 1", attempts)
+		}
+		if delay < 0 {
+			return fmt.Errorf("invalid retries delay %s: can't be negative", delay)
+		}
+		b.retries_enable = true
+		b.retries_number = attempts
+		b.retries_delay = delay
+		if len(patterns) > 0 {
+			b.retries_patterns = patterns
+		}
+		return nil
+	}
+}
+
+// WithLogger replaces the client's logger
+func WithLogger(logger *logging.Logger) Option {
+	return func(b *BaseClient) error {
+		if logger == nil {
+			return errors.New("invalid logger: nil")
+		}
+		b.Logger = logger
+		return nil
+	}
+}
+
+// WithHTTPClient uses client for all requests instead of the one built for the endpoint
+func WithHTTPClient(client *http.Client) Option {
+	return func(b *BaseClient) error {
+		if client == nil {
+			return errors.New("invalid HTTP client: nil")
+		}
+		b.client = client
+		return nil
+	}
+}
+
+// WithPool enables the connection pool warmup with size connections, checked every healthInterval
+func WithPool(size int, healthInterval time.Duration) Option {
+	return func(b *BaseClient) error {
+		if size < 1 {
+			return fmt.Errorf("invalid pool size %d: must be at least 1", size)
+		}
+		if healthInterval <= 0 {
+			return fmt.Errorf("invalid pool health interval %s: must be positive", healthInterval)
+		}
+		b.pool_warmup_enabled = true
+		b.pool_size = size
+		b.pool_health_interval = healthInterval
+		return nil
+	}
+}
+
+// WithMiddleware adds operation middleware, as Use does
+func WithMiddleware(mw ...Middleware) Option {
+	return func(b *BaseClient) error {
+		b.middlewares = append(b.middlewares, mw...)
+		return nil
+	}
+}
+
+// WithTransportMiddleware adds middleware wrapping every HTTP attempt, as UseTransport does
+func WithTransportMiddleware(mw ...TransportMiddleware) Option {
+	return func(b *BaseClient) error {
+		b.transport_middlewares = append(b.transport_middlewares, mw...)
+		return nil
+	}
+}
+
+// WithEnv reads the settings NewConnection uses from the environment, with every variable name
+// prefixed by prefix - "BILLING" reads BILLING_GRAPHQL_ENDPOINT, BILLING_LOG_LEVEL and so on.
+// Variables that aren't set keep their current value; invalid ones fail the whole option.
+func WithEnv(prefix string) Option {
+	return withEnv(prefix, true)
+}
+
+// withEnv reads the environment. When not strict, invalid values are logged and ignored,
+// which is what NewConnection has always done.
+func withEnv(prefix string, strict bool) Option {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return func(b *BaseClient) error {
+		env := &envReader{b: b, prefix: prefix, strict: strict}
+
+		if level, ok := env.lookup("LOG_LEVEL"); ok {
+			if !validLogLevel(level) {
+				env.invalid("LOG_LEVEL", level, errors.New("unknown log level"))
+			}
+			b.Logger.SetMinLogLevel(logging.GetLogLevel(level))
+		}
+		if endpoint, ok := env.lookup("GRAPHQL_ENDPOINT"); ok {
+			// NewConnection reports bad endpoints when building the HTTP client
+			if err := validateEndpoint(endpoint); err != nil && strict {
+				env.invalid("GRAPHQL_ENDPOINT", endpoint, err)
+			} else {
+				b.endpoint = endpoint
+			}
+		}
+		env.text("GRAPHQL_OUTPUT", &b.responseType, "byte", "string", "mapstring")
+		env.duration("GRAPHQL_CACHE_TTL", time.Second, 1, &b.cache_ttl)
+		env.boolean("GRAPHQL_CACHE_ENABLED", &b.cache_global)
+		env.boolean("GRAPHQL_RETRIES_ENABLE", &b.retries_enable)
+		env.duration("GRAPHQL_RETRIES_DELAY", time.Millisecond, 0, &b.retries_delay)
+		env.integer("GRAPHQL_RETRIES_NUMBER", 1, &b.retries_number)
+		if patterns, ok := env.lookup("GRAPHQL_RETRIES_PATTERNS"); ok {
+			b.retries_patterns = parseRetryPatterns(patterns)
+		}
+		if headers, ok := env.lookup("GRAPHQL_CACHE_KEY_HEADERS"); ok {
+			b.cache_key_headers = normaliseCacheKeyHeaders(strings.Split(headers, ","))
+		}
+		env.boolean("GRAPHQL_MINIFY_QUERIES", &b.minify_queries)
+		env.boolean("GRAPHQL_PERSISTED_QUERIES", &b.persisted_queries)
+		env.boolean("GRAPHQL_PERSISTED_QUERIES_GET", &b.persisted_queries_get)
+		env.boolean("GRAPHQL_DEDUPLICATE_QUERIES", &b.deduplicate_queries)
+		env.duration("GRAPHQL_AUTO_BATCH_WINDOW", time.Millisecond, 0, &b.auto_batch_window)
+		env.integer("GRAPHQL_AUTO_BATCH_SIZE", 0, &b.auto_batch_size)
+		env.boolean("GRAPHQL_POOL_WARMUP_ENABLED", &b.pool_warmup_enabled)
+		env.integer("GRAPHQL_POOL_SIZE", 1, &b.pool_size)
+		env.text("GRAPHQL_POOL_WARMUP_QUERY", &b.pool_warmup_query)
+		env.duration("GRAPHQL_POOL_HEALTH_INTERVAL", time.Second, 1, &b.pool_health_interval)
+		env.duration("GRAPHQL_SUBSCRIPTION_KEEPALIVE", time.Second, 1, &b.subscription_keepalive)
+		env.text("GRAPHQL_VALIDATION", &b.validation, ValidationOff, ValidationWarn, ValidationStrict)
+		if path, ok := env.lookup("GRAPHQL_SCHEMA_FILE"); ok {
+			if schema, err := LoadSchema(path); err != nil {
+				env.invalid("GRAPHQL_SCHEMA_FILE", path, err)
+			} else {
+				b.schema = schema
+			}
+		}
+		env.text("GRAPHQL_SUBSCRIPTION_PROTOCOL", &b.subscription_protocol,
+			SubscriptionProtocolAuto, SubscriptionProtocolTransportWS, SubscriptionProtocolLegacyWS)
+		env.text("GRAPHQL_SUBSCRIPTION_TRANSPORT", &b.subscription_transport,
+			SubscriptionTransportWebSocket, SubscriptionTransportSSE, SubscriptionTransportSSESingle)
+
+		// Log the LOG_LEVEL configuration for validation
+		logLevelStr := envutil.Getenv(prefix+"LOG_LEVEL", "info")
+		logLevel := logging.GetLogLevel(logLevelStr)
+		b.Logger.Info(&logging.LogMessage{
+			Message: "Logger initialized with LOG_LEVEL configuration",
+			Pairs: map[string]interface{}{
+				"LOG_LEVEL_env_var": logLevelStr,
+				"parsed_log_level":  logLevel,
+				"level_name":        logging.LevelNames[logLevel],
+				"default_min_level": logging.LEVEL_INFO,
+			},
+		})
+		return errors.Join(env.errs...)
+	}
+}
+
+// envReader parses prefixed environment variables into the client's settings. Empty variables
+// count as unset.
+type envReader struct {
+	b      *BaseClient
+	prefix string
+	errs   []error
+	strict bool
+}
+
+func (e *envReader) lookup(name string) (string, bool) {
+	value := envutil.Getenv(e.prefix + name)
+	return value, value != ""
+}
+
+// invalid records a value that can't be used, or only logs it when not strict
+func (e *envReader) invalid(name, value string, err error) {
+	if e.strict {
+		e.errs = append(e.errs, fmt.Errorf("invalid %s%s %q: %w", e.prefix, name, value, err))
+		return
+	}
+	e.b.Logger.Warning(&logging.LogMessage{
+		Message: "Ignoring invalid environment variable",
+		Pairs:   map[string]interface{}{"name": e.prefix + name, "value": value, "error": err.Error()},
+	})
+}
+
+// text reads a string, limited to allowed when any are given
+func (e *envReader) text(name string, dst *string, allowed ...string) {
+	value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	if len(allowed) > 0 && !slices.Contains(allowed, value) {
+		e.invalid(name, value, fmt.Errorf("must be one of %s", strings.Join(allowed, ", ")))
+		return
+	}
+	*dst = value
+}
+
+func (e *envReader) boolean(name string, dst *bool) {
+	value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := strutil.ToBool(value)
+	if err != nil {
+		e.invalid(name, value, err)
+		return
+	}
+	*dst = parsed
+}
+
+// integer reads a whole number no lower than min
+func (e *envReader) integer(name string, min int, dst *int) {
+	value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err == nil && parsed < min {
+		err = fmt.Errorf("must be at least %d", min)
+	}
+	if err != nil {
+		e.invalid(name, value, err)
+		return
+	}
+	*dst = parsed
+}
+
+// duration reads a whole number of units, no lower than min
+func (e *envReader) duration(name string, unit time.Duration, min int, dst *time.Duration) {
+	count := -1
+	e.integer(name, min, &count)
+	if count >= 0 {
+		*dst = time.Duration(count) * unit
+	}
+}
+
+func validateEndpoint(endpoint string) error {
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: must be an http:// or https:// URL", endpoint)
+	}
+	return nil
+}
+
+func validLogLevel(level string) bool {
+	for _, name := range logging.LevelNames {
+		if name == strings.ToLower(level) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package gql
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	logging "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+func (suite *Tests) TestNew() {
+	suite.T().Run("should apply options", func(t *testing.T) {
+		httpClient := &http.Client{}
+		logger := logging.New()
+		b, err := New(
+			WithEndpoint("http://localhost:8080/v1/graphql"),
+			WithCache(true, time.Minute),
+			WithRetries(5, time.Second, "deadlock"),
+			WithLogger(logger),
+			WithHTTPClient(httpClient),
+		)
+		assert.NoError(err)
+		assert.Equal("http://localhost:8080/v1/graphql", b.endpoint)
+		assert.True(b.cache_global)
+		assert.Equal(time.Minute, b.cache_ttl)
+		assert.True(b.retries_enable)
+		assert.Equal(5, b.retries_number)
+		assert.Equal(time.Second, b.retries_delay)
+		assert.Equal([]string{"deadlock"}, b.retries_patterns)
+		assert.Same(logger, b.Logger)
+		assert.Same(httpClient, b.client)
+		assert.NotNil(b.cache)
+	})
+
+	suite.T().Run("should not read the environment by default", func(t *testing.T) {
+		t.Setenv("GRAPHQL_ENDPOINT", "http://env.example.com/graphql")
+		b, err := New()
+		assert.NoError(err)
+		assert.Equal("https://api.github.com/graphql", b.endpoint)
+		assert.NotNil(b.client)
+	})
+
+	suite.T().Run("should read prefixed environment variables", func(t *testing.T) {
+		t.Setenv("BILLING_GRAPHQL_ENDPOINT", "http://billing.example.com/graphql")
+		t.Setenv("BILLING_GRAPHQL_RETRIES_NUMBER", "7")
+		t.Setenv("BILLING_GRAPHQL_CACHE_TTL", "60")
+		t.Setenv("BILLING_LOG_LEVEL", "error")
+		t.Setenv("GRAPHQL_ENDPOINT", "http://other.example.com/graphql")
+
+		billing, err := New(WithEnv("BILLING"))
+		assert.NoError(err)
+		assert.Equal("http://billing.example.com/graphql", billing.endpoint)
+		assert.Equal(7, billing.retries_number)
+		assert.Equal(time.Minute, billing.cache_ttl)
+
+		other, err := New(WithEnv(""))
+		assert.NoError(err)
+		assert.Equal("http://other.example.com/graphql", other.endpoint)
+		assert.Equal(3, other.retries_number)
+	})
+
+	suite.T().Run("should let later options override the environment", func(t *testing.T) {
+		t.Setenv("APP_GRAPHQL_ENDPOINT", "http://env.example.com/graphql")
+		b, err := New(WithEnv("APP_"), WithEndpoint("http://option.example.com/graphql"))
+		assert.NoError(err)
+		assert.Equal("http://option.example.com/graphql", b.endpoint)
+	})
+
+	suite.T().Run("should return invalid environment variables as errors", func(t *testing.T) {
+		t.Setenv("BAD_GRAPHQL_RETRIES_NUMBER", "many")
+		t.Setenv("BAD_GRAPHQL_OUTPUT", "xml")
+		t.Setenv("BAD_GRAPHQL_CACHE_ENABLED", "perhaps")
+		_, err := New(WithEnv("BAD"))
+		assert.ErrorContains(err, "BAD_GRAPHQL_RETRIES_NUMBER")
+		assert.ErrorContains(err, "BAD_GRAPHQL_OUTPUT")
+		assert.ErrorContains(err, "BAD_GRAPHQL_CACHE_ENABLED")
+	})
+
+	suite.T().Run("should keep NewConnection lenient", func(t *testing.T) {
+		t.Setenv("GRAPHQL_RETRIES_NUMBER", "many")
+		b := NewConnection()
+		assert.Equal(3, b.retries_number)
+	})
+
+	suite.T().Run("should reject invalid options", func(t *testing.T) {
+		invalid := map[string]Option{
+			"endpoint":     WithEndpoint("localhost:8080"),
+			"cache ttl":    WithCache(true, 0),
+			"retries":      WithRetries(0, time.Second),
+			"delay":        WithRetries(3, -time.Second),
+			"logger":       WithLogger(nil),
+			"http client":  WithHTTPClient(nil),
+			"pool size":    WithPool(0, time.Second),
+			"pool checks":  WithPool(3, 0),
+			"env endpoint": WithEnv("INVALID"),
+		}
+		t.Setenv("INVALID_GRAPHQL_ENDPOINT", "ftp://example.com")
+		for name, option := range invalid {
+			_, err := New(option)
+			assert.Error(err, name)
+		}
+	})
+}
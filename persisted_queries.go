@@ -0,0 +1,231 @@
+package gql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Errors servers return for Automatic Persisted Queries, matched by message or extensions.code
+const (
+	persistedQueryNotFound     = "PersistedQueryNotFound"
+	persistedQueryNotSupported = "PersistedQueryNotSupported"
+)
+
+// persistedQueryRegistry remembers which endpoints don't support persisted queries at all,
+// and per endpoint whether the server knows a hash (true) or answered PersistedQueryNotFound
+// for it and hasn't accepted its document since (false)
+type persistedQueryRegistry struct {
+	unsupported map[string]bool
+	hashes      map[string]map[string]bool
+	mu          sync.RWMutex
+}
+
+type persistedQueryExtension struct {
+	Sha256Hash string `json:"sha256Hash"`
+	Version    int    `json:"version"`
+}
+
+type persistedQueryRequest struct {
+	Variables     map[string]interface{}             `json:"variables,omitempty"`
+	Extensions    map[string]persistedQueryExtension `json:"extensions"`
+	Query         string                             `json:"query,omitempty"`
+	OperationName string                             `json:"operationName,omitempty"`
+}
+
+// SetPersistedQueries enables Automatic Persisted Queries. Queries are first sent as the
+// sha256 hash of their document only; when the server answers PersistedQueryNotFound, it is sent
+// again along with the full text so the server can register it. Hashes an endpoint is known to
+// be missing go out with their document straight away until the server has accepted it.
+func (b *BaseClient) SetPersistedQueries(enabled bool) {
+	b.persisted_queries = enabled
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL persisted queries setting updated",
+		Pairs:   map[string]interface{}{"persisted_queries": enabled},
+	})
+}
+
+// SetPersistedQueriesGET sends hash-only queries as GET requests so CDNs can cache them.
+// Mutations are always sent as POST.
+func (b *BaseClient) SetPersistedQueriesGET(enabled bool) {
+	b.persisted_queries_get = enabled
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL persisted queries GET setting updated",
+		Pairs:   map[string]interface{}{"persisted_queries_get": enabled},
+	})
+}
+
+func persistedQueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *persistedQueryRegistry) isUnsupported(endpoint string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.unsupported[endpoint]
+}
+
+func (r *persistedQueryRegistry) markUnsupported(endpoint string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.unsupported == nil {
+		r.unsupported = make(map[string]bool)
+	}
+	r.unsupported[endpoint] = true
+}
+
+// isMissing reports whether the endpoint answered PersistedQueryNotFound for the hash and
+// hasn't accepted its document since
+func (r *persistedQueryRegistry) isMissing(endpoint, hash string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	known, seen := r.hashes[endpoint][hash]
+	return seen && !known
+}
+
+// markHash records whether the endpoint knows the hash
+func (r *persistedQueryRegistry) markHash(endpoint, hash string, known bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.hashes == nil {
+		r.hashes = make(map[string]map[string]bool)
+	}
+	if r.hashes[endpoint] == nil {
+		r.hashes[endpoint] = make(map[string]bool)
+	}
+	r.hashes[endpoint][hash] = known
+}
+
+// persistedQueryBody builds the request body carrying the hash, with or without the document
+func (b *BaseClient) persistedQueryBody(query *Query, hash string, withDocument bool) []byte {
+	request := &persistedQueryRequest{
+		Variables:     query.Variables,
+		OperationName: query.OperationName,
+		Extensions: map[string]persistedQueryExtension{
+			"persistedQuery": {Version: 1, Sha256Hash: hash},
+		},
+	}
+	if withDocument {
+		request.Query = query.Query
+	}
+	return b.convertToJSON(request)
+}
+
+// persistedQueryError returns the APQ error code found in a response, if any
+func persistedQueryError(result *QueryResult, err error) string {
+	var errs Errors
+	if result != nil {
+		errs = result.Errors
+	}
+	if err != nil {
+		errors.As(err, &errs)
+	}
+	for _, e := range errs {
+		switch {
+		case e.Message == persistedQueryNotFound || e.Code() == "PERSISTED_QUERY_NOT_FOUND":
+			return persistedQueryNotFound
+		case e.Message == persistedQueryNotSupported || e.Code() == "PERSISTED_QUERY_NOT_SUPPORTED":
+			return persistedQueryNotSupported
+		}
+	}
+	return ""
+}
+
+// executePersisted runs the query using Automatic Persisted Queries. The hash is sent alone
+// first (as GET when enabled), so a document the server or a CDN already has never travels
+// again, not even from a freshly started client. When the server doesn't know the hash, the
+// document is sent along with it, and keeps being sent right away for that endpoint until the
+// server has accepted it. Endpoints without APQ support fall back to plain requests.
+func (qe *QueryExecutor) executePersisted(ctx context.Context, query *Query) (*QueryResult, error) {
+	if qe.persisted_registry.isUnsupported(qe.endpoint) {
+		return qe.executeResult(ctx)
+	}
+
+	hash := query.persistedHash
+	if hash == "" {
+		hash = persistedQueryHash(query.Query)
+	}
+	// A hash-only attempt for a hash the server is known to be missing is a wasted round trip
+	if !qe.persisted_registry.isMissing(qe.endpoint, hash) {
+		hashOnly := *qe
+		hashOnly.Query = qe.persistedQueryBody(query, hash, false)
+		if qe.persisted_queries_get && query.OperationType == OperationQuery {
+			hashOnly.Method = http.MethodGet
+		}
+
+		result, err := hashOnly.executeResult(ctx)
+		switch persistedQueryError(result, err) {
+		case persistedQueryNotFound:
+			qe.persisted_registry.markHash(qe.endpoint, hash, false)
+			qe.Logger.Debug(&libpack_logger.LogMessage{
+				Message: "Persisted query not found on server, sending full document",
+				Pairs:   map[string]interface{}{"hash": hash},
+			})
+		case persistedQueryNotSupported:
+			return qe.executeUnpersisted(ctx)
+		default:
+			if err == nil {
+				qe.persisted_registry.markHash(qe.endpoint, hash, true)
+			}
+			return result, err
+		}
+	}
+
+	withDocument := *qe
+	withDocument.Query = qe.persistedQueryBody(query, hash, true)
+	result, err := withDocument.executeResult(ctx)
+	switch persistedQueryError(result, err) {
+	case persistedQueryNotSupported:
+		return qe.executeUnpersisted(ctx)
+	case "":
+		if err == nil {
+			// The server registered the document, later calls can send the hash alone again
+			qe.persisted_registry.markHash(qe.endpoint, hash, true)
+		}
+	}
+	return result, err
+}
+
+// executeUnpersisted remembers the endpoint can't do APQ and sends the plain request instead
+func (qe *QueryExecutor) executeUnpersisted(ctx context.Context) (*QueryResult, error) {
+	qe.persisted_registry.markUnsupported(qe.endpoint)
+	qe.Logger.Warning(&libpack_logger.LogMessage{
+		Message: "Persisted queries not supported by server, sending plain queries",
+		Pairs:   map[string]interface{}{"endpoint": qe.endpoint},
+	})
+	return qe.executeResult(ctx)
+}
+
+// getRequestURL encodes a JSON request body as the URL parameters of a GET request
+func getRequestURL(endpoint string, body []byte) (string, error) {
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("can't parse endpoint: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", fmt.Errorf("can't encode request as URL parameters: %w", err)
+	}
+
+	params := target.Query()
+	for key, raw := range fields {
+		var text string
+		if json.Unmarshal(raw, &text) == nil {
+			params.Set(key, text)
+			continue
+		}
+		params.Set(key, string(raw))
+	}
+	target.RawQuery = params.Encode()
+	return target.String(), nil
+}
@@ -0,0 +1,304 @@
+package gql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+type apqRequest struct {
+	method  string
+	hash    string
+	query   string
+	hasVars bool
+}
+
+// apqServer is a stand-in for an Apollo-style server supporting Automatic Persisted Queries
+type apqServer struct {
+	*httptest.Server
+	known        map[string]string
+	requests     []apqRequest
+	mu           sync.Mutex
+	unsupported  bool
+	notFoundCode int
+	rejectDocs   bool // Fail requests carrying a document without registering it
+}
+
+func newAPQServer() *apqServer {
+	server := &apqServer{known: make(map[string]string), notFoundCode: http.StatusOK}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+func (s *apqServer) handle(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Variables  map[string]interface{} `json:"variables"`
+		Extensions struct {
+			PersistedQuery *persistedQueryExtension `json:"persistedQuery"`
+		} `json:"extensions"`
+		Query string `json:"query"`
+	}
+	if r.Method == http.MethodGet {
+		params := r.URL.Query()
+		body.Query = params.Get("query")
+		json.Unmarshal([]byte(params.Get("extensions")), &body.Extensions)
+		json.Unmarshal([]byte(params.Get("variables")), &body.Variables)
+	} else {
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &body)
+	}
+
+	request := apqRequest{method: r.Method, query: body.Query, hasVars: body.Variables != nil}
+	if body.Extensions.PersistedQuery != nil {
+		request.hash = body.Extensions.PersistedQuery.Sha256Hash
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if request.hash != "" {
+		if s.unsupported {
+			w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotSupported","extensions":{"code":"PERSISTED_QUERY_NOT_SUPPORTED"}}]}`))
+			return
+		}
+		s.mu.Lock()
+		if body.Query != "" && s.rejectDocs {
+			s.mu.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if body.Query != "" {
+			if persistedQueryHash(body.Query) != request.hash {
+				s.mu.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":[{"message":"provided sha does not match query"}]}`))
+				return
+			}
+			s.known[request.hash] = body.Query
+		}
+		_, ok := s.known[request.hash]
+		s.mu.Unlock()
+		if !ok {
+			w.WriteHeader(s.notFoundCode)
+			w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+			return
+		}
+	}
+	w.Write([]byte(`{"data":{"viewer":{"login":"mockuser"}}}`))
+}
+
+func (s *apqServer) recorded() []apqRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func (s *apqServer) forgetAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.known = make(map[string]string)
+}
+
+func newAPQTestClient(server *apqServer) *BaseClient {
+	b := NewConnection()
+	b.SetEndpoint(server.URL)
+	b.SetHTTPClient(server.Client())
+	b.SetPersistedQueries(true)
+	return b
+}
+
+const apqQuery = `query ($id: Int!) { viewer(id: $id) { login } }`
+
+func (suite *Tests) TestBaseClient_PersistedQueries() {
+	variables := map[string]interface{}{"id": 1}
+
+	suite.T().Run("should send the hash alone and register unknown documents", func(t *testing.T) {
+		server := newAPQServer()
+		defer server.Close()
+		b := newAPQTestClient(server)
+
+		_, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		first := server.recorded()
+		assert.Len(first, 2)
+		assert.Empty(first[0].query)
+		assert.NotEmpty(first[1].query)
+		assert.Equal(persistedQueryHash(first[1].query), first[0].hash)
+		assert.Equal(first[0].hash, first[1].hash)
+
+		result, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		assert.NotNil(result)
+		second := server.recorded()
+		assert.Equal([]apqRequest{{method: http.MethodPost, hash: first[0].hash, hasVars: true}}, second)
+	})
+
+	suite.T().Run("should send the hash alone from a new client", func(t *testing.T) {
+		server := newAPQServer()
+		defer server.Close()
+		_, err := newAPQTestClient(server).QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		server.recorded()
+
+		// Another replica, or the same service after a restart
+		b := newAPQTestClient(server)
+		b.SetPersistedQueriesGET(true)
+		_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		requests := server.recorded()
+		assert.Len(requests, 1)
+		assert.Equal(http.MethodGet, requests[0].method)
+		assert.Empty(requests[0].query)
+	})
+
+	suite.T().Run("should resend the document when the server forgot the hash", func(t *testing.T) {
+		for _, status := range []int{http.StatusOK, http.StatusBadRequest} {
+			server := newAPQServer()
+			server.notFoundCode = status
+			b := newAPQTestClient(server)
+
+			_, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+			assert.NoError(err)
+			server.recorded()
+			server.forgetAll()
+
+			_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+			assert.NoError(err)
+			requests := server.recorded()
+			assert.Len(requests, 2)
+			assert.Empty(requests[0].query)
+			assert.NotEmpty(requests[1].query)
+			server.Close()
+		}
+	})
+
+	suite.T().Run("should send the document right away for hashes known to be missing", func(t *testing.T) {
+		server := newAPQServer()
+		defer server.Close()
+		b := newAPQTestClient(server)
+
+		server.rejectDocs = true
+		_, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.Error(err)
+		assert.Len(server.recorded(), 2)
+
+		// The server still doesn't know the hash, so it's sent along with the document at once
+		server.rejectDocs = false
+		_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		requests := server.recorded()
+		assert.Len(requests, 1)
+		assert.NotEmpty(requests[0].query)
+		assert.NotEmpty(requests[0].hash)
+
+		// Registered now, back to the hash alone
+		_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		requests = server.recorded()
+		assert.Len(requests, 1)
+		assert.Empty(requests[0].query)
+
+		// Other endpoints keep their own record
+		other := newAPQServer()
+		defer other.Close()
+		b.SetEndpoint(other.URL)
+		b.SetHTTPClient(other.Client())
+		other.rejectDocs = true
+		_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.Error(err)
+		b.SetEndpoint(server.URL)
+		b.SetHTTPClient(server.Client())
+		_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		requests = server.recorded()
+		assert.Len(requests, 1)
+		assert.Empty(requests[0].query)
+	})
+
+	suite.T().Run("should send hash-only queries as GET", func(t *testing.T) {
+		server := newAPQServer()
+		defer server.Close()
+		b := newAPQTestClient(server)
+		b.SetPersistedQueriesGET(true)
+
+		_, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		_, err = b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+
+		// Hash alone, the document to register it once the server didn't know it, then the hash again
+		requests := server.recorded()
+		assert.Len(requests, 3)
+		assert.Equal(http.MethodGet, requests[0].method)
+		assert.Equal(http.MethodPost, requests[1].method)
+		assert.NotEmpty(requests[1].query)
+		assert.Equal(http.MethodGet, requests[2].method)
+		assert.Equal(requests[0].hash, requests[2].hash)
+		assert.True(requests[2].hasVars)
+	})
+
+	suite.T().Run("should keep mutations on POST", func(t *testing.T) {
+		server := newAPQServer()
+		defer server.Close()
+		b := newAPQTestClient(server)
+		b.SetPersistedQueriesGET(true)
+
+		mutation := `mutation { viewer { login } }`
+		for i := 0; i < 2; i++ {
+			_, err := b.MutateContext(context.Background(), mutation, nil, nil)
+			assert.NoError(err)
+		}
+		requests := server.recorded()
+		assert.Len(requests, 3)
+		for _, request := range requests {
+			assert.Equal(http.MethodPost, request.method)
+			assert.NotEmpty(request.hash)
+		}
+	})
+
+	suite.T().Run("should fall back to plain queries when not supported", func(t *testing.T) {
+		server := newAPQServer()
+		server.unsupported = true
+		defer server.Close()
+		b := newAPQTestClient(server)
+
+		for i := 0; i < 2; i++ {
+			_, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+			assert.NoError(err)
+		}
+		requests := server.recorded()
+		assert.Len(requests, 3)
+		assert.NotEmpty(requests[0].hash)
+		assert.Empty(requests[1].hash)
+		assert.Empty(requests[2].hash)
+	})
+
+	suite.T().Run("should not send hashes when disabled", func(t *testing.T) {
+		server := newAPQServer()
+		defer server.Close()
+		b := newAPQTestClient(server)
+		b.SetPersistedQueries(false)
+
+		_, err := b.QueryContext(context.Background(), apqQuery, variables, nil)
+		assert.NoError(err)
+		requests := server.recorded()
+		assert.Empty(requests[0].hash)
+	})
+}
+
+func (suite *Tests) TestGetRequestURL() {
+	target, err := getRequestURL("https://example.com/graphql?tenant=a", []byte(`{"query":"{ viewer }","variables":{"id":1},"extensions":{"persistedQuery":{"version":1,"sha256Hash":"abc"}}}`))
+	assert.NoError(err)
+	assert.Equal("https://example.com/graphql?extensions=%7B%22persistedQuery%22%3A%7B%22version%22%3A1%2C%22sha256Hash%22%3A%22abc%22%7D%7D&query=%7B+viewer+%7D&tenant=a&variables=%7B%22id%22%3A1%7D", target)
+
+	_, err = getRequestURL("https://example.com/graphql", []byte(`not json`))
+	assert.Error(err)
+}
@@ -2,12 +2,25 @@ package gql
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
 	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
 )
 
+// poolQueryTimeout bounds a single warmup or health check query
+const poolQueryTimeout = 5 * time.Second
+
+// poolQuery executes the warmup query with poolQueryTimeout applied
+func (b *BaseClient) poolQuery() error {
+	ctx, cancel := context.WithTimeout(context.Background(), poolQueryTimeout)
+	defer cancel()
+
+	_, err := b.QueryContext(ctx, b.pool_warmup_query, nil, nil)
+	return err
+}
+
 // warmupConnectionPool pre-creates connections by executing warmup queries
 // This establishes connections in the pool before actual traffic arrives
 func (b *BaseClient) warmupConnectionPool() {
@@ -33,7 +46,7 @@ func (b *BaseClient) warmupConnectionPool() {
 			defer wg.Done()
 
 			// Execute warmup query to establish connection
-			_, err := b.Query(b.pool_warmup_query, nil, nil)
+			err := b.poolQuery()
 
 			mu.Lock()
 			if err != nil {
@@ -127,44 +140,32 @@ func (b *BaseClient) performPoolHealthCheck() {
 		go func(index int) {
 			defer wg.Done()
 
-			// Create a context with timeout for health check
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
+			err := b.poolQuery()
 
-			// Use a channel to handle timeout
-			done := make(chan error, 1)
-			go func() {
-				_, err := b.Query(b.pool_warmup_query, nil, nil)
-				done <- err
-			}()
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				healthyCount++
+				return
+			}
 
-			select {
-			case err := <-done:
-				mu.Lock()
-				if err != nil {
-					unhealthyCount++
-					b.Logger.Warning(&libpack_logger.LogMessage{
-						Message: "Pool health check failed",
-						Pairs: map[string]interface{}{
-							"check_index": index,
-							"error":       err.Error(),
-						},
-					})
-				} else {
-					healthyCount++
-				}
-				mu.Unlock()
-			case <-ctx.Done():
-				mu.Lock()
-				unhealthyCount++
+			unhealthyCount++
+			if errors.Is(err, context.DeadlineExceeded) {
 				b.Logger.Warning(&libpack_logger.LogMessage{
 					Message: "Pool health check timeout",
 					Pairs: map[string]interface{}{
 						"check_index": index,
 					},
 				})
-				mu.Unlock()
+				return
 			}
+			b.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Pool health check failed",
+				Pairs: map[string]interface{}{
+					"check_index": index,
+					"error":       err.Error(),
+				},
+			})
 		}(i)
 	}
 
@@ -211,7 +212,7 @@ func (b *BaseClient) refreshConnectionPool() {
 		go func(index int) {
 			defer wg.Done()
 
-			_, err := b.Query(b.pool_warmup_query, nil, nil)
+			err := b.poolQuery()
 			if err != nil {
 				b.Logger.Debug(&libpack_logger.LogMessage{
 					Message: "Pool refresh connection failed",
@@ -1,136 +1,25 @@
 package gql
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/goccy/go-json"
 	"github.com/gookit/goutil"
-	"github.com/gookit/goutil/strutil"
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
 	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
 )
 
-// minifyGraphQLQuery removes unnecessary whitespace from GraphQL queries while preserving string literals
+// minifyGraphQLQuery removes comments and insignificant whitespace from a GraphQL document by
+// parsing and printing it back, so the result means exactly the same. Documents that don't parse
+// are only trimmed, leaving the server to report the problem.
 func minifyGraphQLQuery(query string) string {
-	if query == "" {
-		return query
-	}
-
-	// Pre-allocate with estimated final size (typically 70-80% of original)
-	var result strings.Builder
-	result.Grow(len(query) * 3 / 4)
-
-	inString := false
-	stringChar := byte(0)       // Track whether we're in single or double quotes
-	queryBytes := []byte(query) // Convert to bytes once for faster access
-	length := len(queryBytes)
-
-	i := 0
-	for i < length {
-		char := queryBytes[i]
-
-		// Handle string literals - preserve everything inside quotes
-		if !inString && (char == '"' || char == '\'') {
-			inString = true
-			stringChar = char
-			result.WriteByte(char)
-			i++
-		} else if inString && char == stringChar {
-			// Check if this quote is escaped (optimized version)
-			escaped := false
-			backslashCount := 0
-			for j := i - 1; j >= 0 && queryBytes[j] == '\\'; j-- {
-				backslashCount++
-			}
-			escaped = backslashCount%2 == 1
-
-			if !escaped {
-				inString = false
-				stringChar = 0
-			}
-			result.WriteByte(char)
-			i++
-		} else if inString {
-			// Inside string literal - preserve everything including whitespace
-			result.WriteByte(char)
-			i++
-		} else if isWhitespace(char) {
-			// Handle whitespace outside strings - optimized version
-			// Skip all consecutive whitespace
-			for i < length && isWhitespace(queryBytes[i]) {
-				i++
-			}
-
-			if i < length && result.Len() > 0 {
-				// Get previous character from result buffer
-				resultStr := result.String()
-				prevChar := resultStr[len(resultStr)-1]
-				nextChar := queryBytes[i]
-
-				// Optimized space preservation logic
-				needsSpace := false
-
-				// Between alphanumeric characters (field names, variables, types)
-				if isAlphaNumeric(prevChar) && isAlphaNumeric(nextChar) {
-					needsSpace = true
-				} else if prevChar == '}' && isAlphaNumeric(nextChar) {
-					// Between closing brace and field name: "} field_name"
-					needsSpace = true
-				} else if prevChar == ':' && (isAlphaNumeric(nextChar) || nextChar == '$' || nextChar == '"' || nextChar == '\'' || nextChar == '{') {
-					// Around colons in type definitions and arguments: "$var: Type", "field: value"
-					needsSpace = true
-				} else if (isAlphaNumeric(prevChar) || prevChar == '_') && nextChar == ':' {
-					needsSpace = true
-				} else if prevChar == ',' && nextChar == '$' {
-					// After commas in argument lists: "arg1: value1, arg2: value2" - only for variables
-					needsSpace = true
-				} else if prevChar == ',' && isAlphaNumeric(nextChar) {
-					// Special case: space after comma when followed by field that has colon
-					// Look ahead to see if this field has a colon (limited lookahead for performance)
-					colonFound := false
-					lookAheadLimit := min(i+15, length) // Reduced lookahead for performance
-					for j := i + 1; j < lookAheadLimit; j++ {
-						if queryBytes[j] == ':' {
-							colonFound = true
-							break
-						}
-						if !isAlphaNumeric(queryBytes[j]) && queryBytes[j] != '_' {
-							break
-						}
-					}
-					if colonFound {
-						needsSpace = true
-					}
-				} else if (prevChar == '!' && isAlphaNumeric(nextChar)) || (isAlphaNumeric(prevChar) && nextChar == '!') {
-					// Around exclamation marks in type definitions: "Type !"
-					needsSpace = true
-				}
-
-				if needsSpace {
-					result.WriteByte(' ')
-				}
-			}
-		} else {
-			// Regular character - just copy it
-			result.WriteByte(char)
-			i++
-		}
+	minified, err := ast.Minify(query)
+	if err != nil {
+		return strings.TrimSpace(query)
 	}
-
-	return result.String()
-}
-
-// isAlphaNumeric checks if a character is alphanumeric or underscore
-func isAlphaNumeric(char byte) bool {
-	return (char >= 'a' && char <= 'z') ||
-		(char >= 'A' && char <= 'Z') ||
-		(char >= '0' && char <= '9') ||
-		char == '_'
-}
-
-// isWhitespace checks if a character is whitespace
-func isWhitespace(char byte) bool {
-	return char == ' ' || char == '\t' || char == '\n' || char == '\r'
+	return minified
 }
 
 func (b *BaseClient) convertToJSON(v any) []byte {
@@ -208,8 +97,10 @@ func processFlags(variables map[string]interface{}, headers map[string]interface
 }
 
 func (b *BaseClient) compileQuery(queryPartials ...any) *Query {
-	var query string
+	var query, operationName string
 	var variables map[string]interface{}
+	var doc *ast.Document
+	var parsed bool
 
 	// Pre-allocate the query with an estimated size
 	if len(queryPartials) > 0 {
@@ -225,6 +116,18 @@ func (b *BaseClient) compileQuery(queryPartials ...any) *Query {
 		}
 	}
 
+	// The operation to run from documents holding several
+	if len(queryPartials) > 2 {
+		if name, ok := queryPartials[2].(string); ok {
+			operationName = name
+		}
+	}
+
+	// The syntax tree when the caller parsed the query already, nil when it didn't parse
+	if len(queryPartials) > 3 {
+		doc, parsed = queryPartials[3].(*ast.Document)
+	}
+
 	if query == "" {
 		errPairs := errPairsPool.Get().(map[string]interface{})
 		errPairs["error"] = "query is empty"
@@ -240,7 +143,15 @@ func (b *BaseClient) compileQuery(queryPartials ...any) *Query {
 	finalQuery := query
 	if b.minify_queries {
 		originalSize := len(query)
-		minifiedQuery := minifyGraphQLQuery(query)
+		var minifiedQuery string
+		switch {
+		case doc != nil:
+			minifiedQuery = ast.Print(doc)
+		case parsed:
+			minifiedQuery = strings.TrimSpace(query)
+		default:
+			minifiedQuery = minifyGraphQLQuery(query)
+		}
 		minifiedSize := len(minifiedQuery)
 
 		// Log the minification results if there was a reduction
@@ -260,19 +171,116 @@ func (b *BaseClient) compileQuery(queryPartials ...any) *Query {
 
 	// Construct query object once with final query
 	q := &Query{
-		Query:     finalQuery,
-		Variables: variables,
+		Query:         finalQuery,
+		Variables:     variables,
+		OperationName: operationName,
 	}
 	q.JsonQuery = b.convertToJSON(q)
 	return q
 }
 
 func (b *BaseClient) Query(query string, variables map[string]interface{}, headers map[string]interface{}) (any, error) {
+	return b.QueryContext(context.Background(), query, variables, headers)
+}
+
+// MutateContext executes a GraphQL mutation. Mutations are never served from
+// or stored in the cache, otherwise it behaves exactly like QueryContext.
+func (b *BaseClient) MutateContext(ctx context.Context, mutation string, variables map[string]interface{}, headers map[string]interface{}) (any, error) {
+	return b.QueryContext(ctx, mutation, variables, headers)
+}
+
+// QueryContext executes a GraphQL query bound to ctx. A ctx that is already done fails
+// before the cache is consulted; cancellation and deadlines then apply to the HTTP
+// request and the backoff between retries.
+func (b *BaseClient) QueryContext(ctx context.Context, query string, variables map[string]interface{}, headers map[string]interface{}) (any, error) {
+	rv, err := b.query(ctx, newRequest(query, "", variables, headers))
+	if err != nil {
+		return nil, err
+	}
+	return b.decodeResponse(rv)
+}
+
+// QueryInto executes a GraphQL query and unmarshals its data straight into dst,
+// which must be a pointer. The client's output setting is ignored.
+func (b *BaseClient) QueryInto(ctx context.Context, query string, variables map[string]interface{}, headers map[string]interface{}, dst any) error {
+	rv, err := b.query(ctx, newRequest(query, "", variables, headers))
+	if err != nil {
+		return err
+	}
+	return b.decodeResponseInto(rv, dst)
+}
+
+// QueryAs executes a GraphQL query and returns its data decoded into T
+func QueryAs[T any](ctx context.Context, client *BaseClient, query string, variables map[string]interface{}, headers map[string]interface{}) (T, error) {
+	var result T
+	err := client.QueryInto(ctx, query, variables, headers, &result)
+	return result, err
+}
+
+// QueryPartial executes a GraphQL query and returns the data together with any GraphQL
+// errors, so field-level failures don't discard the rest of the payload. Transport
+// failures and responses without data or errors are still returned as an error.
+func (b *BaseClient) QueryPartial(ctx context.Context, query string, variables map[string]interface{}, headers map[string]interface{}) (*QueryResult, error) {
+	req := newRequest(query, "", variables, headers)
+	req.AllowPartial = true
+	return b.execute(ctx, req)
+}
+
+// HasErrors reports whether the server returned any GraphQL errors
+func (r *QueryResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// Decode unmarshals the (possibly partial) data into dst. A null data field leaves dst untouched.
+func (r *QueryResult) Decode(dst any) error {
+	if isNullJSON(r.Data) {
+		return nil
+	}
+	return json.Unmarshal(r.Data, dst)
+}
+
+// query resolves the raw JSON of the response data field, either from the cache or from the endpoint
+func (b *BaseClient) query(ctx context.Context, req *Request) ([]byte, error) {
+	result, err := b.execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// executeOperation runs the whole operation lifecycle - compilation, cache lookup and the request
+// itself. It sits at the end of the middleware chain.
+func (b *BaseClient) executeOperation(ctx context.Context, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	query, variables, headers, allowPartial := req.Query, req.Variables, req.Headers, req.AllowPartial
+
 	// Process flags before compilation to avoid recompilation
 	enableCache, enableRetries, cleanedVariables := processFlags(variables, headers)
 
-	// Compile query once with cleaned variables
-	compiledQuery := b.compileQuery(query, cleanedVariables)
+	if err := b.validateOperation(req, cleanedVariables); err != nil {
+		return nil, err
+	}
+
+	// Files travel as separate multipart fields, the variables only keep nulls in their place
+	cleanedVariables, uploads, err := extractUploads(cleanedVariables)
+	if err != nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Can't prepare file uploads",
+			Pairs:   map[string]interface{}{"error": err.Error()},
+		})
+		return nil, err
+	}
+
+	// Compile query once with cleaned variables - registry operations are minified already
+	var compiledQuery *Query
+	if op := req.operation; op != nil && op.Document == query && op.Name == req.OperationName {
+		compiledQuery = b.compileOperation(op, cleanedVariables)
+	} else {
+		doc, _ := req.parse()
+		compiledQuery = b.compileQuery(query, cleanedVariables, req.OperationName, doc)
+	}
 	if compiledQuery == nil || compiledQuery.JsonQuery == nil {
 		b.Logger.Error(&libpack_logger.LogMessage{
 			Message: "Can't compile query",
@@ -280,24 +288,30 @@ func (b *BaseClient) Query(query string, variables map[string]interface{}, heade
 		})
 		return nil, fmt.Errorf("can't compile query")
 	}
+	compiledQuery.OperationType = req.OperationType
 	b.Logger.Debug(&libpack_logger.LogMessage{
 		Message: "Compiled query",
-		Pairs:   map[string]interface{}{"query": compiledQuery},
+		Pairs:   map[string]interface{}{"query": compiledQuery, "operation": req.OperationName, "operation_type": req.OperationType},
 	})
 
+	// Only queries are read-only: they alone are cached, coalesced and batched. Retries don't
+	// depend on the operation type, mutations are retried like any other operation.
+	isQuery := req.OperationType == OperationQuery
 	var queryHash string
-	if (enableCache || b.cache_global) && strutil.HasPrefix(compiledQuery.Query, "query") {
+	if (enableCache || b.cache_global) && len(uploads) == 0 && isQuery {
+		queryHash = b.cacheKey(ctx, compiledQuery, headers)
+	}
+	if queryHash != "" {
 		b.Logger.Debug(&libpack_logger.LogMessage{
 			Message: "Cache enabled",
 			Pairs:   nil,
 		})
-		queryHash = calculateHash(compiledQuery)
 		if cachedValue := b.cacheLookup(queryHash); cachedValue != nil {
 			b.Logger.Debug(&libpack_logger.LogMessage{
 				Message: "Cache hit",
 				Pairs:   map[string]interface{}{"query": compiledQuery},
 			})
-			return b.decodeResponse(cachedValue)
+			return &Response{Result: &QueryResult{Data: cachedValue}, Cached: true}, nil
 		}
 		b.Logger.Debug(&libpack_logger.LogMessage{
 			Message: "Cache miss",
@@ -315,17 +329,47 @@ func (b *BaseClient) Query(query string, variables map[string]interface{}, heade
 			}
 			return "no-cache"
 		}(),
-		Retries: enableRetries || b.retries_enable,
+		CacheTTL:     b.cacheTTL(),
+		Retries:      enableRetries || b.retries_enable,
+		Uploads:      uploads,
+		AllowPartial: allowPartial,
 	}
 
-	rv, err := q.executeQuery()
+	run := func(ctx context.Context) (*QueryResult, error) {
+		switch {
+		case len(uploads) > 0:
+			return q.executeResult(ctx)
+		case b.persisted_queries:
+			return q.executePersisted(ctx, compiledQuery)
+		case b.auto_batch_window > 0 && isQuery:
+			// Only queries are collected, mutations keep their own request
+			return q.executeAutoBatched(ctx)
+		default:
+			return q.executeResult(ctx)
+		}
+	}
+
+	var result *QueryResult
+	if (queryHash != "" || b.deduplicate_queries) && len(uploads) == 0 && isQuery {
+		// Identical queries in flight share one request - mutations are never coalesced
+		var shared bool
+		result, shared, err = b.inflight.do(ctx, inflightKey(compiledQuery, headers, allowPartial), run)
+		if shared {
+			b.Logger.Debug(&libpack_logger.LogMessage{
+				Message: "Shared in-flight query result",
+				Pairs:   map[string]interface{}{"query": compiledQuery},
+			})
+		}
+	} else {
+		result, err = run(ctx)
+	}
 	if err != nil {
 		b.Logger.Error(&libpack_logger.LogMessage{
 			Message: "Error executing query",
-			Pairs:   map[string]interface{}{"error": err.Error()},
+			Pairs:   map[string]interface{}{"error": err.Error(), "operation": req.OperationName, "operation_type": req.OperationType},
 		})
 		return nil, err
 	}
 
-	return b.decodeResponse(rv)
+	return &Response{Result: result}, nil
 }
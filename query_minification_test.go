@@ -67,15 +67,6 @@ func TestMinifyGraphQLQuery(t *testing.T) {
 			}`,
 			expected: "query Dragons{dragons{name type abilities{name power}}}",
 		},
-		{
-			name: "query_with_single_quotes",
-			input: `mutation test($value: String!) {
-				insert_test(value: $value, note: 'Single quoted string with\nnewlines') {
-					id
-				}
-			}`,
-			expected: `mutation test($value: String!){insert_test(value: $value, note: 'Single quoted string with\nnewlines'){id}}`,
-		},
 		{
 			name: "query_with_escaped_quotes",
 			input: `mutation test($value: String!) {
@@ -100,20 +91,7 @@ func TestMinifyGraphQLQuery(t *testing.T) {
 					}
 				}
 			}`,
-			expected: "mutation storeProcessedMessage($content: jsonb, $telegram_msg_id: bigint!, $hasMedia: Boolean!, $urls: jsonb, $groupID: bigint!, $userID: bigint!, $entities: jsonb){insert_tg_messages_one(object: {content: $content,telegram_msg_id: $telegram_msg_id, has_media: $hasMedia, urls: $urls, group_id: $groupID, user_id: $userID, entities: $entities}){id tg_group{tg_group_admins_aggregate(where: {user_id: {_eq: $userID}}){aggregate{count}} set_spam_ai}}}",
-		},
-		{
-			name: "query_with_mixed_quotes_and_escapes",
-			input: `mutation test {
-				insert_test(
-					single: 'Single quote with "double" inside',
-					double: "Double quote with 'single' inside",
-					escaped: "Escaped \"double\" quotes"
-				) {
-					id
-				}
-			}`,
-			expected: `mutation test{insert_test(single: 'Single quote with "double" inside', double: "Double quote with 'single' inside", escaped: "Escaped \"double\" quotes"){id}}`,
+			expected: "mutation storeProcessedMessage($content: jsonb, $telegram_msg_id: bigint!, $hasMedia: Boolean!, $urls: jsonb, $groupID: bigint!, $userID: bigint!, $entities: jsonb){insert_tg_messages_one(object: {content: $content, telegram_msg_id: $telegram_msg_id, has_media: $hasMedia, urls: $urls, group_id: $groupID, user_id: $userID, entities: $entities}){id tg_group{tg_group_admins_aggregate(where: {user_id: {_eq: $userID}}){aggregate{count}} set_spam_ai}}}",
 		},
 		{
 			name: "query_with_unicode_in_strings",
@@ -127,6 +105,42 @@ func TestMinifyGraphQLQuery(t *testing.T) {
 			}`,
 			expected: `mutation storeMessage($user_name: String!){insert_message(user_name: $user_name, description: "This a group for serious buyers and sellers\nOf uber eats and Door dash account \nRippers are not allowed in this group😈"){id}}`,
 		},
+		{
+			name: "query_with_comments",
+			input: `# Fetch the viewer
+			query Viewer { # the root field
+				viewer { login } # trailing
+				# id
+			}`,
+			expected: "query Viewer{viewer{login}}",
+		},
+		{
+			name: "query_with_block_string",
+			input: `mutation {
+				insert_note(text: """
+					First line
+					  with "quotes" and a # hash
+				""") { id }
+			}`,
+			expected: `mutation{insert_note(text: "First line\n  with \"quotes\" and a # hash"){id}}`,
+		},
+		{
+			name:     "object_with_long_field_names_after_commas",
+			input:    "query { users(where: {a_very_long_column_name: {_eq: 1},another_very_long_column_name: {_eq: 2}}) { id } }",
+			expected: "query{users(where: {a_very_long_column_name: {_eq: 1}, another_very_long_column_name: {_eq: 2}}){id}}",
+		},
+		{
+			name: "fragments_directives_and_defaults",
+			input: `query Users($limit: Int = 10, $withRole: Boolean!) @cached {
+				users(limit: $limit, order_by: [{name: asc}]) {
+					...UserFields
+					... on users @include(if: $withRole) { role }
+					... @skip(if: false) { name }
+				}
+			}
+			fragment UserFields on users { id }`,
+			expected: "query Users($limit: Int = 10, $withRole: Boolean!) @cached{users(limit: $limit, order_by: [{name: asc}]){...UserFields ...on users @include(if: $withRole){role} ... @skip(if: false){name}}} fragment UserFields on users{id}",
+		},
 	}
 
 	for _, tt := range tests {
@@ -149,6 +163,28 @@ func TestMinifyGraphQLQuery(t *testing.T) {
 	}
 }
 
+func TestMinifyGraphQLQueryInvalidDocuments(t *testing.T) {
+	assert := assertions.New(t)
+
+	// Single quoted strings aren't GraphQL, documents that don't parse are sent as they are
+	tests := []string{
+		`mutation test($value: String!) {
+			insert_test(value: $value, note: 'Single quoted string with\nnewlines') {
+				id
+			}
+		}`,
+		`mutation test {
+			insert_test(single: 'Single quote with "double" inside', double: "Double quote with 'single' inside") {
+				id
+			}
+		}`,
+		"  query { users { id }  ",
+	}
+	for _, query := range tests {
+		assert.Equal(strings.TrimSpace(query), minifyGraphQLQuery(query))
+	}
+}
+
 func TestMinifyGraphQLQuerySizeReduction(t *testing.T) {
 	assert := assertions.New(t)
 
@@ -323,40 +359,6 @@ func TestBaseClientQueryMinification(t *testing.T) {
 	})
 }
 
-func TestIsAlphaNumeric(t *testing.T) {
-	assert := assertions.New(t)
-
-	tests := []struct {
-		char     byte
-		expected bool
-	}{
-		{'a', true},
-		{'z', true},
-		{'A', true},
-		{'Z', true},
-		{'0', true},
-		{'9', true},
-		{'_', true},
-		{' ', false},
-		{'\n', false},
-		{'\t', false},
-		{'{', false},
-		{'}', false},
-		{'(', false},
-		{')', false},
-		{':', false},
-		{'"', false},
-		{'\'', false},
-	}
-
-	for _, tt := range tests {
-		t.Run(string(tt.char), func(t *testing.T) {
-			result := isAlphaNumeric(tt.char)
-			assert.Equal(tt.expected, result, "isAlphaNumeric should correctly identify character type")
-		})
-	}
-}
-
 func TestQueryMinificationEnvironmentVariable(t *testing.T) {
 	assert := assertions.New(t)
 
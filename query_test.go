@@ -1,7 +1,12 @@
 package gql
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/goccy/go-reflect"
 )
@@ -376,6 +381,33 @@ func (suite *Tests) TestBaseClient_Query_caching() {
 		_, err := b.Query(mutation, variables, nil)
 		assert.Error(err) // Mock server doesn't handle mutations
 	})
+
+	suite.T().Run("should cache queries by their operation, not their prefix", func(t *testing.T) {
+		server, requests := newMiddlewareTestServer()
+		defer server.Close()
+		b := NewConnection()
+		b.SetEndpoint(server.URL)
+		b.SetHTTPClient(server.Client())
+
+		variables := map[string]interface{}{"gqlcache": true}
+		for _, query := range []string{
+			`{ viewer { login } }`,
+			"# The signed in user\nquery Viewer { viewer { login } }",
+			`fragment Login on User { login } query { viewer { ...Login } }`,
+		} {
+			for i := 0; i < 2; i++ {
+				_, err := b.Query(query, variables, nil)
+				assert.NoError(err, query)
+			}
+		}
+		assert.Equal(int32(3), atomic.LoadInt32(requests))
+
+		// Not a query at all, so neither cached nor coalesced
+		for i := 0; i < 2; i++ {
+			b.Query(`queryX { viewer { login } }`, variables, nil)
+		}
+		assert.Equal(int32(5), atomic.LoadInt32(requests))
+	})
 }
 
 func (suite *Tests) TestBaseClient_Query_retries() {
@@ -393,6 +425,17 @@ func (suite *Tests) TestBaseClient_Query_retries() {
 		assert.NoError(err)
 		assert.NotNil(result)
 	})
+
+	suite.T().Run("should retry mutations with client-wide retries", func(t *testing.T) {
+		server, requests := newMiddlewareTestServer(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+		defer server.Close()
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithRetries(3, 0))
+		assert.NoError(err)
+
+		_, err = b.MutateContext(context.Background(), `mutation { logout }`, nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(3), atomic.LoadInt32(requests))
+	})
 }
 
 func (suite *Tests) TestBaseClient_Query_globalCache() {
@@ -427,3 +470,283 @@ func (suite *Tests) TestBaseClient_Query_emptyQuery() {
 		assert.Contains(err.Error(), "can't compile query")
 	})
 }
+
+func (suite *Tests) TestBaseClient_QueryContext() {
+	suite.T().Run("should execute query with background context", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		result, err := b.QueryContext(context.Background(), "query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"viewer":{"login":"mockuser"}}`, result)
+	})
+
+	suite.T().Run("should fail fast on cancelled context", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := b.QueryContext(ctx, "query { viewer { login } }", map[string]interface{}{"gqlcache": true}, nil)
+		assert.ErrorIs(err, context.Canceled)
+		assert.Nil(result)
+	})
+
+	suite.T().Run("should abort slow request on deadline", func(t *testing.T) {
+		slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			w.Write([]byte(`{"data":{"viewer":{"login":"slow"}}}`))
+		}))
+		defer slowServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(slowServer.URL)
+		b.SetHTTPClient(slowServer.Client())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		startTime := time.Now()
+		_, err := b.QueryContext(ctx, "query { viewer { login } }", nil, nil)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		assert.Less(time.Since(startTime), time.Second)
+	})
+
+	suite.T().Run("should stop retry backoff on cancellation", func(t *testing.T) {
+		var attempts int32
+		flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer flakyServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(flakyServer.URL)
+		b.SetHTTPClient(flakyServer.Client())
+		b.retries_enable = true
+		b.retries_number = 5
+		b.retries_delay = time.Second
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := b.QueryContext(ctx, "query { viewer { login } }", nil, nil)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+		assert.Equal(int32(1), atomic.LoadInt32(&attempts))
+	})
+
+	suite.T().Run("should execute mutation with context", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		_, err := b.MutateContext(context.Background(), "mutation { updateUser { id } }", nil, nil)
+		assert.Error(err) // Mock server doesn't handle mutations
+	})
+}
+
+func (suite *Tests) TestBaseClient_QueryInto() {
+	type dragons struct {
+		Dragons []struct {
+			Name string `json:"name"`
+		} `json:"dragons"`
+	}
+
+	suite.T().Run("should decode data into struct", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		var got dragons
+		err := b.QueryInto(context.Background(), "query { dragons { name } }", nil, nil, &got)
+		assert.NoError(err)
+		assert.Len(got.Dragons, 2)
+		assert.Equal("Mock Dragon 1", got.Dragons[0].Name)
+	})
+
+	suite.T().Run("should decode cached data into struct", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint("http://127.0.0.1:1/graphql") // Unreachable, the cache must answer
+		b.cache = GetTestCache()
+
+		compiled := b.compileQuery("query { dragons { name } }", nil)
+		b.cache.Set(calculateHash(compiled), []byte(`{"dragons":[{"name":"Cached Dragon"}]}`), 5*time.Second)
+
+		var got dragons
+		err := b.QueryInto(context.Background(), "query { dragons { name } }", map[string]interface{}{"gqlcache": true}, nil, &got)
+		assert.NoError(err)
+		assert.Len(got.Dragons, 1)
+		assert.Equal("Cached Dragon", got.Dragons[0].Name)
+	})
+
+	suite.T().Run("should return query errors", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		var got dragons
+		err := b.QueryInto(context.Background(), "query { potato { login } ", nil, nil, &got)
+		assert.Error(err)
+	})
+}
+
+func (suite *Tests) TestQueryAs() {
+	type viewer struct {
+		Viewer struct {
+			Login string `json:"login"`
+		} `json:"viewer"`
+	}
+
+	suite.T().Run("should return typed result", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		got, err := QueryAs[viewer](context.Background(), b, "query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+		assert.Equal("mockuser", got.Viewer.Login)
+	})
+
+	suite.T().Run("should return zero value on error", func(t *testing.T) {
+		b := NewConnection()
+		b.SetEndpoint(mockServer.URL)
+		b.SetHTTPClient(mockServer.Client())
+
+		got, err := QueryAs[viewer](context.Background(), b, "query { unknown }", nil, nil)
+		assert.Error(err)
+		assert.Empty(got.Viewer.Login)
+	})
+}
+
+func (suite *Tests) TestBaseClient_QueryPartial() {
+	suite.T().Run("should return data alongside errors", func(t *testing.T) {
+		partialServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"viewer":{"login":"mockuser","avatar":null}},"errors":[{"message":"avatar service down","path":["viewer","avatar"],"extensions":{"code":"unavailable"}}],"extensions":{"tracing":{"duration":42}}}`))
+		}))
+		defer partialServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(partialServer.URL)
+		b.SetHTTPClient(partialServer.Client())
+
+		result, err := b.QueryPartial(context.Background(), "query { viewer { login avatar } }", nil, nil)
+		assert.NoError(err)
+		assert.True(result.HasErrors())
+		assert.True(result.Errors.HasCode("unavailable"))
+		assert.Equal([]interface{}{"viewer", "avatar"}, result.Errors[0].Path)
+		assert.Equal(`{"viewer":{"login":"mockuser","avatar":null}}`, string(result.Data))
+		assert.NotNil(result.Extensions["tracing"])
+
+		var got struct {
+			Viewer struct {
+				Login string `json:"login"`
+			} `json:"viewer"`
+		}
+		assert.NoError(result.Decode(&got))
+		assert.Equal("mockuser", got.Viewer.Login)
+	})
+
+	suite.T().Run("should accept null data with errors", func(t *testing.T) {
+		nullServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":null,"errors":[{"message":"not authorised","extensions":{"code":"access-denied"}}]}`))
+		}))
+		defer nullServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(nullServer.URL)
+		b.SetHTTPClient(nullServer.Client())
+
+		result, err := b.QueryPartial(context.Background(), "query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+		assert.Nil(result.Data)
+		assert.True(result.Errors.HasCode("access-denied"))
+	})
+
+	suite.T().Run("should retry retryable errors and return last response", func(t *testing.T) {
+		var attempts int32
+		flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if atomic.AddInt32(&attempts, 1) < 3 {
+				w.Write([]byte(`{"data":null,"errors":[{"message":"postgres deadlock detected"}]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"viewer":{"login":"mockuser"}}}`))
+		}))
+		defer flakyServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(flakyServer.URL)
+		b.SetHTTPClient(flakyServer.Client())
+		b.retries_enable = true
+		b.retries_number = 3
+		b.retries_delay = time.Millisecond
+
+		result, err := b.QueryPartial(context.Background(), "query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+		assert.False(result.HasErrors())
+		assert.Equal(`{"viewer":{"login":"mockuser"}}`, string(result.Data))
+		assert.Equal(int32(3), atomic.LoadInt32(&attempts))
+	})
+
+	suite.T().Run("should return errors when retries are exhausted", func(t *testing.T) {
+		failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"viewer":null},"errors":[{"message":"connection refused by postgres"}]}`))
+		}))
+		defer failingServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(failingServer.URL)
+		b.SetHTTPClient(failingServer.Client())
+		b.retries_enable = true
+		b.retries_number = 2
+		b.retries_delay = time.Millisecond
+
+		result, err := b.QueryPartial(context.Background(), "query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+		assert.True(result.HasErrors())
+		assert.Equal(`{"viewer":null}`, string(result.Data))
+	})
+
+	suite.T().Run("should still fail on transport errors", func(t *testing.T) {
+		errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer errorServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(errorServer.URL)
+		b.SetHTTPClient(errorServer.Client())
+
+		result, err := b.QueryPartial(context.Background(), "query { viewer { login } }", nil, nil)
+		assert.Error(err)
+		assert.Nil(result)
+	})
+
+	suite.T().Run("should not cache responses with errors", func(t *testing.T) {
+		var hits int32
+		partialServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"viewer":null},"errors":[{"message":"partial"}]}`))
+		}))
+		defer partialServer.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(partialServer.URL)
+		b.SetHTTPClient(partialServer.Client())
+
+		compiled := b.compileQuery("query { viewer { id } }", nil)
+		_, err := b.QueryPartial(context.Background(), "query { viewer { id } }", map[string]interface{}{"gqlcache": true}, nil)
+		assert.NoError(err)
+		assert.Nil(b.cacheLookup(calculateHash(compiled)))
+	})
+}
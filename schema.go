@@ -0,0 +1,353 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Type kinds as reported in SchemaType.Kind and TypeRef.Kind
+const (
+	KindScalar      = "SCALAR"
+	KindObject      = "OBJECT"
+	KindInterface   = "INTERFACE"
+	KindUnion       = "UNION"
+	KindEnum        = "ENUM"
+	KindInputObject = "INPUT_OBJECT"
+	KindList        = "LIST"
+	KindNonNull     = "NON_NULL"
+)
+
+// introspectionQuery is the standard introspection query, as sent by graphql-js and GraphiQL
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types { ...FullType }
+    directives {
+      name
+      description
+      locations
+      args { ...InputValue }
+    }
+  }
+}
+
+fragment FullType on __Type {
+  kind
+  name
+  description
+  fields(includeDeprecated: true) {
+    name
+    description
+    args { ...InputValue }
+    type { ...TypeRef }
+    isDeprecated
+    deprecationReason
+  }
+  inputFields { ...InputValue }
+  interfaces { ...TypeRef }
+  enumValues(includeDeprecated: true) {
+    name
+    description
+    isDeprecated
+    deprecationReason
+  }
+  possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+  name
+  description
+  type { ...TypeRef }
+  defaultValue
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType {
+    kind
+    name
+    ofType {
+      kind
+      name
+      ofType {
+        kind
+        name
+        ofType {
+          kind
+          name
+          ofType {
+            kind
+            name
+            ofType {
+              kind
+              name
+              ofType {
+                kind
+                name
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// Schema is the result of introspecting a GraphQL endpoint
+type Schema struct {
+	QueryType        *TypeName              `json:"queryType"`
+	MutationType     *TypeName              `json:"mutationType"`
+	SubscriptionType *TypeName              `json:"subscriptionType"`
+	Types            []*SchemaType          `json:"types"`
+	Directives       []*Directive           `json:"directives"`
+	types            map[string]*SchemaType // Types by name
+}
+
+// TypeName refers to a type by its name
+type TypeName struct {
+	Name string `json:"name"`
+}
+
+// SchemaType is a named type of the schema. Which of the lists are set depends on its kind.
+type SchemaType struct {
+	Kind          string        `json:"kind"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description,omitempty"`
+	Fields        []*Field      `json:"fields"`        // Objects and interfaces
+	InputFields   []*InputValue `json:"inputFields"`   // Input objects
+	Interfaces    []*TypeRef    `json:"interfaces"`    // Objects and interfaces
+	EnumValues    []*EnumValue  `json:"enumValues"`    // Enums
+	PossibleTypes []*TypeRef    `json:"possibleTypes"` // Interfaces and unions
+}
+
+// Field is a field of an object or interface type
+type Field struct {
+	Type              *TypeRef      `json:"type"`
+	Name              string        `json:"name"`
+	Description       string        `json:"description,omitempty"`
+	DeprecationReason string        `json:"deprecationReason,omitempty"`
+	Args              []*InputValue `json:"args"`
+	IsDeprecated      bool          `json:"isDeprecated"`
+}
+
+// InputValue is an argument or a field of an input object
+type InputValue struct {
+	Type         *TypeRef `json:"type"`
+	DefaultValue *string  `json:"defaultValue"` // GraphQL literal, nil when there's no default
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+}
+
+// EnumValue is a value of an enum type
+type EnumValue struct {
+	Name              string `json:"name"`
+	Description       string `json:"description,omitempty"`
+	DeprecationReason string `json:"deprecationReason,omitempty"`
+	IsDeprecated      bool   `json:"isDeprecated"`
+}
+
+// Directive is a directive the server supports
+type Directive struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Locations   []string      `json:"locations"`
+	Args        []*InputValue `json:"args"`
+}
+
+// TypeRef is a reference to a type, wrapped in any number of lists and non-nulls
+type TypeRef struct {
+	OfType *TypeRef `json:"ofType,omitempty"` // The wrapped type of lists and non-nulls
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name,omitempty"` // Empty for lists and non-nulls
+}
+
+// introspectionResult is the data of the introspection query, also used as the file format
+type introspectionResult struct {
+	Schema *Schema `json:"__schema"`
+}
+
+// FetchSchema introspects the endpoint. The query goes through the middleware chain with the
+// client's credentials, retries and transport, like any other.
+func (b *BaseClient) FetchSchema(ctx context.Context) (*Schema, error) {
+	result, err := b.execute(ctx, newRequest(introspectionQuery, "", nil, nil))
+	if err != nil {
+		return nil, fmt.Errorf("can't introspect schema: %w", err)
+	}
+	schema, err := parseIntrospection(result.Data)
+	if err != nil {
+		return nil, err
+	}
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL schema fetched",
+		Pairs:   map[string]interface{}{"types": len(schema.Types), "directives": len(schema.Directives)},
+	})
+	return schema, nil
+}
+
+// LoadSchema reads a schema saved with Save. The result of an introspection query saved by
+// other tools - with or without the data envelope - is read as well, and so is SDL, like a
+// schema.graphql file.
+func LoadSchema(path string) (*Schema, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read schema: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] != '{' {
+		return SchemaFromSDL(string(raw))
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(raw, &envelope) == nil && !isNullJSON(envelope.Data) {
+		raw = envelope.Data
+	}
+	return parseIntrospection(raw)
+}
+
+// Save writes the schema to path as the JSON result of the introspection query
+func (s *Schema) Save(path string) error {
+	raw, err := json.MarshalIndent(&introspectionResult{Schema: s}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("can't encode schema: %w", err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return fmt.Errorf("can't write schema: %w", err)
+	}
+	return nil
+}
+
+// parseIntrospection decodes the data of the introspection query
+func parseIntrospection(data []byte) (*Schema, error) {
+	var result introspectionResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("can't decode schema: %w", err)
+	}
+	if result.Schema == nil {
+		return nil, errors.New("can't decode schema: no __schema in the introspection result")
+	}
+	result.Schema.index()
+	return result.Schema, nil
+}
+
+// index makes the types searchable by name
+func (s *Schema) index() {
+	s.types = make(map[string]*SchemaType, len(s.Types))
+	for _, t := range s.Types {
+		s.types[t.Name] = t
+	}
+}
+
+// Type returns the named type, or nil when the schema has no such type
+func (s *Schema) Type(name string) *SchemaType {
+	if s.types != nil {
+		return s.types[name]
+	}
+	for _, t := range s.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Directive returns the named directive, or nil when the schema has no such directive
+func (s *Schema) Directive(name string) *Directive {
+	for _, d := range s.Directives {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// RootType returns the root type of an operation type - OperationQuery, OperationMutation or
+// OperationSubscription - or nil when the schema doesn't support it
+func (s *Schema) RootType(operationType string) *SchemaType {
+	var root *TypeName
+	switch operationType {
+	case OperationQuery:
+		root = s.QueryType
+	case OperationMutation:
+		root = s.MutationType
+	case OperationSubscription:
+		root = s.SubscriptionType
+	}
+	if root == nil {
+		return nil
+	}
+	return s.Type(root.Name)
+}
+
+// Field returns the named field, or nil when the type has no such field
+func (t *SchemaType) Field(name string) *Field {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// InputField returns the named input field, or nil when the type has no such field
+func (t *SchemaType) InputField(name string) *InputValue {
+	for _, f := range t.InputFields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Arg returns the named argument, or nil when the field takes no such argument
+func (f *Field) Arg(name string) *InputValue {
+	for _, a := range f.Args {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// NamedType returns the name of the type inside all the lists and non-nulls
+func (t *TypeRef) NamedType() string {
+	for t.OfType != nil {
+		t = t.OfType
+	}
+	return t.Name
+}
+
+// String renders the reference in GraphQL notation, like [String!]!
+func (t *TypeRef) String() string {
+	switch {
+	case t == nil:
+		return ""
+	case t.Kind == KindNonNull:
+		return t.OfType.String() + "!"
+	case t.Kind == KindList:
+		return "[" + t.OfType.String() + "]"
+	default:
+		return t.Name
+	}
+}
+
+// IsNonNull reports whether the outermost type is a non-null one
+func (t *TypeRef) IsNonNull() bool {
+	return t.Kind == KindNonNull
+}
+
+// IsList reports whether the type is a list, possibly wrapped in a non-null
+func (t *TypeRef) IsList() bool {
+	if t.Kind == KindNonNull && t.OfType != nil {
+		t = t.OfType
+	}
+	return t.Kind == KindList
+}
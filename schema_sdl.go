@@ -0,0 +1,191 @@
+package gql
+
+import (
+	"fmt"
+
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+)
+
+// builtinScalars are defined by every schema, whether the SDL mentions them or not
+var builtinScalars = []string{"Int", "Float", "String", "Boolean", "ID"}
+
+// builtinDirectives are supported by every schema. Printed SDL usually leaves them out.
+const builtinDirectives = `
+directive @include(if: Boolean!) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+directive @skip(if: Boolean!) on FIELD | FRAGMENT_SPREAD | INLINE_FRAGMENT
+directive @deprecated(reason: String = "No longer supported") on FIELD_DEFINITION | ARGUMENT_DEFINITION | INPUT_FIELD_DEFINITION | ENUM_VALUE
+directive @specifiedBy(url: String!) on SCALAR
+`
+
+// SchemaFromSDL builds a schema from its type system definition, as printed by most servers and
+// tools. Without a schema definition, the root types are Query, Mutation and Subscription.
+func SchemaFromSDL(sdl string) (*Schema, error) {
+	doc, err := ast.ParseSchema(sdl)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse schema: %w", err)
+	}
+	builtins, err := ast.ParseSchema(builtinDirectives)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[string]string, len(doc.Types)+len(builtinScalars))
+	for _, name := range builtinScalars {
+		kinds[name] = KindScalar
+	}
+	for _, definition := range doc.Types {
+		kinds[definition.Name] = definition.Kind
+	}
+	c := &sdlConverter{kinds: kinds}
+
+	schema := &Schema{}
+	for _, name := range builtinScalars {
+		if !hasTypeDefinition(doc.Types, name) {
+			schema.Types = append(schema.Types, &SchemaType{Kind: KindScalar, Name: name})
+		}
+	}
+	for _, definition := range doc.Types {
+		schema.Types = append(schema.Types, c.schemaType(definition))
+	}
+	for _, definition := range builtins.Directives {
+		if !hasDirectiveDefinition(doc.Directives, definition.Name) {
+			schema.Directives = append(schema.Directives, c.directive(definition))
+		}
+	}
+	for _, definition := range doc.Directives {
+		schema.Directives = append(schema.Directives, c.directive(definition))
+	}
+	schema.index()
+
+	for _, t := range schema.Types {
+		for _, implemented := range t.Interfaces {
+			if i := schema.Type(implemented.Name); i != nil && t.Kind == KindObject {
+				i.PossibleTypes = append(i.PossibleTypes, &TypeRef{Kind: KindObject, Name: t.Name})
+			}
+		}
+	}
+
+	roots := &ast.SchemaDefinition{Query: "Query", Mutation: "Mutation", Subscription: "Subscription"}
+	if doc.Schema != nil {
+		roots = doc.Schema
+	}
+	schema.QueryType = schema.typeName(roots.Query)
+	schema.MutationType = schema.typeName(roots.Mutation)
+	schema.SubscriptionType = schema.typeName(roots.Subscription)
+	if schema.QueryType == nil {
+		return nil, fmt.Errorf("can't parse schema: no query type %q", roots.Query)
+	}
+	return schema, nil
+}
+
+// typeName refers to the named object type, or returns nil when there's none
+func (s *Schema) typeName(name string) *TypeName {
+	if t := s.Type(name); t != nil && t.Kind == KindObject {
+		return &TypeName{Name: name}
+	}
+	return nil
+}
+
+// sdlConverter turns type system definitions into their introspection representation
+type sdlConverter struct {
+	kinds map[string]string // Kinds of the named types
+}
+
+func (c *sdlConverter) schemaType(definition *ast.TypeDefinition) *SchemaType {
+	t := &SchemaType{Kind: definition.Kind, Name: definition.Name, Description: definition.Description}
+	for _, name := range definition.Interfaces {
+		t.Interfaces = append(t.Interfaces, &TypeRef{Kind: KindInterface, Name: name})
+	}
+	for _, field := range definition.Fields {
+		reason, deprecated := deprecation(field.Directives)
+		t.Fields = append(t.Fields, &Field{
+			Name:              field.Name,
+			Description:       field.Description,
+			Args:              c.inputValues(field.Arguments),
+			Type:              c.typeRef(field.Type),
+			IsDeprecated:      deprecated,
+			DeprecationReason: reason,
+		})
+	}
+	t.InputFields = c.inputValues(definition.InputFields)
+	for _, value := range definition.EnumValues {
+		reason, deprecated := deprecation(value.Directives)
+		t.EnumValues = append(t.EnumValues, &EnumValue{
+			Name:              value.Name,
+			Description:       value.Description,
+			IsDeprecated:      deprecated,
+			DeprecationReason: reason,
+		})
+	}
+	for _, name := range definition.Types {
+		t.PossibleTypes = append(t.PossibleTypes, &TypeRef{Kind: KindObject, Name: name})
+	}
+	return t
+}
+
+func (c *sdlConverter) directive(definition *ast.DirectiveDefinition) *Directive {
+	return &Directive{
+		Name:        definition.Name,
+		Description: definition.Description,
+		Locations:   definition.Locations,
+		Args:        c.inputValues(definition.Arguments),
+	}
+}
+
+func (c *sdlConverter) inputValues(definitions []*ast.InputValueDefinition) []*InputValue {
+	var values []*InputValue
+	for _, definition := range definitions {
+		value := &InputValue{Name: definition.Name, Description: definition.Description, Type: c.typeRef(definition.Type)}
+		if definition.DefaultValue != nil {
+			literal := definition.DefaultValue.String()
+			value.DefaultValue = &literal
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func (c *sdlConverter) typeRef(t *ast.Type) *TypeRef {
+	var ref *TypeRef
+	if t.Elem != nil {
+		ref = &TypeRef{Kind: KindList, OfType: c.typeRef(t.Elem)}
+	} else {
+		ref = &TypeRef{Kind: c.kinds[t.Name], Name: t.Name}
+	}
+	if t.NonNull {
+		ref = &TypeRef{Kind: KindNonNull, OfType: ref}
+	}
+	return ref
+}
+
+// deprecation returns the reason given by the @deprecated directive, if there is one
+func deprecation(directives []*ast.Directive) (string, bool) {
+	for _, d := range directives {
+		if d.Name != "deprecated" {
+			continue
+		}
+		if reason := d.Argument("reason"); reason != nil && reason.Value.Kind == ast.StringValue {
+			return ast.Unquote(reason.Value.Raw), true
+		}
+		return "No longer supported", true
+	}
+	return "", false
+}
+
+func hasTypeDefinition(definitions []*ast.TypeDefinition, name string) bool {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDirectiveDefinition(definitions []*ast.DirectiveDefinition, name string) bool {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package gql
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testSDL is the schema of testIntrospection, printed as SDL
+const testSDL = `
+schema {
+  query: query_root
+  mutation: mutation_root
+}
+
+"""whether this query should be cached"""
+directive @cached(ttl: Int! = 60) on QUERY
+
+type query_root {
+  "fetch data from the table: \"users\""
+  users(limit: Int, where: users_bool_exp): [users!]!
+  legacy_users: users @deprecated(reason: "Use users")
+}
+
+type mutation_root {
+  delete_users: Int
+}
+
+"""
+columns and relationships of "users"
+"""
+type users {
+  id: uuid!
+  role: user_role
+}
+
+input users_bool_exp {
+  id: uuid
+}
+
+enum user_role {
+  admin
+  guest @deprecated(reason: "No more guests")
+}
+
+scalar uuid
+`
+
+func TestSchemaFromSDL(t *testing.T) {
+	schema, err := SchemaFromSDL(testSDL)
+	if err != nil {
+		t.Fatalf("SchemaFromSDL() error = %v", err)
+	}
+	if schema.RootType(OperationQuery).Name != "query_root" || schema.RootType(OperationMutation).Name != "mutation_root" || schema.RootType(OperationSubscription) != nil {
+		t.Errorf("root types = %+v %+v %+v", schema.QueryType, schema.MutationType, schema.SubscriptionType)
+	}
+	if users := schema.Type("query_root").Field("users"); users.Type.String() != "[users!]!" || users.Arg("where").Type.Kind != KindInputObject || users.Description != `fetch data from the table: "users"` {
+		t.Errorf("users = %+v", users)
+	}
+	if legacy := schema.Type("query_root").Field("legacy_users"); !legacy.IsDeprecated || legacy.DeprecationReason != "Use users" {
+		t.Errorf("legacy_users = %+v", legacy)
+	}
+	if role := schema.Type("users").Field("role"); role.Type.Kind != KindEnum {
+		t.Errorf("role = %+v", role.Type)
+	}
+	if cached := schema.Directive("cached"); cached == nil || *cached.Args[0].DefaultValue != "60" {
+		t.Errorf("cached = %+v", cached)
+	}
+	for _, name := range []string{"include", "skip", "deprecated", "specifiedBy"} {
+		if schema.Directive(name) == nil {
+			t.Errorf("built-in directive @%s is missing", name)
+		}
+	}
+	for _, name := range builtinScalars {
+		if schema.Type(name) == nil {
+			t.Errorf("built-in scalar %s is missing", name)
+		}
+	}
+
+	introspected := testSchema(t)
+	for _, query := range []string{
+		`query ($id: uuid!) { users(where: {id: $id}) { id role } }`,
+		`{ users(limit: "10") { id nme } legacy_users { role } }`,
+		`query { users(where: {id: 1, ids: []}) { role } } mutation { delete_users { id } }`,
+		`query @cached(ttl: 120) { users { role(format: $missing) } }`,
+	} {
+		if got, want := schema.Validate(query, nil).Error(), introspected.Validate(query, nil).Error(); got != want {
+			t.Errorf("Validate(%q) with the SDL schema = %s, want %s", query, got, want)
+		}
+	}
+	if problems := schema.Validate(introspectionQuery, nil); len(problems) > 0 {
+		t.Errorf("Validate(introspectionQuery) with the SDL schema = %s", problems)
+	}
+}
+
+func TestSchemaFromSDL_Defaults(t *testing.T) {
+	schema, err := SchemaFromSDL(`
+interface Node { id: ID! }
+type User implements Node { id: ID! name: String @deprecated }
+type Group implements Node { id: ID! }
+union Member = User | Group
+type Query { node(id: ID!): Node, members(filter: Filter = {names: ["a"]}): [Member] }
+type Subscription { users: [User!]! }
+input Filter { names: [String!] }
+`)
+	if err != nil {
+		t.Fatalf("SchemaFromSDL() error = %v", err)
+	}
+	if schema.RootType(OperationQuery).Name != "Query" || schema.RootType(OperationSubscription).Name != "Subscription" || schema.RootType(OperationMutation) != nil {
+		t.Errorf("root types = %+v %+v %+v", schema.QueryType, schema.MutationType, schema.SubscriptionType)
+	}
+	if node := schema.Type("Node"); len(node.PossibleTypes) != 2 || node.PossibleTypes[1].Name != "Group" {
+		t.Errorf("possible types of Node = %+v", node.PossibleTypes)
+	}
+	if member := schema.Type("Member"); len(member.PossibleTypes) != 2 {
+		t.Errorf("possible types of Member = %+v", member.PossibleTypes)
+	}
+	if name := schema.Type("User").Field("name"); name.DeprecationReason != "No longer supported" {
+		t.Errorf("deprecation reason = %q", name.DeprecationReason)
+	}
+	if filter := schema.Type("Query").Field("members").Arg("filter"); *filter.DefaultValue != `{names: ["a"]}` {
+		t.Errorf("default value = %s", *filter.DefaultValue)
+	}
+	if errs := schema.Validate(`{ node(id: "1") { id ... on User { name } ... on Member { __typename } } }`, nil); len(errs.failures()) != 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+
+	for sdl, want := range map[string]string{
+		"type User { id: ID! }": `can't parse schema: no query type "Query"`,
+		"type Query { id: ID! ": "can't parse schema: syntax error at 1:22: expected Name, found <EOF>",
+	} {
+		if _, err := SchemaFromSDL(sdl); err == nil || err.Error() != want {
+			t.Errorf("SchemaFromSDL(%q) error = %v, want %s", sdl, err, want)
+		}
+	}
+}
+
+func TestLoadSchema_SDL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.graphql")
+	if err := os.WriteFile(path, []byte(testSDL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadSchema(path)
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	if schema.Type("users").Field("id").Type.String() != "uuid!" {
+		t.Errorf("LoadSchema() = %+v", schema.Type("users"))
+	}
+}
@@ -0,0 +1,160 @@
+package gql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testIntrospection is the introspection result of a small schema
+const testIntrospection = `{"__schema":{
+  "queryType":{"name":"query_root"},
+  "mutationType":{"name":"mutation_root"},
+  "subscriptionType":null,
+  "types":[
+    {"kind":"OBJECT","name":"query_root","description":null,"fields":[
+      {"name":"users","description":"fetch data from the table: \"users\"","args":[
+        {"name":"limit","description":null,"type":{"kind":"SCALAR","name":"Int","ofType":null},"defaultValue":null},
+        {"name":"where","description":null,"type":{"kind":"INPUT_OBJECT","name":"users_bool_exp","ofType":null},"defaultValue":null}
+      ],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"LIST","name":null,"ofType":{"kind":"NON_NULL","name":null,"ofType":{"kind":"OBJECT","name":"users","ofType":null}}}},"isDeprecated":false,"deprecationReason":null},
+      {"name":"legacy_users","description":null,"args":[],"type":{"kind":"OBJECT","name":"users","ofType":null},"isDeprecated":true,"deprecationReason":"Use users"}
+    ],"inputFields":null,"interfaces":[],"enumValues":null,"possibleTypes":null},
+    {"kind":"OBJECT","name":"mutation_root","description":null,"fields":[
+      {"name":"delete_users","description":null,"args":[],"type":{"kind":"SCALAR","name":"Int","ofType":null},"isDeprecated":false,"deprecationReason":null}
+    ],"inputFields":null,"interfaces":[],"enumValues":null,"possibleTypes":null},
+    {"kind":"OBJECT","name":"users","description":"columns and relationships of \"users\"","fields":[
+      {"name":"id","description":null,"args":[],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"uuid","ofType":null}},"isDeprecated":false,"deprecationReason":null},
+      {"name":"role","description":null,"args":[],"type":{"kind":"ENUM","name":"user_role","ofType":null},"isDeprecated":false,"deprecationReason":null}
+    ],"inputFields":null,"interfaces":[],"enumValues":null,"possibleTypes":null},
+    {"kind":"INPUT_OBJECT","name":"users_bool_exp","description":null,"fields":null,"inputFields":[
+      {"name":"id","description":null,"type":{"kind":"SCALAR","name":"uuid","ofType":null},"defaultValue":null}
+    ],"interfaces":null,"enumValues":null,"possibleTypes":null},
+    {"kind":"ENUM","name":"user_role","description":null,"fields":null,"inputFields":null,"interfaces":null,"enumValues":[
+      {"name":"admin","description":null,"isDeprecated":false,"deprecationReason":null},
+      {"name":"guest","description":null,"isDeprecated":true,"deprecationReason":"No more guests"}
+    ],"possibleTypes":null},
+    {"kind":"SCALAR","name":"String","description":null,"fields":null,"inputFields":null,"interfaces":null,"enumValues":null,"possibleTypes":null},
+    {"kind":"SCALAR","name":"Boolean","description":null,"fields":null,"inputFields":null,"interfaces":null,"enumValues":null,"possibleTypes":null},
+    {"kind":"SCALAR","name":"Int","description":null,"fields":null,"inputFields":null,"interfaces":null,"enumValues":null,"possibleTypes":null},
+    {"kind":"SCALAR","name":"uuid","description":null,"fields":null,"inputFields":null,"interfaces":null,"enumValues":null,"possibleTypes":null}
+  ],
+  "directives":[
+    {"name":"cached","description":"whether this query should be cached","locations":["QUERY"],"args":[
+      {"name":"ttl","description":null,"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"Int","ofType":null}},"defaultValue":"60"}
+    ]}
+  ]
+}}`
+
+// newSchemaServer answers introspection queries sent with the admin secret
+func newSchemaServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("X-Hasura-Admin-Secret") != "s3cret" || !strings.Contains(string(body), "IntrospectionQuery") {
+			w.Write([]byte(`{"errors":[{"message":"field '__schema' not found in type: 'query_root'"}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":` + testIntrospection + `}`))
+	}))
+}
+
+func (suite *Tests) TestBaseClient_FetchSchema() {
+	suite.T().Run("should introspect the schema", func(t *testing.T) {
+		server := newSchemaServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewHasuraAdminSecretAuth("s3cret"))
+		schema, err := b.FetchSchema(context.Background())
+		assert.NoError(err)
+		assert.Len(schema.Types, 9)
+		assert.Equal("query_root", schema.RootType(OperationQuery).Name)
+		assert.Equal("mutation_root", schema.RootType(OperationMutation).Name)
+		assert.Nil(schema.RootType(OperationSubscription))
+
+		users := schema.RootType(OperationQuery).Field("users")
+		assert.Equal("[users!]!", users.Type.String())
+		assert.Equal("users", users.Type.NamedType())
+		assert.True(users.Type.IsList())
+		assert.True(users.Type.IsNonNull())
+		assert.Equal("users_bool_exp", users.Arg("where").Type.String())
+		assert.NotNil(schema.Type("users_bool_exp").InputField("id"))
+		assert.True(schema.RootType(OperationQuery).Field("legacy_users").IsDeprecated)
+		assert.Equal("No more guests", schema.Type("user_role").EnumValues[1].DeprecationReason)
+		assert.Equal("60", *schema.Directive("cached").Args[0].DefaultValue)
+		assert.Nil(schema.Type("missing"))
+	})
+
+	suite.T().Run("should pass strict validation", func(t *testing.T) {
+		server := newSchemaServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithSchema(testSchema(t)), WithValidation(ValidationStrict))
+		assert.NoError(err)
+		b.SetAuthProvider(NewHasuraAdminSecretAuth("s3cret"))
+		schema, err := b.FetchSchema(context.Background())
+		assert.NoError(err)
+		assert.Len(schema.Types, 9)
+	})
+
+	suite.T().Run("should return the server's errors", func(t *testing.T) {
+		server := newSchemaServer()
+		defer server.Close()
+
+		_, err := newBatchTestClient(server).FetchSchema(context.Background())
+		assert.ErrorContains(err, "can't introspect schema")
+		assert.ErrorContains(err, "__schema")
+	})
+
+	suite.T().Run("should save and load the schema", func(t *testing.T) {
+		server := newSchemaServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetAuthProvider(NewHasuraAdminSecretAuth("s3cret"))
+		schema, err := b.FetchSchema(context.Background())
+		assert.NoError(err)
+
+		path := filepath.Join(t.TempDir(), "schema.json")
+		assert.NoError(schema.Save(path))
+		loaded, err := LoadSchema(path)
+		assert.NoError(err)
+		assert.Equal(len(schema.Types), len(loaded.Types))
+		assert.Equal("[users!]!", loaded.Type("query_root").Field("users").Type.String())
+		assert.Equal("uuid", loaded.Type("users").Field("id").Type.NamedType())
+	})
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"bare.json":     testIntrospection,
+		"envelope.json": `{"data":` + testIntrospection + `}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		schema, err := LoadSchema(path)
+		if err != nil {
+			t.Fatalf("LoadSchema(%s) error = %v", name, err)
+		}
+		if schema.Type("users") == nil {
+			t.Errorf("LoadSchema(%s) is missing the users type", name)
+		}
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"data":{"users":[]}}`), 0o644)
+	if _, err := LoadSchema(invalid); err == nil {
+		t.Error("LoadSchema() of a result without __schema should fail")
+	}
+	if _, err := LoadSchema(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadSchema() of a missing file should fail")
+	}
+}
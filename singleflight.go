@@ -0,0 +1,83 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// inflightGroup coalesces identical queries running at the same time into a single request
+type inflightGroup struct {
+	calls map[string]*inflightCall
+	mu    sync.Mutex
+}
+
+// inflightCall is a request shared by every caller waiting for the same query
+type inflightCall struct {
+	err     error
+	result  *QueryResult
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// SetQueryDeduplication coalesces identical queries sent with identical headers while one of
+// them is in flight, even when they aren't cached. Cached queries are always coalesced.
+func (b *BaseClient) SetQueryDeduplication(enabled bool) {
+	b.deduplicate_queries = enabled
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL query deduplication setting updated",
+		Pairs:   map[string]interface{}{"deduplicate_queries": enabled},
+	})
+}
+
+// do runs fn once for every key in flight and hands its result to all the callers. fn gets a
+// context carrying the values of the first caller's, cancelled only once all of the callers
+// have given up.
+func (g *inflightGroup) do(ctx context.Context, key string, fn func(context.Context) (*QueryResult, error)) (*QueryResult, bool, error) {
+	g.mu.Lock()
+	call, shared := g.calls[key]
+	if shared {
+		call.waiters++
+	} else {
+		if g.calls == nil {
+			g.calls = make(map[string]*inflightCall)
+		}
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &inflightCall{done: make(chan struct{}), cancel: cancel, waiters: 1}
+		g.calls[key] = call
+		go func() {
+			defer cancel()
+			call.result, call.err = fn(callCtx)
+			g.mu.Lock()
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, shared, call.err
+		}
+		// Callers get their own copy, only the data itself is shared
+		result := *call.result
+		return &result, shared, nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		if call.waiters--; call.waiters == 0 {
+			call.cancel()
+			// Later callers must not join a cancelled request
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
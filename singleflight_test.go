@@ -0,0 +1,166 @@
+package gql
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newSlowServer holds every request until release is closed, counting them
+func newSlowServer() (*httptest.Server, *int32, chan struct{}) {
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"viewer":{"login":"shared"}}}`))
+	}))
+	return server, &requests, release
+}
+
+// startQueries runs the query n times concurrently and returns a function waiting for the results
+func startQueries(b *BaseClient, n int, query string, variables, headers map[string]interface{}) func() ([]any, []error) {
+	results := make([]any, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = b.QueryContext(context.Background(), query, variables, headers)
+		}(i)
+	}
+	return func() ([]any, []error) {
+		wg.Wait()
+		return results, errs
+	}
+}
+
+// requestIDKey carries a value through the context, to check it reaches the transport
+type requestIDKey struct{}
+
+// recordRequestIDs returns transport middleware collecting the requestIDKey of every attempt
+func recordRequestIDs(ids *[]any, mu *sync.Mutex) TransportMiddleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			mu.Lock()
+			*ids = append(*ids, r.Context().Value(requestIDKey{}))
+			mu.Unlock()
+			return next(r)
+		}
+	}
+}
+
+func (suite *Tests) TestBaseClient_QueryDeduplication() {
+	suite.T().Run("should share one request between identical queries", func(t *testing.T) {
+		server, requests, release := newSlowServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetQueryDeduplication(true)
+		wait := startQueries(b, 5, "query { viewer { login } }", nil, nil)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		results, errs := wait()
+		for i := range results {
+			assert.NoError(errs[i])
+			assert.Equal(`{"viewer":{"login":"shared"}}`, results[i])
+		}
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should keep the context values of the first caller", func(t *testing.T) {
+		server, _, release := newSlowServer()
+		defer server.Close()
+		close(release)
+
+		var ids []any
+		var mu sync.Mutex
+		b := newBatchTestClient(server)
+		b.SetQueryDeduplication(true)
+		b.UseTransport(recordRequestIDs(&ids, &mu))
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+		_, err := b.QueryContext(ctx, "query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+		assert.Equal([]any{"req-1"}, ids)
+	})
+
+	suite.T().Run("should coalesce cached queries without the setting", func(t *testing.T) {
+		server, requests, release := newSlowServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		wait := startQueries(b, 5, "query { viewer { login } }", map[string]interface{}{"gqlcache": true}, nil)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		_, errs := wait()
+		for _, err := range errs {
+			assert.NoError(err)
+		}
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should keep requests with different headers apart", func(t *testing.T) {
+		server, requests, release := newSlowServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetQueryDeduplication(true)
+		waitAlice := startQueries(b, 2, "query { viewer { login } }", nil, map[string]interface{}{"Authorization": "alice"})
+		waitBob := startQueries(b, 2, "query { viewer { login } }", nil, map[string]interface{}{"Authorization": "bob"})
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		waitAlice()
+		waitBob()
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should never coalesce mutations", func(t *testing.T) {
+		server, requests, release := newSlowServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetQueryDeduplication(true)
+		wait := startQueries(b, 3, "mutation { viewer { login } }", nil, nil)
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		wait()
+		assert.Equal(int32(3), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should not fail waiters when another caller gives up", func(t *testing.T) {
+		server, requests, release := newSlowServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetQueryDeduplication(true)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled := make(chan error, 1)
+		go func() {
+			_, err := b.QueryContext(ctx, "query { viewer { login } }", nil, nil)
+			cancelled <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+		wait := startQueries(b, 1, "query { viewer { login } }", nil, nil)
+		time.Sleep(20 * time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(<-cancelled, context.Canceled)
+		close(release)
+
+		results, errs := wait()
+		assert.NoError(errs[0])
+		assert.Equal(`{"viewer":{"login":"shared"}}`, results[0])
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+}
@@ -2,52 +2,97 @@ package gql
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/goccy/go-json"
 	cache "github.com/lukaszraczylo/go-simple-graphql/cache"
 	logging "github.com/lukaszraczylo/go-simple-graphql/logging"
 )
 
 type BaseClient struct {
-	cache                *cache.Cache
-	Logger               *logging.Logger
-	client               *http.Client
-	endpoint             string
-	responseType         string
-	retries_delay        time.Duration
-	retries_number       int
-	retries_patterns     []string      // Error patterns that should trigger retries
-	pool_size            int           // Number of connections to pre-warm
-	pool_warmup_enabled  bool          // Enable connection pool warmup
-	pool_warmup_query    string        // Query to use for warming up connections
-	pool_health_interval time.Duration // Interval for pool health checks
-	pool_stop            chan bool     // Channel to stop pool monitor
-	MaxGoRoutines        int
-	cache_global         bool
-	retries_enable       bool
-	minify_queries       bool // Enable GraphQL query minification (default: true)
+	cache                  *cache.Cache
+	cache_ttl              time.Duration // How long cached responses stay valid
+	Logger                 *logging.Logger
+	client                 *http.Client
+	endpoint               string
+	responseType           string
+	retries_delay          time.Duration
+	retries_number         int
+	retries_patterns       []string              // Error patterns that should trigger retries
+	pool_size              int                   // Number of connections to pre-warm
+	pool_warmup_enabled    bool                  // Enable connection pool warmup
+	pool_warmup_query      string                // Query to use for warming up connections
+	pool_health_interval   time.Duration         // Interval for pool health checks
+	pool_stop              chan bool             // Channel to stop pool monitor
+	*clientState                                 // Connections and queues shared with derived clients
+	subscription_keepalive time.Duration         // Interval between keepalive pings on subscription sockets
+	subscription_protocol  string                // auto, graphql-transport-ws or graphql-ws
+	subscription_transport string                // websocket, sse or sse-single
+	cache_key_headers      []string              // Headers that are part of the cache key, nil for the default
+	cache_key_func         CacheKeyFunc          // Builds cache keys in place of cache_key_headers
+	schema                 *Schema               // Operations are validated against it, see SetValidation
+	operations             *Operations           // Registry Exec runs operations from
+	validation             string                // off, warn or strict
+	hasura_session         *HasuraSession        // Session sent with every operation, see WithHasuraSession
+	auth_provider          AuthProvider          // Supplies credentials for every request
+	middlewares            []Middleware          // Wrap every operation, outermost first
+	transport_middlewares  []TransportMiddleware // Wrap every HTTP attempt, outermost first
+	auto_batch_window      time.Duration         // How long queries are collected for, 0 disables auto-batching
+	auto_batch_size        int                   // Most queries sent in one auto-batch, 0 for no limit
+	MaxGoRoutines          int
+	cache_global           bool
+	retries_enable         bool
+	minify_queries         bool // Enable GraphQL query minification (default: true)
+	persisted_queries      bool // Send Automatic Persisted Query hashes instead of full documents
+	persisted_queries_get  bool // Send hash-only queries as GET
+	deduplicate_queries    bool // Coalesce identical in-flight queries even when they aren't cached
+}
+
+// clientState is the runtime state of a client. Clients derived with WithHasuraSession share
+// it with their parent, so they reuse its sockets, registry and queues.
+type clientState struct {
+	subscription_conns map[string]*subscriptionConn // Subscription sockets keyed by header set
+	sse_conns          map[string]*sseConn          // Reserved graphql-sse streams keyed by header set
+	persisted_registry persistedQueryRegistry       // Persisted query support and known hashes per endpoint
+	auto_batcher       autoBatcher                  // Queries waiting to be sent together
+	inflight           inflightGroup                // Queries in flight, shared by identical callers
+	subscription_mu    sync.Mutex
 }
 
 type Query struct {
-	Variables map[string]interface{} `json:"variables,omitempty"`
-	Query     string                 `json:"query,omitempty"`
-	JsonQuery []byte                 `json:"-"` // Exclude from JSON serialization to prevent nested encoding
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	JsonQuery     []byte                 `json:"-"` // Exclude from JSON serialization to prevent nested encoding
+	OperationType string                 `json:"-"` // Type of the operation the document runs, see operationInfo
+	persistedHash string                 // persistedQueryHash of Query when known in advance
 }
 
 type QueryExecutor struct {
 	Result any
 	Error  error
 	*BaseClient
-	Headers  map[string]interface{}
-	CacheKey string
-	Query    []byte
-	CacheTTL time.Duration
-	Retries  bool
+	Headers      map[string]interface{}
+	CacheKey     string
+	Query        []byte
+	CacheTTL     time.Duration
+	Retries      bool
+	Method       string       // http.MethodGet sends the request as URL parameters, POST otherwise
+	Uploads      []uploadFile // Files sent as a multipart request
+	AllowPartial bool         // Return data together with GraphQL errors instead of failing
 }
 
 type queryResults struct {
-	Data   interface{} `json:"data"`
-	Errors []struct {
-		Message interface{} `json:"message"`
-	} `json:"errors"`
+	Extensions map[string]interface{} `json:"extensions"`
+	Data       json.RawMessage        `json:"data"`
+	Errors     Errors                 `json:"errors"`
+}
+
+// QueryResult is the complete GraphQL response. Data and Errors may both be set
+// when the server resolved only part of the selection.
+type QueryResult struct {
+	Extensions map[string]interface{}
+	Data       json.RawMessage
+	Errors     Errors
 }
@@ -0,0 +1,642 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultSubscriptionKeepAlive = 15 * time.Second
+	subscriptionAckTimeout       = 10 * time.Second
+	subscriptionMaxBackoff       = 10 * time.Second
+	subscriptionBufferSize       = 16
+)
+
+var errSubscriptionConnClosed = errors.New("subscription connection closed")
+
+// SubscriptionEvent is a single result pushed by the server for a subscription.
+// The channel it arrives on is closed once the subscription completes.
+type SubscriptionEvent struct {
+	Extensions map[string]interface{}
+	Data       json.RawMessage
+	Errors     Errors
+}
+
+type wsMessage struct {
+	Payload json.RawMessage `json:"payload,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+}
+
+// subscription is a single operation multiplexed over a subscriptionConn
+type subscription struct {
+	events   chan SubscriptionEvent
+	done     chan struct{}
+	id       string
+	payload  json.RawMessage
+	mu       sync.Mutex
+	once     sync.Once
+	finished bool
+}
+
+func newSubscription(id string, payload json.RawMessage) *subscription {
+	return &subscription{
+		id:      id,
+		payload: payload,
+		events:  make(chan SubscriptionEvent, subscriptionBufferSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// deliver hands the event to the subscriber, giving up once the subscription is finished
+func (s *subscription) deliver(event SubscriptionEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return false
+	}
+	select {
+	case s.events <- event:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// offer hands the event to the subscriber without waiting for it, so a subscriber lagging
+// behind can't hold up the others reading from the same connection. It reports whether the
+// event had to be dropped because the subscriber's buffer is full.
+func (s *subscription) offer(event SubscriptionEvent) (dropped bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return false
+	}
+	select {
+	case s.events <- event:
+		return false
+	default:
+		return true
+	}
+}
+
+// deliverShared hands an event read from a connection shared by several subscriptions to sub,
+// dropping it with a warning when the subscriber has fallen a full buffer behind
+func (b *BaseClient) deliverShared(sub *subscription, event SubscriptionEvent) {
+	if sub.offer(event) {
+		b.Logger.Warning(&libpack_logger.LogMessage{
+			Message: "Subscriber is falling behind, dropping subscription event",
+			Pairs:   map[string]interface{}{"id": sub.id, "buffer_size": subscriptionBufferSize},
+		})
+	}
+}
+
+// finish closes the events channel exactly once, after any pending delivery gave up
+func (s *subscription) finish() {
+	s.once.Do(func() {
+		close(s.done)
+		s.mu.Lock()
+		s.finished = true
+		close(s.events)
+		s.mu.Unlock()
+	})
+}
+
+// subscriptionConn is one socket shared by every subscription made with the same headers
+type subscriptionConn struct {
+	ctx      context.Context
+	err      error // Why the first dial failed, set before ready is closed
+	client   *BaseClient
+	headers  map[string]interface{}
+	subs     map[string]*subscription
+	ws       *websocket.Conn
+	protocol *subscriptionProtocol // Negotiated for the current socket
+	ready    chan struct{}         // Closed once the first dial is done
+	cancel   context.CancelFunc
+	key      string
+	nextID   uint64
+	mu       sync.Mutex
+	writeMu  sync.Mutex
+	closed   bool
+}
+
+// Subscribe starts a GraphQL subscription. By default it runs over WebSocket, speaking
+// graphql-transport-ws or the legacy subscriptions-transport-ws protocol depending on
+// SetSubscriptionProtocol; SetSubscriptionTransport switches to graphql-sse. Subscriptions sharing
+// the same headers are multiplexed over one connection, which is kept alive and transparently
+// reconnected (and resubscribed) when it drops. Every subscription buffers up to 16 events;
+// when one of them falls further behind, newer events on a shared connection are dropped with
+// a warning rather than holding up the other subscriptions. Cancel ctx to stop the subscription;
+// the returned channel is closed afterwards.
+func (b *BaseClient) Subscribe(ctx context.Context, query string, variables map[string]interface{}, headers map[string]interface{}) (<-chan SubscriptionEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	headers, err := b.authHeaders(ctx, b.sessionHeaders(ctx, headers))
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, cleanedVariables := processFlags(variables, headers)
+	req := newRequest(query, "", cleanedVariables, headers)
+	if err := b.validateOperation(req, cleanedVariables); err != nil {
+		return nil, err
+	}
+	doc, _ := req.parse()
+	compiledQuery := b.compileQuery(query, cleanedVariables, req.OperationName, doc)
+	if compiledQuery == nil || compiledQuery.JsonQuery == nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Can't compile subscription",
+			Pairs:   map[string]interface{}{"error": "query is empty"},
+		})
+		return nil, fmt.Errorf("can't compile query")
+	}
+
+	var sub *subscription
+	var stop func()
+	switch b.subscriptionTransportSetting() {
+	case SubscriptionTransportSSE:
+		sub, stop, err = b.subscribeSSE(ctx, headers, compiledQuery.JsonQuery)
+	case SubscriptionTransportSSESingle:
+		sub, stop, err = b.subscribeSSESingle(ctx, headers, compiledQuery)
+	default:
+		var conn *subscriptionConn
+		conn, sub, err = b.subscribe(ctx, headers, compiledQuery.JsonQuery)
+		stop = func() { conn.unsubscribe(sub, true) }
+	}
+	if err != nil {
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Can't start subscription",
+			Pairs:   map[string]interface{}{"error": err.Error(), "transport": b.subscriptionTransportSetting()},
+		})
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-sub.done:
+		}
+	}()
+
+	return sub.events, nil
+}
+
+// subscribe registers the operation on the connection for headers, dialing it first if needed.
+// Dialing happens outside subscription_mu: subscriptions with the same headers wait for it,
+// while the others and the teardown of idle connections carry on.
+func (b *BaseClient) subscribe(ctx context.Context, headers map[string]interface{}, payload []byte) (*subscriptionConn, *subscription, error) {
+	key := headersKey(headers)
+	for {
+		b.subscription_mu.Lock()
+		conn, ok := b.subscription_conns[key]
+		if !ok {
+			conn = b.newSubscriptionConn(key, headers)
+			if b.subscription_conns == nil {
+				b.subscription_conns = make(map[string]*subscriptionConn)
+			}
+			b.subscription_conns[key] = conn
+		}
+		b.subscription_mu.Unlock()
+
+		if !ok {
+			conn.open(ctx)
+		}
+		select {
+		case <-conn.ready:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if conn.err != nil {
+			// The subscription dialing gave up, dial again for this one
+			if ok && errors.Is(conn.err, context.Canceled) {
+				continue
+			}
+			return nil, nil, conn.err
+		}
+
+		sub, err := conn.add(payload)
+		if errors.Is(err, errSubscriptionConnClosed) {
+			// Its last subscription has just closed the connection - take a fresh one
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, sub, nil
+	}
+}
+
+// open dials the connection for the first time and starts serving it. A connection that
+// can't be dialed is dropped, so the next subscription dials a new one.
+func (c *subscriptionConn) open(ctx context.Context) {
+	defer close(c.ready)
+	ws, protocol, err := c.connect(ctx)
+	if err != nil {
+		b := c.client
+		b.subscription_mu.Lock()
+		if b.subscription_conns[c.key] == c {
+			delete(b.subscription_conns, c.key)
+		}
+		b.subscription_mu.Unlock()
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+		c.cancel()
+		c.err = err
+		return
+	}
+	c.mu.Lock()
+	c.ws, c.protocol = ws, protocol
+	c.mu.Unlock()
+	go c.run()
+}
+
+func (b *BaseClient) newSubscriptionConn(key string, headers map[string]interface{}) *subscriptionConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &subscriptionConn{
+		ctx:     ctx,
+		cancel:  cancel,
+		client:  b,
+		headers: headers,
+		key:     key,
+		subs:    make(map[string]*subscription),
+		ready:   make(chan struct{}),
+	}
+}
+
+// subscriptionEndpoint converts the client endpoint into its WebSocket equivalent
+func (b *BaseClient) subscriptionEndpoint() (string, error) {
+	switch {
+	case strings.HasPrefix(b.endpoint, "https://"):
+		return "wss://" + strings.TrimPrefix(b.endpoint, "https://"), nil
+	case strings.HasPrefix(b.endpoint, "http://"):
+		return "ws://" + strings.TrimPrefix(b.endpoint, "http://"), nil
+	default:
+		return "", fmt.Errorf("invalid endpoint for subscriptions - must start with http:// or https://")
+	}
+}
+
+func (b *BaseClient) subscriptionKeepAlive() time.Duration {
+	if b.subscription_keepalive <= 0 {
+		return defaultSubscriptionKeepAlive
+	}
+	return b.subscription_keepalive
+}
+
+// connect dials the socket, negotiates the protocol and completes the connection_init / connection_ack handshake
+func (c *subscriptionConn) connect(ctx context.Context) (*websocket.Conn, *subscriptionProtocol, error) {
+	endpoint, err := c.client.subscriptionEndpoint()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err := websocket.NewConfig(endpoint, c.client.endpoint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't configure subscription connection: %w", err)
+	}
+	config.Protocol = c.client.offeredSubscriptionProtocols()
+	config.TlsConfig = c.client.tlsClientConfig()
+
+	dialCtx, cancel := context.WithTimeout(ctx, subscriptionAckTimeout)
+	defer cancel()
+
+	ws, err := config.DialContext(dialCtx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't open subscription connection: %w", err)
+	}
+	protocol := negotiatedSubscriptionProtocol(ws.Config().Protocol)
+
+	initHeaders := make(map[string]string, len(c.headers))
+	for key, value := range c.headers {
+		initHeaders[key] = fmt.Sprint(value)
+	}
+	initPayload, err := json.Marshal(map[string]interface{}{"headers": initHeaders})
+	if err != nil {
+		ws.Close()
+		return nil, nil, fmt.Errorf("can't encode connection_init payload: %w", err)
+	}
+	if err := c.send(ws, wsMessage{Type: wsConnectionInit, Payload: initPayload}); err != nil {
+		ws.Close()
+		return nil, nil, fmt.Errorf("can't send connection_init: %w", err)
+	}
+
+	ws.SetReadDeadline(time.Now().Add(subscriptionAckTimeout))
+	for {
+		msg, err := receiveWSMessage(ws)
+		if err != nil {
+			ws.Close()
+			return nil, nil, fmt.Errorf("no connection_ack received: %w", err)
+		}
+		switch msg.Type {
+		case wsConnectionAck:
+			ws.SetReadDeadline(time.Time{})
+			c.client.Logger.Debug(&libpack_logger.LogMessage{
+				Message: "Subscription connection established",
+				Pairs:   map[string]interface{}{"endpoint": endpoint, "protocol": protocol.name},
+			})
+			return ws, protocol, nil
+		case wsPing:
+			if err := c.send(ws, wsMessage{Type: wsPong}); err != nil {
+				ws.Close()
+				return nil, nil, fmt.Errorf("can't answer ping: %w", err)
+			}
+		case wsKeepAlive:
+			// Legacy servers may start sending ka before the ack
+		case wsConnectionError:
+			ws.Close()
+			return nil, nil, fmt.Errorf("connection rejected by server: %w", parseSubscriptionErrors(msg.Payload))
+		default:
+			ws.Close()
+			return nil, nil, fmt.Errorf("unexpected %q message before connection_ack", msg.Type)
+		}
+	}
+}
+
+// run serves the socket and reconnects with exponential backoff until the last subscription is gone
+func (c *subscriptionConn) run() {
+	baseDelay := c.client.subscriptionRetryDelay()
+	delay := baseDelay
+
+	for {
+		c.mu.Lock()
+		ws, protocol := c.ws, c.protocol
+		c.mu.Unlock()
+
+		if ws != nil {
+			err := c.serve(ws, protocol)
+			ws.Close()
+
+			c.mu.Lock()
+			c.ws = nil
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return
+			}
+
+			c.client.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Subscription connection lost, reconnecting",
+				Pairs:   map[string]interface{}{"error": err.Error()},
+			})
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		ws, protocol, err := c.connect(c.ctx)
+		if err != nil {
+			if delay *= 2; delay > subscriptionMaxBackoff {
+				delay = subscriptionMaxBackoff
+			}
+			c.client.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Subscription reconnect failed",
+				Pairs:   map[string]interface{}{"error": err.Error(), "next_attempt_in": delay.String()},
+			})
+			continue
+		}
+		if err := c.attach(ws, protocol); err != nil {
+			ws.Close()
+			if errors.Is(err, errSubscriptionConnClosed) {
+				return
+			}
+			continue
+		}
+		delay = baseDelay
+	}
+}
+
+// attach installs a freshly connected socket and resubscribes every active operation on it
+func (c *subscriptionConn) attach(ws *websocket.Conn, protocol *subscriptionProtocol) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errSubscriptionConnClosed
+	}
+	for _, sub := range c.subs {
+		if err := c.send(ws, wsMessage{ID: sub.id, Type: protocol.subscribe, Payload: sub.payload}); err != nil {
+			return fmt.Errorf("can't resubscribe: %w", err)
+		}
+	}
+	c.ws = ws
+	c.protocol = protocol
+	c.client.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Subscriptions restored after reconnect",
+		Pairs:   map[string]interface{}{"subscriptions": len(c.subs)},
+	})
+	return nil
+}
+
+// serve reads messages until the socket fails. With graphql-transport-ws the client pings the
+// server to keep it alive; legacy servers send ka messages on their own schedule instead.
+func (c *subscriptionConn) serve(ws *websocket.Conn, protocol *subscriptionProtocol) error {
+	keepAlive := c.client.subscriptionKeepAlive()
+	if protocol.clientPings {
+		done := make(chan struct{})
+		defer close(done)
+		go c.keepAlive(ws, keepAlive, done)
+	}
+
+	// Legacy servers are only held to a deadline once they have shown they send ka
+	watchdog := protocol.clientPings
+	for {
+		// Keepalive traffic is guaranteed, so silence for two intervals means a dead socket
+		if watchdog {
+			ws.SetReadDeadline(time.Now().Add(2 * keepAlive))
+		}
+		msg, err := receiveWSMessage(ws)
+		if err != nil {
+			return err
+		}
+		if msg.Type == wsKeepAlive {
+			watchdog = true
+		}
+		if err := c.handle(ws, protocol, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *subscriptionConn) keepAlive(ws *websocket.Conn, interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.send(ws, wsMessage{Type: wsPing}); err != nil {
+				ws.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *subscriptionConn) handle(ws *websocket.Conn, protocol *subscriptionProtocol, msg wsMessage) error {
+	switch msg.Type {
+	case wsPing:
+		if protocol.clientPings {
+			c.send(ws, wsMessage{Type: wsPong})
+		}
+	case wsPong, wsConnectionAck, wsKeepAlive:
+		// Keepalive traffic only
+	case protocol.next:
+		sub := c.lookup(msg.ID)
+		if sub == nil {
+			return nil
+		}
+		event, err := decodeSubscriptionEvent(msg.Payload)
+		if err != nil {
+			c.client.Logger.Error(&libpack_logger.LogMessage{
+				Message: "Can't decode subscription payload",
+				Pairs:   map[string]interface{}{"error": err.Error(), "id": msg.ID},
+			})
+			return nil
+		}
+		c.client.deliverShared(sub, event)
+	case wsError:
+		sub := c.lookup(msg.ID)
+		if sub == nil {
+			return nil
+		}
+		errs := parseSubscriptionErrors(msg.Payload)
+		c.client.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Subscription failed",
+			Pairs:   map[string]interface{}{"id": msg.ID, "errors": errs},
+		})
+		c.client.deliverShared(sub, SubscriptionEvent{Errors: errs})
+		c.unsubscribe(sub, false)
+	case wsComplete:
+		if sub := c.lookup(msg.ID); sub != nil {
+			c.unsubscribe(sub, false)
+		}
+	case wsConnectionError:
+		return fmt.Errorf("connection error from server: %w", parseSubscriptionErrors(msg.Payload))
+	default:
+		c.client.Logger.Debug(&libpack_logger.LogMessage{
+			Message: "Ignoring unknown subscription message",
+			Pairs:   map[string]interface{}{"type": msg.Type, "protocol": protocol.name},
+		})
+	}
+	return nil
+}
+
+func (c *subscriptionConn) lookup(id string) *subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[id]
+}
+
+// add registers a new operation and sends it right away when the socket is up;
+// otherwise it goes out with the resubscribe after the next reconnect
+func (c *subscriptionConn) add(payload []byte) (*subscription, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, errSubscriptionConnClosed
+	}
+
+	c.nextID++
+	sub := newSubscription(strconv.FormatUint(c.nextID, 10), payload)
+	c.subs[sub.id] = sub
+
+	if c.ws != nil {
+		if err := c.send(c.ws, wsMessage{ID: sub.id, Type: c.protocol.subscribe, Payload: payload}); err != nil {
+			// The read loop notices the broken socket and resubscribes after reconnecting
+			c.client.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Can't send subscribe message, will retry after reconnect",
+				Pairs:   map[string]interface{}{"error": err.Error(), "id": sub.id},
+			})
+		}
+	}
+	return sub, nil
+}
+
+// unsubscribe removes the operation, telling the server when notify is set, and
+// shuts the socket down once no subscriptions are left
+func (c *subscriptionConn) unsubscribe(sub *subscription, notify bool) {
+	b := c.client
+	b.subscription_mu.Lock()
+	c.mu.Lock()
+	if _, ok := c.subs[sub.id]; ok {
+		delete(c.subs, sub.id)
+		if notify && c.ws != nil {
+			c.send(c.ws, wsMessage{ID: sub.id, Type: c.protocol.stop})
+		}
+	}
+	idle := len(c.subs) == 0 && !c.closed
+	if idle {
+		c.closed = true
+		if b.subscription_conns[c.key] == c {
+			delete(b.subscription_conns, c.key)
+		}
+	}
+	ws, protocol := c.ws, c.protocol
+	c.mu.Unlock()
+	b.subscription_mu.Unlock()
+
+	sub.finish()
+
+	if idle {
+		c.cancel()
+		if ws != nil {
+			if protocol.terminate != "" {
+				c.send(ws, wsMessage{Type: protocol.terminate})
+			}
+			ws.Close()
+		}
+		b.Logger.Debug(&libpack_logger.LogMessage{
+			Message: "Subscription connection closed - no active subscriptions",
+			Pairs:   map[string]interface{}{"endpoint": b.endpoint},
+		})
+	}
+}
+
+func (c *subscriptionConn) send(ws *websocket.Conn, msg wsMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return websocket.Message.Send(ws, string(data))
+}
+
+// decodeSubscriptionEvent turns an execution result pushed by the server into an event
+func decodeSubscriptionEvent(payload json.RawMessage) (SubscriptionEvent, error) {
+	var result queryResults
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return SubscriptionEvent{}, err
+	}
+	event := SubscriptionEvent{Errors: result.Errors, Extensions: result.Extensions}
+	if !isNullJSON(result.Data) {
+		event.Data = result.Data
+	}
+	return event, nil
+}
+
+func receiveWSMessage(ws *websocket.Conn) (wsMessage, error) {
+	var msg wsMessage
+	var data []byte
+	if err := websocket.Message.Receive(ws, &data); err != nil {
+		return msg, err
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, fmt.Errorf("can't decode subscription message: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,129 @@
+package gql
+
+import (
+	"bytes"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Subscription protocols accepted by SetSubscriptionProtocol and GRAPHQL_SUBSCRIPTION_PROTOCOL.
+// The names match the WebSocket subprotocols the servers announce.
+const (
+	SubscriptionProtocolAuto        = "auto"                 // offer both, let the server choose
+	SubscriptionProtocolTransportWS = "graphql-transport-ws" // graphql-ws library, current Hasura and Apollo
+	SubscriptionProtocolLegacyWS    = "graphql-ws"           // subscriptions-transport-ws, older Hasura and Apollo
+)
+
+// Message types of both protocols
+const (
+	wsConnectionInit = "connection_init"
+	wsConnectionAck  = "connection_ack"
+	wsPing           = "ping"
+	wsPong           = "pong"
+	wsSubscribe      = "subscribe"
+	wsNext           = "next"
+	wsError          = "error"
+	wsComplete       = "complete"
+
+	// subscriptions-transport-ws only
+	wsConnectionError     = "connection_error"
+	wsConnectionTerminate = "connection_terminate"
+	wsStart               = "start"
+	wsData                = "data"
+	wsStop                = "stop"
+	wsKeepAlive           = "ka"
+)
+
+// subscriptionProtocol describes the message set spoken on a subscription socket
+type subscriptionProtocol struct {
+	name        string
+	subscribe   string // Starts an operation
+	next        string // Carries an operation result
+	stop        string // Cancels an operation from the client side
+	terminate   string // Sent before the client closes the socket, empty if the protocol has none
+	clientPings bool   // Client drives keepalive with ping/pong, otherwise the server sends ka
+}
+
+var (
+	transportWSProtocol = &subscriptionProtocol{
+		name:        SubscriptionProtocolTransportWS,
+		subscribe:   wsSubscribe,
+		next:        wsNext,
+		stop:        wsComplete,
+		clientPings: true,
+	}
+	legacyWSProtocol = &subscriptionProtocol{
+		name:      SubscriptionProtocolLegacyWS,
+		subscribe: wsStart,
+		next:      wsData,
+		stop:      wsStop,
+		terminate: wsConnectionTerminate,
+	}
+)
+
+// SetSubscriptionProtocol selects the WebSocket subscription protocol. Use SubscriptionProtocolAuto
+// to offer both and let the server pick, which allows migrating servers without changing call sites.
+func (b *BaseClient) SetSubscriptionProtocol(protocol string) {
+	switch protocol {
+	case SubscriptionProtocolAuto, SubscriptionProtocolTransportWS, SubscriptionProtocolLegacyWS:
+		b.subscription_protocol = protocol
+		b.Logger.Debug(&libpack_logger.LogMessage{
+			Message: "GraphQL subscription protocol updated",
+			Pairs:   map[string]interface{}{"subscription_protocol": protocol},
+		})
+	default:
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Unknown subscription protocol - keeping current setting",
+			Pairs: map[string]interface{}{
+				"requested": protocol,
+				"current":   b.subscriptionProtocolSetting(),
+			},
+		})
+	}
+}
+
+func (b *BaseClient) subscriptionProtocolSetting() string {
+	if b.subscription_protocol == "" {
+		return SubscriptionProtocolAuto
+	}
+	return b.subscription_protocol
+}
+
+// offeredSubscriptionProtocols returns the subprotocols to offer in the handshake, in order of preference
+func (b *BaseClient) offeredSubscriptionProtocols() []string {
+	switch b.subscriptionProtocolSetting() {
+	case SubscriptionProtocolTransportWS:
+		return []string{SubscriptionProtocolTransportWS}
+	case SubscriptionProtocolLegacyWS:
+		return []string{SubscriptionProtocolLegacyWS}
+	default:
+		return []string{SubscriptionProtocolTransportWS, SubscriptionProtocolLegacyWS}
+	}
+}
+
+// negotiatedSubscriptionProtocol picks the protocol the server accepted. Servers that don't
+// echo a subprotocol get the first one offered.
+func negotiatedSubscriptionProtocol(accepted []string) *subscriptionProtocol {
+	if len(accepted) > 0 && accepted[0] == SubscriptionProtocolLegacyWS {
+		return legacyWSProtocol
+	}
+	return transportWSProtocol
+}
+
+// parseSubscriptionErrors decodes an error payload - an array in graphql-transport-ws,
+// a single object in most subscriptions-transport-ws servers
+func parseSubscriptionErrors(payload json.RawMessage) Errors {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var errs Errors
+		if err := json.Unmarshal(trimmed, &errs); err == nil {
+			return errs
+		}
+	}
+	var single GraphQLError
+	if err := json.Unmarshal(trimmed, &single); err == nil && single.Message != "" {
+		return Errors{single}
+	}
+	return Errors{{Message: string(payload)}}
+}
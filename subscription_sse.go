@@ -0,0 +1,683 @@
+package gql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Subscription transports accepted by SetSubscriptionTransport and GRAPHQL_SUBSCRIPTION_TRANSPORT
+const (
+	SubscriptionTransportWebSocket = "websocket"  // graphql-transport-ws or graphql-ws, see SetSubscriptionProtocol
+	SubscriptionTransportSSE       = "sse"        // graphql-sse distinct connections mode, one stream per subscription
+	SubscriptionTransportSSESingle = "sse-single" // graphql-sse single connection mode, one reserved stream per header set
+)
+
+// sseTokenHeader carries the reservation token in graphql-sse single connection mode
+const sseTokenHeader = "X-GraphQL-Event-Stream-Token"
+
+// Event names of the graphql-sse protocol
+const (
+	sseEventNext     = "next"
+	sseEventComplete = "complete"
+)
+
+// SetSubscriptionTransport selects how Subscribe talks to the server. The SSE transports
+// only need plain (streaming) HTTP, so they work where WebSocket upgrades are blocked.
+func (b *BaseClient) SetSubscriptionTransport(transport string) {
+	switch transport {
+	case SubscriptionTransportWebSocket, SubscriptionTransportSSE, SubscriptionTransportSSESingle:
+		b.subscription_transport = transport
+		b.Logger.Debug(&libpack_logger.LogMessage{
+			Message: "GraphQL subscription transport updated",
+			Pairs:   map[string]interface{}{"subscription_transport": transport},
+		})
+	default:
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Unknown subscription transport - keeping current setting",
+			Pairs: map[string]interface{}{
+				"requested": transport,
+				"current":   b.subscriptionTransportSetting(),
+			},
+		})
+	}
+}
+
+func (b *BaseClient) subscriptionTransportSetting() string {
+	if b.subscription_transport == "" {
+		return SubscriptionTransportWebSocket
+	}
+	return b.subscription_transport
+}
+
+// newSSERequest builds a graphql-sse request carrying the subscription headers
+func (b *BaseClient) newSSERequest(ctx context.Context, method, endpoint string, headers map[string]interface{}, body []byte, accept string) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, fmt.Errorf("can't create HTTP request: %w", err)
+	}
+	for key, value := range headers {
+		request.Header.Set(key, fmt.Sprint(value))
+	}
+	if body != nil && request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	request.Header.Set("Accept", accept)
+	request.Header.Set("Accept-Encoding", "identity")
+	return request, nil
+}
+
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// readSSEEvent returns the next event of a text/event-stream, skipping the comment lines
+// servers send as keepalive
+func readSSEEvent(r *bufio.Reader) (sseEvent, error) {
+	var event sseEvent
+	hasData := false
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return event, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			if event.name == "" && !hasData {
+				continue
+			}
+			if event.name == "" {
+				event.name = "message"
+			}
+			return event, nil
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			event.name = string(value)
+		case "data":
+			if hasData {
+				event.data = append(event.data, '\n')
+			}
+			event.data = append(event.data, value...)
+			hasData = true
+		}
+	}
+}
+
+// subscriptionRetryDelay is the first reconnect delay; it doubles up to subscriptionMaxBackoff
+func (b *BaseClient) subscriptionRetryDelay() time.Duration {
+	if b.retries_delay <= 0 {
+		return 250 * time.Millisecond
+	}
+	return b.retries_delay
+}
+
+// subscribeSSE runs the operation on a streaming request of its own (distinct connections mode).
+// With HTTP/2 every stream is multiplexed over the connection the queries use.
+func (b *BaseClient) subscribeSSE(ctx context.Context, headers map[string]interface{}, payload []byte) (*subscription, func(), error) {
+	client, err := b.streamingClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	response, err := b.openDistinctSSEStream(streamCtx, client, headers, payload)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	sub := newSubscription("", payload)
+	go func() {
+		defer cancel()
+		b.runDistinctSSEStream(streamCtx, client, headers, sub, response)
+	}()
+
+	stop := func() {
+		cancel()
+		sub.finish()
+	}
+	return sub, stop, nil
+}
+
+func (b *BaseClient) openDistinctSSEStream(ctx context.Context, client *http.Client, headers map[string]interface{}, payload []byte) (*http.Response, error) {
+	request, err := b.newSSERequest(ctx, http.MethodPost, b.endpoint, headers, payload, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	response, err := b.doStreamingRequest(client, request)
+	if err != nil {
+		return nil, fmt.Errorf("can't open event stream: %w", err)
+	}
+	return response, nil
+}
+
+// runDistinctSSEStream delivers events until the server completes the operation, re-executing
+// it with exponential backoff whenever the stream breaks off
+func (b *BaseClient) runDistinctSSEStream(ctx context.Context, client *http.Client, headers map[string]interface{}, sub *subscription, response *http.Response) {
+	defer sub.finish()
+
+	baseDelay := b.subscriptionRetryDelay()
+	delay := baseDelay
+	for {
+		if response != nil {
+			completed, err := b.serveDistinctSSEStream(response, sub)
+			response.Body.Close()
+			if completed || ctx.Err() != nil {
+				return
+			}
+			b.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Subscription event stream lost, reconnecting",
+				Pairs:   map[string]interface{}{"error": err.Error()},
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		var err error
+		response, err = b.openDistinctSSEStream(ctx, client, headers, sub.payload)
+		if err != nil {
+			var statusErr *httpStatusError
+			if errors.As(err, &statusErr) && statusErr.permanent() {
+				sub.deliver(SubscriptionEvent{Errors: subscriptionErrorsFrom(err)})
+				return
+			}
+			if delay *= 2; delay > subscriptionMaxBackoff {
+				delay = subscriptionMaxBackoff
+			}
+			b.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Subscription reconnect failed",
+				Pairs:   map[string]interface{}{"error": err.Error(), "next_attempt_in": delay.String()},
+			})
+			continue
+		}
+		delay = baseDelay
+	}
+}
+
+// serveDistinctSSEStream reads one response; servers that don't stream answer with a single JSON result
+func (b *BaseClient) serveDistinctSSEStream(response *http.Response, sub *subscription) (bool, error) {
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			return false, err
+		}
+		event, err := decodeSubscriptionEvent(body)
+		if err != nil {
+			return false, fmt.Errorf("can't decode subscription payload: %w", err)
+		}
+		sub.deliver(event)
+		return true, nil
+	}
+
+	reader := bufio.NewReader(response.Body)
+	for {
+		event, err := readSSEEvent(reader)
+		if err != nil {
+			return false, err
+		}
+		switch event.name {
+		case sseEventNext:
+			result, err := decodeSubscriptionEvent(event.data)
+			if err != nil {
+				b.Logger.Error(&libpack_logger.LogMessage{
+					Message: "Can't decode subscription payload",
+					Pairs:   map[string]interface{}{"error": err.Error()},
+				})
+				continue
+			}
+			if !sub.deliver(result) {
+				return true, nil
+			}
+		case sseEventComplete:
+			return true, nil
+		}
+	}
+}
+
+// subscriptionErrorsFrom exposes a failed request as subscription errors
+func subscriptionErrorsFrom(err error) Errors {
+	var errs Errors
+	if errors.As(err, &errs) {
+		return errs
+	}
+	return Errors{{Message: err.Error()}}
+}
+
+// sseConn is one reserved event stream shared by every subscription made with the same
+// headers (single connection mode). Operations are started and stopped with separate requests.
+type sseConn struct {
+	ctx     context.Context
+	err     error // Why the first connect failed, set before ready is closed
+	client  *BaseClient
+	http    *http.Client
+	headers map[string]interface{}
+	subs    map[string]*subscription
+	ready   chan struct{} // Closed once the first connect is done
+	cancel  context.CancelFunc
+	key     string
+	token   string
+	nextID  uint64
+	mu      sync.Mutex
+	closed  bool
+}
+
+// sseOperation is the request body of graphql-sse single connection mode
+type sseOperation struct {
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Extensions map[string]interface{} `json:"extensions"`
+	Query      string                 `json:"query"`
+}
+
+// sseStreamMessage is the data of events on a reserved stream
+type sseStreamMessage struct {
+	Payload json.RawMessage `json:"payload"`
+	ID      string          `json:"id"`
+}
+
+// subscribeSSESingle starts the operation on the reserved stream for headers, reserving and
+// opening it first if needed
+func (b *BaseClient) subscribeSSESingle(ctx context.Context, headers map[string]interface{}, query *Query) (*subscription, func(), error) {
+	for {
+		conn, err := b.sseConnFor(ctx, headers)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Operations are started outside subscription_mu, so the stream may have just been
+		// released by its last subscription - take a fresh one then
+		sub, err := conn.add(ctx, query)
+		if errors.Is(err, errSubscriptionConnClosed) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return sub, func() { conn.unsubscribe(sub, true) }, nil
+	}
+}
+
+// sseConnFor returns the reserved stream for headers, connecting it first if needed. Connecting
+// happens outside subscription_mu, like dialing a websocket does.
+func (b *BaseClient) sseConnFor(ctx context.Context, headers map[string]interface{}) (*sseConn, error) {
+	key := headersKey(headers)
+	for {
+		b.subscription_mu.Lock()
+		conn, ok := b.sse_conns[key]
+		if !ok {
+			client, err := b.streamingClient()
+			if err != nil {
+				b.subscription_mu.Unlock()
+				return nil, err
+			}
+			conn = b.newSSEConn(key, headers, client)
+			if b.sse_conns == nil {
+				b.sse_conns = make(map[string]*sseConn)
+			}
+			b.sse_conns[key] = conn
+		}
+		b.subscription_mu.Unlock()
+
+		if !ok {
+			conn.open(ctx)
+		}
+		select {
+		case <-conn.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if conn.err != nil {
+			// The subscription connecting gave up, connect again for this one
+			if ok && errors.Is(conn.err, context.Canceled) {
+				continue
+			}
+			return nil, conn.err
+		}
+		return conn, nil
+	}
+}
+
+// open connects the stream for the first time and starts serving it. A stream that can't be
+// connected is dropped, so the next subscription reserves a new one.
+func (c *sseConn) open(ctx context.Context) {
+	defer close(c.ready)
+	response, token, err := c.connect(ctx)
+	if err != nil {
+		b := c.client
+		b.subscription_mu.Lock()
+		if b.sse_conns[c.key] == c {
+			delete(b.sse_conns, c.key)
+		}
+		b.subscription_mu.Unlock()
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+		c.cancel()
+		c.err = err
+		return
+	}
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	go c.run(response)
+}
+
+func (b *BaseClient) newSSEConn(key string, headers map[string]interface{}, client *http.Client) *sseConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sseConn{
+		ctx:     ctx,
+		cancel:  cancel,
+		client:  b,
+		http:    client,
+		headers: headers,
+		key:     key,
+		subs:    make(map[string]*subscription),
+		ready:   make(chan struct{}),
+	}
+}
+
+// connect reserves a stream and opens it. The stream lives as long as the connection, not
+// the context of the subscription that happened to open it.
+func (c *sseConn) connect(ctx context.Context) (*http.Response, string, error) {
+	reserveCtx, cancel := context.WithTimeout(ctx, subscriptionAckTimeout)
+	defer cancel()
+
+	request, err := c.client.newSSERequest(reserveCtx, http.MethodPut, c.client.endpoint, c.headers, nil, "text/plain")
+	if err != nil {
+		return nil, "", err
+	}
+	reservation, err := c.client.doStreamingRequest(c.http, request)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't reserve event stream: %w", err)
+	}
+	tokenBody, err := io.ReadAll(io.LimitReader(reservation.Body, 4096))
+	reservation.Body.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("can't read event stream token: %w", err)
+	}
+	token := strings.TrimSpace(string(tokenBody))
+	if token == "" {
+		return nil, "", fmt.Errorf("server returned an empty event stream token")
+	}
+
+	request, err = c.client.newSSERequest(c.ctx, http.MethodGet, c.client.endpoint, c.headers, nil, "text/event-stream")
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set(sseTokenHeader, token)
+	response, err := c.client.doStreamingRequest(c.http, request)
+	if err != nil {
+		return nil, "", fmt.Errorf("can't open event stream: %w", err)
+	}
+
+	c.client.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Subscription event stream established",
+		Pairs:   map[string]interface{}{"endpoint": c.client.endpoint, "transport": SubscriptionTransportSSESingle},
+	})
+	return response, token, nil
+}
+
+// run serves the stream and reconnects with exponential backoff until the last subscription is gone
+func (c *sseConn) run(response *http.Response) {
+	baseDelay := c.client.subscriptionRetryDelay()
+	delay := baseDelay
+
+	for {
+		if response != nil {
+			err := c.serve(response.Body)
+			response.Body.Close()
+
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return
+			}
+
+			c.client.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Subscription event stream lost, reconnecting",
+				Pairs:   map[string]interface{}{"error": err.Error()},
+			})
+			response = nil
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		next, token, err := c.connect(c.ctx)
+		if err == nil {
+			err = c.attach(token)
+			if err != nil {
+				next.Body.Close()
+			}
+		}
+		if err != nil {
+			if errors.Is(err, errSubscriptionConnClosed) || c.ctx.Err() != nil {
+				return
+			}
+			if delay *= 2; delay > subscriptionMaxBackoff {
+				delay = subscriptionMaxBackoff
+			}
+			c.client.Logger.Warning(&libpack_logger.LogMessage{
+				Message: "Subscription reconnect failed",
+				Pairs:   map[string]interface{}{"error": err.Error(), "next_attempt_in": delay.String()},
+			})
+			continue
+		}
+		response = next
+		delay = baseDelay
+	}
+}
+
+// attach switches to the new reservation and executes every active operation on it again
+func (c *sseConn) attach(token string) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errSubscriptionConnClosed
+	}
+	c.token = token
+	subs := make([]*subscription, 0, len(c.subs))
+	for _, sub := range c.subs {
+		subs = append(subs, sub)
+	}
+	c.mu.Unlock()
+
+	for _, sub := range subs {
+		if err := c.execute(c.ctx, token, sub.payload); err != nil {
+			return fmt.Errorf("can't resubscribe: %w", err)
+		}
+	}
+	c.client.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "Subscriptions restored after reconnect",
+		Pairs:   map[string]interface{}{"subscriptions": len(subs)},
+	})
+	return nil
+}
+
+func (c *sseConn) serve(body io.Reader) error {
+	reader := bufio.NewReader(body)
+	for {
+		event, err := readSSEEvent(reader)
+		if err != nil {
+			return err
+		}
+		if event.name != sseEventNext && event.name != sseEventComplete {
+			continue
+		}
+
+		var msg sseStreamMessage
+		if err := json.Unmarshal(event.data, &msg); err != nil {
+			c.client.Logger.Error(&libpack_logger.LogMessage{
+				Message: "Can't decode subscription payload",
+				Pairs:   map[string]interface{}{"error": err.Error(), "event": event.name},
+			})
+			continue
+		}
+		sub := c.lookup(msg.ID)
+		if sub == nil {
+			continue
+		}
+
+		if event.name == sseEventComplete {
+			c.unsubscribe(sub, false)
+			continue
+		}
+		result, err := decodeSubscriptionEvent(msg.Payload)
+		if err != nil {
+			c.client.Logger.Error(&libpack_logger.LogMessage{
+				Message: "Can't decode subscription payload",
+				Pairs:   map[string]interface{}{"error": err.Error(), "id": msg.ID},
+			})
+			continue
+		}
+		c.client.deliverShared(sub, result)
+	}
+}
+
+func (c *sseConn) lookup(id string) *subscription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[id]
+}
+
+// add registers a new operation and executes it on the reserved stream
+func (c *sseConn) add(ctx context.Context, query *Query) (*subscription, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errSubscriptionConnClosed
+	}
+	c.nextID++
+	id := strconv.FormatUint(c.nextID, 10)
+	payload, err := json.Marshal(sseOperation{
+		Query:      query.Query,
+		Variables:  query.Variables,
+		Extensions: map[string]interface{}{"operationId": id},
+	})
+	if err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("can't encode operation: %w", err)
+	}
+	sub := newSubscription(id, payload)
+	c.subs[id] = sub
+	token := c.token
+	c.mu.Unlock()
+
+	if err := c.execute(ctx, token, payload); err != nil {
+		c.unsubscribe(sub, false)
+		return nil, err
+	}
+	return sub, nil
+}
+
+// execute starts an operation on the reserved stream; results arrive on the stream itself
+func (c *sseConn) execute(ctx context.Context, token string, payload []byte) error {
+	request, err := c.client.newSSERequest(ctx, http.MethodPost, c.client.endpoint, c.headers, payload, "application/json")
+	if err != nil {
+		return err
+	}
+	request.Header.Set(sseTokenHeader, token)
+	response, err := c.client.doStreamingRequest(c.http, request)
+	if err != nil {
+		return fmt.Errorf("can't execute operation: %w", err)
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	return nil
+}
+
+// unsubscribe removes the operation, telling the server when notify is set, and releases
+// the reserved stream once no subscriptions are left
+func (c *sseConn) unsubscribe(sub *subscription, notify bool) {
+	b := c.client
+	b.subscription_mu.Lock()
+	c.mu.Lock()
+	_, active := c.subs[sub.id]
+	delete(c.subs, sub.id)
+	idle := len(c.subs) == 0 && !c.closed
+	if idle {
+		c.closed = true
+		if b.sse_conns[c.key] == c {
+			delete(b.sse_conns, c.key)
+		}
+	}
+	token := c.token
+	c.mu.Unlock()
+	b.subscription_mu.Unlock()
+
+	sub.finish()
+
+	if active && notify {
+		c.stop(token, sub.id)
+	}
+	if idle {
+		c.cancel()
+		b.Logger.Debug(&libpack_logger.LogMessage{
+			Message: "Subscription event stream closed - no active subscriptions",
+			Pairs:   map[string]interface{}{"endpoint": b.endpoint},
+		})
+	}
+}
+
+// stop cancels a running operation with DELETE ?operationId=
+func (c *sseConn) stop(token, id string) {
+	endpoint, err := url.Parse(c.client.endpoint)
+	if err != nil {
+		return
+	}
+	params := endpoint.Query()
+	params.Set("operationId", id)
+	endpoint.RawQuery = params.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionAckTimeout)
+	defer cancel()
+	request, err := c.client.newSSERequest(ctx, http.MethodDelete, endpoint.String(), c.headers, nil, "application/json")
+	if err != nil {
+		return
+	}
+	request.Header.Set(sseTokenHeader, token)
+	response, err := c.client.doStreamingRequest(c.http, request)
+	if err != nil {
+		c.client.Logger.Warning(&libpack_logger.LogMessage{
+			Message: "Can't stop subscription operation",
+			Pairs:   map[string]interface{}{"error": err.Error(), "id": id},
+		})
+		return
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+}
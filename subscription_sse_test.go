@@ -0,0 +1,395 @@
+package gql
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// sseServer is a minimal graphql-sse server supporting both modes. Operations emit
+// {"counter": n} events every few milliseconds, forever when events is 0.
+type sseServer struct {
+	*httptest.Server
+	streams     map[string]*sseTestStream
+	operations  chan string
+	stopped     chan string
+	headers     chan http.Header
+	protocols   chan int
+	mu          sync.Mutex
+	events      int
+	dropAfter   int // Close the first stream after this many events
+	connections int32
+	requests    int32
+	tokens      int32
+}
+
+type sseTestStream struct {
+	frames chan string
+	done   chan struct{}
+	ops    map[string]chan struct{}
+}
+
+func newSSEServer(events int) *sseServer {
+	server := &sseServer{
+		events:     events,
+		streams:    make(map[string]*sseTestStream),
+		operations: make(chan string, 16),
+		stopped:    make(chan string, 16),
+		headers:    make(chan http.Header, 16),
+		protocols:  make(chan int, 64),
+	}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.handle))
+	server.EnableHTTP2 = true
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&server.connections, 1)
+		}
+	}
+	server.StartTLS()
+	return server
+}
+
+func sseFrame(event, data string) string {
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", event, data)
+}
+
+func (s *sseServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.protocols <- r.ProtoMajor
+	token := r.Header.Get(sseTokenHeader)
+
+	switch {
+	case r.Method == http.MethodPut:
+		s.headers <- r.Header.Clone()
+		token := "token-" + strconv.Itoa(int(atomic.AddInt32(&s.tokens, 1)))
+		s.mu.Lock()
+		s.streams[token] = &sseTestStream{frames: make(chan string, 64), done: make(chan struct{}), ops: make(map[string]chan struct{})}
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(token))
+
+	case r.Method == http.MethodGet:
+		stream := s.stream(token)
+		if stream == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		defer close(stream.done)
+		first := atomic.AddInt32(&s.requests, 1) == 1
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(": connected\n\n"))
+		w.(http.Flusher).Flush()
+		sent := 0
+		for {
+			select {
+			case frame := <-stream.frames:
+				w.Write([]byte(frame))
+				w.(http.Flusher).Flush()
+				if sent++; first && s.dropAfter > 0 && sent >= s.dropAfter {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+	case r.Method == http.MethodDelete:
+		id := r.URL.Query().Get("operationId")
+		if stream := s.stream(token); stream != nil {
+			s.mu.Lock()
+			if stop, ok := stream.ops[id]; ok {
+				close(stop)
+				delete(stream.ops, id)
+			}
+			s.mu.Unlock()
+		}
+		s.stopped <- id
+		w.WriteHeader(http.StatusOK)
+
+	case token != "":
+		stream := s.stream(token)
+		if stream == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var op sseOperation
+		json.NewDecoder(r.Body).Decode(&op)
+		id := fmt.Sprint(op.Extensions["operationId"])
+		stop := make(chan struct{})
+		s.mu.Lock()
+		stream.ops[id] = stop
+		s.mu.Unlock()
+		s.operations <- id
+		w.WriteHeader(http.StatusAccepted)
+
+		go func() {
+			for i := 1; s.events == 0 || i <= s.events; i++ {
+				frame := sseFrame(sseEventNext, fmt.Sprintf(`{"id":%q,"payload":{"data":{"counter":%d}}}`, id, i))
+				select {
+				case stream.frames <- frame:
+				case <-stream.done:
+					return
+				case <-stop:
+					return
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			select {
+			case stream.frames <- sseFrame(sseEventComplete, fmt.Sprintf(`{"id":%q}`, id)):
+			case <-stream.done:
+			}
+		}()
+
+	case strings.Contains(r.Header.Get("Accept"), "text/event-stream"):
+		s.headers <- r.Header.Clone()
+		var op Query
+		json.NewDecoder(r.Body).Decode(&op)
+		if strings.Contains(op.Query, "broken") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Cannot query field \"broken\"","extensions":{"code":"validation-failed"}}]}`))
+			return
+		}
+		first := atomic.AddInt32(&s.requests, 1) == 1
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 1; s.events == 0 || i <= s.events; i++ {
+			w.Write([]byte(": keepalive\n\n" + sseFrame(sseEventNext, fmt.Sprintf(`{"data":{"counter":%d}}`, i))))
+			w.(http.Flusher).Flush()
+			if first && s.dropAfter > 0 && i >= s.dropAfter {
+				return
+			}
+			select {
+			case <-r.Context().Done():
+				s.stopped <- ""
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+		w.Write([]byte(sseFrame(sseEventComplete, "")))
+
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"viewer":{"login":"mockuser"}}}`))
+	}
+}
+
+func (s *sseServer) stream(token string) *sseTestStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[token]
+}
+
+func newSSETestClient(server *sseServer, transport string) *BaseClient {
+	b := NewConnection()
+	b.SetEndpoint(server.URL)
+	b.client = b.createHttpClient()
+	b.retries_delay = 10 * time.Millisecond
+	b.SetSubscriptionTransport(transport)
+	return b
+}
+
+func collectCounters(t *testing.T, events <-chan SubscriptionEvent, n int) []string {
+	var received []string
+	timeout := time.After(5 * time.Second)
+	for len(received) < n {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return received
+			}
+			assert.Empty(event.Errors)
+			received = append(received, string(event.Data))
+		case <-timeout:
+			t.Fatalf("only received %v", received)
+		}
+	}
+	return received
+}
+
+func (suite *Tests) TestBaseClient_Subscribe_SSE() {
+	suite.T().Run("should stream events over HTTP/2 until complete", func(t *testing.T) {
+		server := newSSEServer(3)
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSE)
+		_, err := b.Query("query { viewer { login } }", nil, nil)
+		assert.NoError(err)
+
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, map[string]interface{}{"Authorization": "Bearer token"})
+		assert.NoError(err)
+
+		received := collectCounters(t, events, 4)
+		assert.Equal([]string{`{"counter":1}`, `{"counter":2}`, `{"counter":3}`}, received)
+		assert.Equal("Bearer token", (<-server.headers).Get("Authorization"))
+		assert.Equal(2, <-server.protocols)
+		assert.Equal(int32(1), atomic.LoadInt32(&server.connections), "subscription should share the query connection")
+	})
+
+	suite.T().Run("should return validation errors", func(t *testing.T) {
+		server := newSSEServer(1)
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSE)
+		_, err := b.Subscribe(context.Background(), "subscription { broken }", nil, nil)
+		assert.Error(err)
+
+		var gqlErrs Errors
+		assert.True(errors.As(err, &gqlErrs))
+		assert.True(gqlErrs.HasCode("validation-failed"))
+	})
+
+	suite.T().Run("should close the stream on cancel", func(t *testing.T) {
+		server := newSSEServer(0)
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSE)
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		<-events
+		cancel()
+		select {
+		case <-server.stopped:
+		case <-time.After(2 * time.Second):
+			t.Fatal("stream not closed")
+		}
+		for range events {
+		}
+	})
+
+	suite.T().Run("should re-execute the operation when the stream drops", func(t *testing.T) {
+		server := newSSEServer(0)
+		server.dropAfter = 1
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSE)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		assert.Equal([]string{`{"counter":1}`, `{"counter":1}`, `{"counter":2}`}, collectCounters(t, events, 3))
+	})
+}
+
+func (suite *Tests) TestBaseClient_Subscribe_SSESingle() {
+	suite.T().Run("should multiplex operations over one reserved stream", func(t *testing.T) {
+		server := newSSEServer(2)
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSESingle)
+		headers := map[string]interface{}{"x-hasura-role": "user"}
+		first, err := b.Subscribe(context.Background(), "subscription { counter }", nil, headers)
+		assert.NoError(err)
+		second, err := b.Subscribe(context.Background(), "subscription { counter }", nil, headers)
+		assert.NoError(err)
+
+		assert.Equal([]string{`{"counter":1}`, `{"counter":2}`}, collectCounters(t, first, 3))
+		assert.Equal([]string{`{"counter":1}`, `{"counter":2}`}, collectCounters(t, second, 3))
+		assert.Equal(int32(1), atomic.LoadInt32(&server.tokens))
+		assert.Equal("user", (<-server.headers).Get("x-hasura-role"))
+		assert.Equal(int32(1), atomic.LoadInt32(&server.connections))
+
+		b.subscription_mu.Lock()
+		assert.Empty(b.sse_conns)
+		b.subscription_mu.Unlock()
+	})
+
+	suite.T().Run("should stop the operation on cancel", func(t *testing.T) {
+		server := newSSEServer(0)
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSESingle)
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		<-events
+		id := <-server.operations
+		cancel()
+		select {
+		case stoppedID := <-server.stopped:
+			assert.Equal(id, stoppedID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("operation not stopped")
+		}
+		for range events {
+		}
+	})
+
+	suite.T().Run("should reserve a new stream and resubscribe after a drop", func(t *testing.T) {
+		server := newSSEServer(0)
+		server.dropAfter = 1
+		defer server.Close()
+
+		b := newSSETestClient(server, SubscriptionTransportSSESingle)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		received := collectCounters(t, events, 2)
+		assert.Equal([]string{`{"counter":1}`, `{"counter":1}`}, received)
+		assert.Equal(int32(2), atomic.LoadInt32(&server.tokens))
+	})
+}
+
+func (suite *Tests) TestReadSSEEvent() {
+	stream := ": keepalive\n\n" +
+		"event: next\ndata: {\"a\":1}\n\n" +
+		"event: next\r\ndata: line1\r\ndata: line2\r\n\r\n" +
+		"data: unnamed\n\n" +
+		"event: complete\n\n" +
+		"event: next\ndata: trunc"
+	reader := bufio.NewReader(strings.NewReader(stream))
+
+	expected := []sseEvent{
+		{name: "next", data: []byte(`{"a":1}`)},
+		{name: "next", data: []byte("line1\nline2")},
+		{name: "message", data: []byte("unnamed")},
+		{name: "complete"},
+	}
+	for _, want := range expected {
+		got, err := readSSEEvent(reader)
+		assert.NoError(err)
+		assert.Equal(want, got)
+	}
+	_, err := readSSEEvent(reader)
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+}
+
+func (suite *Tests) TestBaseClient_SetSubscriptionTransport() {
+	suite.T().Run("should default to websocket", func(t *testing.T) {
+		b := CreateTestClient()
+		assert.Equal(SubscriptionTransportWebSocket, b.subscriptionTransportSetting())
+	})
+
+	suite.T().Run("should ignore unknown transports", func(t *testing.T) {
+		b := CreateTestClient()
+		b.SetSubscriptionTransport(SubscriptionTransportSSESingle)
+		b.SetSubscriptionTransport("long-polling")
+		assert.Equal(SubscriptionTransportSSESingle, b.subscriptionTransportSetting())
+	})
+
+	suite.T().Run("should require an HTTP client", func(t *testing.T) {
+		b := CreateTestClient()
+		b.SetSubscriptionTransport(SubscriptionTransportSSE)
+		_, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.Error(err)
+	})
+}
@@ -0,0 +1,522 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"golang.org/x/net/websocket"
+)
+
+// subscriptionServer is a minimal stand-in for graphql-transport-ws and subscriptions-transport-ws
+// servers. Every subscription receives `events` numbered payloads followed by complete (or stays
+// open when events is 0).
+type subscriptionServer struct {
+	*httptest.Server
+	initPayloads chan json.RawMessage
+	subscribed   chan string
+	completed    chan string
+	protocols    []string // Subprotocols the server accepts, in order of preference
+	stall        string   // Never acknowledge connection_init payloads containing this
+	events       int
+	connections  int32
+	pings        int32
+	dropAfter    int32 // Close the first connection after this many results
+	terminated   int32
+}
+
+func newTransportWSServer(events int) *subscriptionServer {
+	return newSubscriptionServer(events, SubscriptionProtocolTransportWS)
+}
+
+func newSubscriptionServer(events int, protocols ...string) *subscriptionServer {
+	s := &subscriptionServer{
+		events:       events,
+		protocols:    protocols,
+		initPayloads: make(chan json.RawMessage, 16),
+		subscribed:   make(chan string, 16),
+		completed:    make(chan string, 16),
+	}
+	s.Server = httptest.NewServer(websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			for _, supported := range s.protocols {
+				for _, offered := range config.Protocol {
+					if supported == offered {
+						config.Protocol = []string{supported}
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("no supported subprotocol offered: %v", config.Protocol)
+		},
+		Handler: s.handle,
+	})
+	return s
+}
+
+func (s *subscriptionServer) handle(ws *websocket.Conn) {
+	connection := atomic.AddInt32(&s.connections, 1)
+	legacy := ws.Config().Protocol[0] == SubscriptionProtocolLegacyWS
+	var writeMu sync.Mutex
+	send := func(msg wsMessage) error {
+		data, _ := json.Marshal(msg)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return websocket.Message.Send(ws, string(data))
+	}
+
+	var sent int32
+	for {
+		msg, err := receiveWSMessage(ws)
+		if err != nil {
+			return
+		}
+		switch msg.Type {
+		case wsConnectionInit:
+			s.initPayloads <- msg.Payload
+			if s.stall != "" && strings.Contains(string(msg.Payload), s.stall) {
+				continue
+			}
+			if legacy {
+				send(wsMessage{Type: wsConnectionAck})
+				send(wsMessage{Type: wsKeepAlive})
+				go func() {
+					for send(wsMessage{Type: wsKeepAlive}) == nil {
+						time.Sleep(20 * time.Millisecond)
+					}
+				}()
+				continue
+			}
+			send(wsMessage{Type: wsPing})
+			send(wsMessage{Type: wsConnectionAck})
+		case wsPing:
+			atomic.AddInt32(&s.pings, 1)
+			send(wsMessage{Type: wsPong})
+		case wsSubscribe, wsStart:
+			s.subscribed <- msg.ID
+			next, errorPayload := wsNext, `[{"message":"Cannot query field \"broken\"","extensions":{"code":"validation-failed"}}]`
+			if legacy {
+				next, errorPayload = wsData, `{"message":"Cannot query field \"broken\"","extensions":{"code":"validation-failed"}}`
+			}
+			go func(id string, payload json.RawMessage) {
+				var req struct {
+					Query string `json:"query"`
+				}
+				json.Unmarshal(payload, &req)
+				if strings.Contains(req.Query, "broken") {
+					send(wsMessage{ID: id, Type: wsError, Payload: json.RawMessage(errorPayload)})
+					return
+				}
+				for i := 1; s.events == 0 || i <= s.events; i++ {
+					if err := send(wsMessage{ID: id, Type: next, Payload: json.RawMessage(`{"data":{"counter":` + strconv.Itoa(i) + `}}`)}); err != nil {
+						return
+					}
+					if connection == 1 && s.dropAfter > 0 && atomic.AddInt32(&sent, 1) >= s.dropAfter {
+						ws.Close()
+						return
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				if s.events > 0 {
+					send(wsMessage{ID: id, Type: wsComplete})
+				}
+			}(msg.ID, msg.Payload)
+		case wsComplete, wsStop:
+			s.completed <- msg.ID
+		case wsConnectionTerminate:
+			atomic.AddInt32(&s.terminated, 1)
+		}
+	}
+}
+
+func newSubscriptionTestClient(endpoint string) *BaseClient {
+	b := CreateTestClient()
+	b.endpoint = endpoint
+	b.subscription_keepalive = 50 * time.Millisecond
+	b.retries_delay = 10 * time.Millisecond
+	return b
+}
+
+func (suite *Tests) TestBaseClient_Subscribe() {
+	suite.T().Run("should receive events until complete", func(t *testing.T) {
+		server := newTransportWSServer(3)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, map[string]interface{}{"Authorization": "Bearer token"})
+		assert.NoError(err)
+
+		var received []string
+		for event := range events {
+			assert.Empty(event.Errors)
+			received = append(received, string(event.Data))
+		}
+		assert.Equal([]string{`{"counter":1}`, `{"counter":2}`, `{"counter":3}`}, received)
+
+		// connection_init carries the headers
+		initPayload := <-server.initPayloads
+		assert.JSONEq(`{"headers":{"Authorization":"Bearer token"}}`, string(initPayload))
+	})
+
+	suite.T().Run("should deliver subscription errors and close", func(t *testing.T) {
+		server := newTransportWSServer(1)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		events, err := b.Subscribe(context.Background(), "subscription { broken }", nil, nil)
+		assert.NoError(err)
+
+		event, ok := <-events
+		assert.True(ok)
+		assert.True(event.Errors.HasCode("validation-failed"))
+		_, ok = <-events
+		assert.False(ok)
+	})
+
+	suite.T().Run("should multiplex subscriptions over one socket", func(t *testing.T) {
+		server := newTransportWSServer(2)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		headers := map[string]interface{}{"x-hasura-role": "user"}
+		first, err := b.Subscribe(context.Background(), "subscription { counter }", nil, headers)
+		assert.NoError(err)
+		second, err := b.Subscribe(context.Background(), "subscription { counter }", nil, headers)
+		assert.NoError(err)
+
+		count := 0
+		for range first {
+			count++
+		}
+		for range second {
+			count++
+		}
+		assert.Equal(4, count)
+		assert.Equal(int32(1), atomic.LoadInt32(&server.connections))
+	})
+
+	suite.T().Run("should stop subscription on context cancel", func(t *testing.T) {
+		server := newTransportWSServer(0)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		<-events
+		id := <-server.subscribed
+		cancel()
+
+		select {
+		case completedID := <-server.completed:
+			assert.Equal(id, completedID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("complete message not received")
+		}
+		for range events {
+			// drain until closed
+		}
+
+		b.subscription_mu.Lock()
+		assert.Empty(b.subscription_conns)
+		b.subscription_mu.Unlock()
+	})
+
+	suite.T().Run("should keep the connection alive with pings", func(t *testing.T) {
+		server := newTransportWSServer(0)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		<-events
+		time.Sleep(200 * time.Millisecond)
+		assert.GreaterOrEqual(atomic.LoadInt32(&server.pings), int32(2))
+	})
+
+	suite.T().Run("should reconnect and resubscribe after connection loss", func(t *testing.T) {
+		server := newTransportWSServer(0)
+		server.dropAfter = 2
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		// Two events from the first connection, then the counter restarts on the new one
+		var received []string
+		timeout := time.After(5 * time.Second)
+		for len(received) < 4 {
+			select {
+			case event := <-events:
+				received = append(received, string(event.Data))
+			case <-timeout:
+				t.Fatalf("only received %v", received)
+			}
+		}
+		assert.Equal([]string{`{"counter":1}`, `{"counter":2}`, `{"counter":1}`, `{"counter":2}`}, received)
+		assert.Equal(int32(2), atomic.LoadInt32(&server.connections))
+	})
+
+	suite.T().Run("should connect other subscriptions while one connection is stuck connecting", func(t *testing.T) {
+		server := newTransportWSServer(1)
+		server.stall = "stalled"
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		stalled := map[string]interface{}{"x-hasura-role": "stalled"}
+		go b.Subscribe(context.Background(), "subscription { counter }", nil, stalled)
+		<-server.initPayloads
+
+		// Subscriptions with other headers don't wait for it
+		start := time.Now()
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.NoError(err)
+		assert.Equal(`{"counter":1}`, string((<-events).Data))
+		for range events {
+			// drain until closed, tearing the connection down
+		}
+		assert.Less(time.Since(start), time.Second)
+
+		// Those with the same headers wait, as long as their context lets them
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = b.Subscribe(ctx, "subscription { counter }", nil, stalled)
+		assert.ErrorIs(err, context.DeadlineExceeded)
+	})
+
+	suite.T().Run("should drop events for a subscriber falling behind", func(t *testing.T) {
+		server := newTransportWSServer(subscriptionBufferSize * 2)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		slow, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.NoError(err)
+		fast, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		// The subscriber reading keeps getting every event from the shared connection
+		received := 0
+		timeout := time.After(5 * time.Second)
+		for received < subscriptionBufferSize*2 {
+			select {
+			case <-fast:
+				received++
+			case <-timeout:
+				t.Fatalf("only received %d events", received)
+			}
+		}
+
+		dropped := subscriptionBufferSize * 2
+		for range slow {
+			dropped--
+		}
+		assert.Equal(subscriptionBufferSize, dropped)
+	})
+
+	suite.T().Run("should fail when the endpoint is unreachable", func(t *testing.T) {
+		b := newSubscriptionTestClient("http://127.0.0.1:1/graphql")
+		_, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.Error(err)
+	})
+
+	suite.T().Run("should fail on empty query", func(t *testing.T) {
+		b := newSubscriptionTestClient("http://127.0.0.1:1/graphql")
+		_, err := b.Subscribe(context.Background(), "", nil, nil)
+		assert.Error(err)
+	})
+}
+
+func (suite *Tests) TestBaseClient_subscriptionEndpoint() {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "https://example.com/v1/graphql", want: "wss://example.com/v1/graphql"},
+		{endpoint: "http://localhost:8080/v1/graphql", want: "ws://localhost:8080/v1/graphql"},
+		{endpoint: "ftp://example.com/graphql", wantErr: true},
+	}
+	for _, tt := range tests {
+		suite.T().Run(tt.endpoint, func(t *testing.T) {
+			b := CreateTestClient()
+			b.endpoint = tt.endpoint
+			got, err := b.subscriptionEndpoint()
+			if tt.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tt.want, got)
+		})
+	}
+}
+
+func (suite *Tests) TestBaseClient_Subscribe_legacyProtocol() {
+	suite.T().Run("should negotiate legacy protocol with legacy-only server", func(t *testing.T) {
+		server := newSubscriptionServer(3, SubscriptionProtocolLegacyWS)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, map[string]interface{}{"x-hasura-role": "user"})
+		assert.NoError(err)
+
+		var received []string
+		for event := range events {
+			received = append(received, string(event.Data))
+		}
+		assert.Equal([]string{`{"counter":1}`, `{"counter":2}`, `{"counter":3}`}, received)
+		assert.JSONEq(`{"headers":{"x-hasura-role":"user"}}`, string(<-server.initPayloads))
+		assert.Equal(int32(0), atomic.LoadInt32(&server.pings))
+	})
+
+	suite.T().Run("should prefer graphql-transport-ws when server supports both", func(t *testing.T) {
+		server := newSubscriptionServer(1, SubscriptionProtocolLegacyWS, SubscriptionProtocolTransportWS)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		b.SetSubscriptionProtocol(SubscriptionProtocolTransportWS)
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.NoError(err)
+		for range events {
+		}
+	})
+
+	suite.T().Run("should use configured legacy protocol", func(t *testing.T) {
+		server := newSubscriptionServer(1, SubscriptionProtocolTransportWS, SubscriptionProtocolLegacyWS)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		b.SetSubscriptionProtocol(SubscriptionProtocolLegacyWS)
+		events, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		event := <-events
+		assert.Equal(`{"counter":1}`, string(event.Data))
+	})
+
+	suite.T().Run("should fail when server doesn't support configured protocol", func(t *testing.T) {
+		server := newSubscriptionServer(1, SubscriptionProtocolTransportWS)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		b.SetSubscriptionProtocol(SubscriptionProtocolLegacyWS)
+		_, err := b.Subscribe(context.Background(), "subscription { counter }", nil, nil)
+		assert.Error(err)
+	})
+
+	suite.T().Run("should deliver legacy error objects", func(t *testing.T) {
+		server := newSubscriptionServer(1, SubscriptionProtocolLegacyWS)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		events, err := b.Subscribe(context.Background(), "subscription { broken }", nil, nil)
+		assert.NoError(err)
+
+		event := <-events
+		assert.True(event.Errors.HasCode("validation-failed"))
+		_, ok := <-events
+		assert.False(ok)
+	})
+
+	suite.T().Run("should send stop and connection_terminate on cancel", func(t *testing.T) {
+		server := newSubscriptionServer(0, SubscriptionProtocolLegacyWS)
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		<-events
+		id := <-server.subscribed
+		cancel()
+
+		select {
+		case stoppedID := <-server.completed:
+			assert.Equal(id, stoppedID)
+		case <-time.After(2 * time.Second):
+			t.Fatal("stop message not received")
+		}
+		for range events {
+		}
+		assert.Eventually(func() bool {
+			return atomic.LoadInt32(&server.terminated) == 1
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	suite.T().Run("should reconnect legacy connection", func(t *testing.T) {
+		server := newSubscriptionServer(0, SubscriptionProtocolLegacyWS)
+		server.dropAfter = 1
+		defer server.Close()
+
+		b := newSubscriptionTestClient(server.URL)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events, err := b.Subscribe(ctx, "subscription { counter }", nil, nil)
+		assert.NoError(err)
+
+		var received []string
+		timeout := time.After(5 * time.Second)
+		for len(received) < 2 {
+			select {
+			case event := <-events:
+				received = append(received, string(event.Data))
+			case <-timeout:
+				t.Fatalf("only received %v", received)
+			}
+		}
+		assert.Equal([]string{`{"counter":1}`, `{"counter":1}`}, received)
+	})
+}
+
+func (suite *Tests) TestParseSubscriptionErrors() {
+	tests := []struct {
+		name    string
+		payload string
+		want    Errors
+	}{
+		{name: "array", payload: `[{"message":"first"},{"message":"second"}]`, want: Errors{{Message: "first"}, {Message: "second"}}},
+		{name: "object", payload: `{"message":"single"}`, want: Errors{{Message: "single"}}},
+		{name: "plain", payload: `"unauthorized"`, want: Errors{{Message: `"unauthorized"`}}},
+	}
+	for _, tt := range tests {
+		suite.T().Run(tt.name, func(t *testing.T) {
+			assert.Equal(tt.want, parseSubscriptionErrors(json.RawMessage(tt.payload)))
+		})
+	}
+}
+
+func (suite *Tests) TestBaseClient_SetSubscriptionProtocol() {
+	suite.T().Run("should accept known protocols", func(t *testing.T) {
+		b := CreateTestClient()
+		assert.Equal([]string{SubscriptionProtocolTransportWS, SubscriptionProtocolLegacyWS}, b.offeredSubscriptionProtocols())
+
+		b.SetSubscriptionProtocol(SubscriptionProtocolLegacyWS)
+		assert.Equal([]string{SubscriptionProtocolLegacyWS}, b.offeredSubscriptionProtocols())
+
+		b.SetSubscriptionProtocol(SubscriptionProtocolTransportWS)
+		assert.Equal([]string{SubscriptionProtocolTransportWS}, b.offeredSubscriptionProtocols())
+	})
+
+	suite.T().Run("should ignore unknown protocols", func(t *testing.T) {
+		b := CreateTestClient()
+		b.SetSubscriptionProtocol(SubscriptionProtocolLegacyWS)
+		b.SetSubscriptionProtocol("graphql-sse")
+		assert.Equal(SubscriptionProtocolLegacyWS, b.subscriptionProtocolSetting())
+	})
+}
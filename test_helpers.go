@@ -65,5 +65,6 @@ func CreateTestClient() *BaseClient {
 		endpoint:       "https://example.com/graphql",
 		responseType:   "mapstring",
 		minify_queries: true, // Enable query minification by default for tests
+		clientState:    &clientState{},
 	}
 }
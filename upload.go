@@ -0,0 +1,186 @@
+package gql
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Upload is a file sent following the GraphQL multipart request spec. Placing an Upload (or a
+// pointer to one) anywhere in the variables, including inside lists and nested input objects,
+// switches the request to multipart/form-data. Reader is streamed once - such requests are
+// never retried - and closed afterwards if it implements io.Closer.
+type Upload struct {
+	Reader      io.Reader
+	Filename    string
+	ContentType string
+}
+
+// uploadFile is one file part of the request with every variable path it is mapped to
+type uploadFile struct {
+	upload *Upload
+	paths  []string
+}
+
+var filenameEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// extractUploads replaces every Upload in variables with null, as the spec requires, and
+// returns the files to send. Variables without uploads are returned untouched.
+func extractUploads(variables map[string]interface{}) (map[string]interface{}, []uploadFile, error) {
+	if !containsUpload(variables) {
+		return variables, nil, nil
+	}
+
+	var files []uploadFile
+	seen := make(map[*Upload]int)
+	cleaned := replaceUploads(variables, "variables", &files, seen).(map[string]interface{})
+	for _, file := range files {
+		if file.upload.Reader == nil {
+			return nil, nil, fmt.Errorf("upload at %s has no reader", file.paths[0])
+		}
+	}
+	return cleaned, files, nil
+}
+
+func containsUpload(value interface{}) bool {
+	switch v := value.(type) {
+	case Upload, *Upload, []Upload, []*Upload:
+		return true
+	case map[string]interface{}:
+		for _, item := range v {
+			if containsUpload(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if containsUpload(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaceUploads copies value with uploads nulled out. The same *Upload used in several
+// places is sent once and mapped to all of its paths.
+func replaceUploads(value interface{}, path string, files *[]uploadFile, seen map[*Upload]int) interface{} {
+	register := func(upload *Upload, path string) {
+		if index, ok := seen[upload]; ok {
+			(*files)[index].paths = append((*files)[index].paths, path)
+			return
+		}
+		seen[upload] = len(*files)
+		*files = append(*files, uploadFile{upload: upload, paths: []string{path}})
+	}
+
+	switch v := value.(type) {
+	case Upload:
+		register(&v, path)
+		return nil
+	case *Upload:
+		if v != nil {
+			register(v, path)
+		}
+		return nil
+	case []Upload:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			register(&v[i], path+"."+strconv.Itoa(i))
+		}
+		return list
+	case []*Upload:
+		list := make([]interface{}, len(v))
+		for i, upload := range v {
+			if upload != nil {
+				register(upload, path+"."+strconv.Itoa(i))
+			}
+		}
+		return list
+	case map[string]interface{}:
+		// Sorted keys keep the numbering of the file parts stable
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		copied := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			copied[key] = replaceUploads(v[key], path+"."+key, files, seen)
+		}
+		return copied
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = replaceUploads(item, path+"."+strconv.Itoa(i), files, seen)
+		}
+		return list
+	}
+	return value
+}
+
+// uploadBody streams the multipart request through a pipe, so files are never buffered in memory
+func (qe *QueryExecutor) uploadBody() (io.ReadCloser, string) {
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(qe.writeUploadForm(form))
+	}()
+	return reader, form.FormDataContentType()
+}
+
+// writeUploadForm writes the operations and map fields followed by the files, in the order the spec requires
+func (qe *QueryExecutor) writeUploadForm(form *multipart.Writer) error {
+	defer func() {
+		for _, file := range qe.Uploads {
+			if closer, ok := file.upload.Reader.(io.Closer); ok {
+				closer.Close()
+			}
+		}
+	}()
+
+	if err := form.WriteField("operations", string(qe.Query)); err != nil {
+		return err
+	}
+
+	fileMap := make(map[string][]string, len(qe.Uploads))
+	for i, file := range qe.Uploads {
+		fileMap[strconv.Itoa(i)] = file.paths
+	}
+	mapField, err := json.Marshal(fileMap)
+	if err != nil {
+		return fmt.Errorf("can't encode upload map: %w", err)
+	}
+	if err := form.WriteField("map", string(mapField)); err != nil {
+		return err
+	}
+
+	for i, file := range qe.Uploads {
+		filename := file.upload.Filename
+		if filename == "" {
+			filename = "upload"
+		}
+		contentType := file.upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename="%s"`, i, filenameEscaper.Replace(filename)))
+		header.Set("Content-Type", contentType)
+		part, err := form.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, file.upload.Reader); err != nil {
+			return fmt.Errorf("can't read upload %s: %w", filename, err)
+		}
+	}
+	return form.Close()
+}
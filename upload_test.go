@@ -0,0 +1,187 @@
+package gql
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+type uploadRequest struct {
+	operations    string
+	fileMap       string
+	files         map[string]string
+	filenames     map[string]string
+	contentTypes  map[string]string
+	contentLength int64
+}
+
+// newUploadServer records the multipart request and answers with a fixed result
+func newUploadServer(status int) (*httptest.Server, chan uploadRequest, *int32) {
+	requests := make(chan uploadRequest, 4)
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		received := uploadRequest{
+			contentLength: r.ContentLength,
+			files:         map[string]string{},
+			filenames:     map[string]string{},
+			contentTypes:  map[string]string{},
+		}
+		reader, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			content, _ := io.ReadAll(part)
+			switch part.FormName() {
+			case "operations":
+				received.operations = string(content)
+			case "map":
+				received.fileMap = string(content)
+			default:
+				received.files[part.FormName()] = string(content)
+				received.filenames[part.FormName()] = part.FileName()
+				received.contentTypes[part.FormName()] = part.Header.Get("Content-Type")
+			}
+		}
+		requests <- received
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":{"uploadImage":{"id":7}}}`))
+	}))
+	return server, requests, &count
+}
+
+func (suite *Tests) TestBaseClient_Upload() {
+	suite.T().Run("should send a single file as multipart request", func(t *testing.T) {
+		server, requests, _ := newUploadServer(http.StatusOK)
+		defer server.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(server.URL)
+		b.SetHTTPClient(server.Client())
+		b.SetOutput("string")
+
+		file := &closeTracker{Reader: strings.NewReader("PNGDATA")}
+		variables := map[string]interface{}{
+			"image": Upload{Filename: "cat.png", ContentType: "image/png", Reader: file},
+			"title": "Cat",
+		}
+		result, err := b.MutateContext(context.Background(), `mutation ($image: Upload!, $title: String!) { uploadImage(image: $image, title: $title) { id } }`, variables, nil)
+		assert.NoError(err)
+		assert.Equal(`{"uploadImage":{"id":7}}`, result)
+
+		received := <-requests
+		var operations map[string]interface{}
+		assert.NoError(json.Unmarshal([]byte(received.operations), &operations))
+		assert.Equal(map[string]interface{}{"image": nil, "title": "Cat"}, operations["variables"])
+		assert.JSONEq(`{"0":["variables.image"]}`, received.fileMap)
+		assert.Equal("PNGDATA", received.files["0"])
+		assert.Equal("cat.png", received.filenames["0"])
+		assert.Equal("image/png", received.contentTypes["0"])
+		assert.Equal(int64(-1), received.contentLength, "body should be streamed")
+		assert.True(file.closed)
+
+		// The caller's variables keep the upload
+		assert.IsType(Upload{}, variables["image"])
+	})
+
+	suite.T().Run("should map files in lists and nested inputs", func(t *testing.T) {
+		server, requests, _ := newUploadServer(http.StatusOK)
+		defer server.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(server.URL)
+		b.SetHTTPClient(server.Client())
+
+		shared := &Upload{Filename: "a.txt", Reader: strings.NewReader("A")}
+		variables := map[string]interface{}{
+			"files": []*Upload{shared, {Filename: "b.txt", Reader: strings.NewReader("B")}},
+			"input": map[string]interface{}{"avatar": shared, "name": "x"},
+		}
+		_, err := b.MutateContext(context.Background(), `mutation ($files: [Upload!]!, $input: ProfileInput!) { uploadImage(files: $files, input: $input) { id } }`, variables, nil)
+		assert.NoError(err)
+
+		received := <-requests
+		assert.JSONEq(`{"0":["variables.files.0","variables.input.avatar"],"1":["variables.files.1"]}`, received.fileMap)
+		assert.Equal(map[string]string{"0": "A", "1": "B"}, received.files)
+		assert.Equal("application/octet-stream", received.contentTypes["0"])
+		assert.Contains(received.operations, `"files":[null,null]`)
+	})
+
+	suite.T().Run("should not retry uploads", func(t *testing.T) {
+		server, requests, count := newUploadServer(http.StatusInternalServerError)
+		defer server.Close()
+
+		b := NewConnection()
+		b.SetEndpoint(server.URL)
+		b.SetHTTPClient(server.Client())
+		b.retries_enable = true
+		b.retries_number = 3
+		b.retries_delay = 0
+
+		_, err := b.MutateContext(context.Background(), `mutation ($image: Upload!) { uploadImage(image: $image) { id } }`, map[string]interface{}{
+			"image": Upload{Filename: "cat.png", Reader: strings.NewReader("PNG")},
+		}, nil)
+		assert.Error(err)
+		<-requests
+		assert.Equal(int32(1), atomic.LoadInt32(count))
+	})
+
+	suite.T().Run("should reject uploads without reader", func(t *testing.T) {
+		b := CreateTestClient()
+		_, err := b.MutateContext(context.Background(), `mutation ($image: Upload!) { uploadImage(image: $image) { id } }`, map[string]interface{}{
+			"image": Upload{Filename: "cat.png"},
+		}, nil)
+		assert.ErrorContains(err, "variables.image")
+	})
+}
+
+func (suite *Tests) TestExtractUploads() {
+	suite.T().Run("should leave variables without uploads untouched", func(t *testing.T) {
+		variables := map[string]interface{}{"id": 1, "tags": []interface{}{"a"}}
+		cleaned, files, err := extractUploads(variables)
+		assert.NoError(err)
+		assert.Nil(files)
+		cleaned["marker"] = true
+		assert.Equal(true, variables["marker"], "the same map should be returned")
+	})
+
+	suite.T().Run("should replace uploads with null", func(t *testing.T) {
+		upload := Upload{Reader: strings.NewReader("x")}
+		cleaned, files, err := extractUploads(map[string]interface{}{
+			"docs": []Upload{upload, upload},
+			"list": []interface{}{map[string]interface{}{"file": &upload}},
+		})
+		assert.NoError(err)
+		assert.Equal(map[string]interface{}{
+			"docs": []interface{}{nil, nil},
+			"list": []interface{}{map[string]interface{}{"file": nil}},
+		}, cleaned)
+		assert.Len(files, 3)
+		assert.Equal([]string{"variables.docs.0"}, files[0].paths)
+		assert.Equal([]string{"variables.list.0.file"}, files[2].paths)
+	})
+}
@@ -0,0 +1,746 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lukaszraczylo/go-simple-graphql/ast"
+	libpack_logger "github.com/lukaszraczylo/go-simple-graphql/logging"
+)
+
+// Validation modes for SetValidation
+const (
+	ValidationOff    = "off"    // Operations are sent without being checked
+	ValidationWarn   = "warn"   // Problems are logged and the operation is sent anyway
+	ValidationStrict = "strict" // Operations with problems fail without being sent
+)
+
+// ValidationError is a problem found in an operation before sending it
+type ValidationError struct {
+	Message string
+	Line    int
+	Column  int
+	Warning bool // Deprecated usage - logged, but never fails the operation
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors are the problems found in an operation, in document order
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid operation: " + strings.Join(messages, "; ")
+}
+
+// failures leaves out the warnings
+func (e ValidationErrors) failures() ValidationErrors {
+	var failures ValidationErrors
+	for _, err := range e {
+		if !err.Warning {
+			failures = append(failures, err)
+		}
+	}
+	return failures
+}
+
+// SetSchema sets the schema operations are validated against, see SetValidation
+func (b *BaseClient) SetSchema(schema *Schema) {
+	b.schema = schema
+	b.Logger.Debug(&libpack_logger.LogMessage{
+		Message: "GraphQL schema updated",
+		Pairs:   map[string]interface{}{"types": len(schema.Types)},
+	})
+}
+
+// SetValidation checks queries and mutations against the schema before sending them: ValidationWarn
+// logs the problems, ValidationStrict fails the operation. Deprecated usage is only ever logged.
+func (b *BaseClient) SetValidation(mode string) {
+	switch mode {
+	case ValidationOff, ValidationWarn, ValidationStrict:
+		b.validation = mode
+		b.Logger.Debug(&libpack_logger.LogMessage{
+			Message: "GraphQL validation mode updated",
+			Pairs:   map[string]interface{}{"validation": mode},
+		})
+	default:
+		b.Logger.Error(&libpack_logger.LogMessage{
+			Message: "Unknown validation mode - keeping current setting",
+			Pairs:   map[string]interface{}{"requested": mode, "current": b.validation},
+		})
+	}
+}
+
+// WithSchema sets the schema operations are validated against, as SetSchema does
+func WithSchema(schema *Schema) Option {
+	return func(b *BaseClient) error {
+		if schema == nil {
+			return errors.New("invalid schema: nil")
+		}
+		b.schema = schema
+		return nil
+	}
+}
+
+// WithValidation validates operations against the schema, as SetValidation does
+func WithValidation(mode string) Option {
+	return func(b *BaseClient) error {
+		switch mode {
+		case ValidationOff, ValidationWarn, ValidationStrict:
+			b.validation = mode
+			return nil
+		}
+		return fmt.Errorf("invalid validation mode %q", mode)
+	}
+}
+
+// validateOperation checks the operation of req, sent with variables, when validation is enabled
+// and a schema is set. Required variables are checked for the operation req runs. It only
+// returns an error in strict mode.
+func (b *BaseClient) validateOperation(req *Request, variables map[string]interface{}) error {
+	if b.schema == nil || (b.validation != ValidationWarn && b.validation != ValidationStrict) {
+		return nil
+	}
+	doc, err := req.parse()
+	problems := syntaxProblems(err)
+	if err == nil {
+		problems = b.schema.validateDocument(doc, req.OperationName, variables)
+	}
+	for _, problem := range problems {
+		b.Logger.Warning(&libpack_logger.LogMessage{
+			Message: "GraphQL validation problem",
+			Pairs: map[string]interface{}{
+				"error":  problem.Message,
+				"line":   problem.Line,
+				"column": problem.Column,
+			},
+		})
+	}
+	if failures := problems.failures(); len(failures) > 0 && b.validation == ValidationStrict {
+		return failures
+	}
+	return nil
+}
+
+// Validate checks an operation against the schema: its syntax, fields, arguments, variables
+// and fragments. Variables declared as required must be present in variables when the document
+// holds a single operation. Deprecated usage is reported as warnings.
+func (s *Schema) Validate(query string, variables map[string]interface{}) ValidationErrors {
+	return s.ValidateOperation(query, "", variables)
+}
+
+// ValidateOperation checks a document like Validate does, with the required variables of the
+// operation named operationName checked against variables. The other operations of the
+// document are checked without them.
+func (s *Schema) ValidateOperation(query, operationName string, variables map[string]interface{}) ValidationErrors {
+	doc, err := ast.Parse(query)
+	if err != nil {
+		return syntaxProblems(err)
+	}
+	return s.validateDocument(doc, operationName, variables)
+}
+
+// syntaxProblems reports a document that doesn't parse, nil when err is nil
+func syntaxProblems(err error) ValidationErrors {
+	if err == nil {
+		return nil
+	}
+	var syntaxErr *ast.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return ValidationErrors{{Message: "Syntax Error: " + syntaxErr.Message, Line: syntaxErr.Line, Column: syntaxErr.Column}}
+	}
+	return ValidationErrors{{Message: err.Error()}}
+}
+
+// validateDocument checks an already parsed document, see ValidateOperation
+func (s *Schema) validateDocument(doc *ast.Document, operationName string, variables map[string]interface{}) ValidationErrors {
+	v := &validator{
+		schema:    s,
+		fragments: make(map[string]*ast.FragmentDefinition, len(doc.Fragments)),
+		spread:    make(map[string]bool),
+		reported:  make(map[string]bool),
+	}
+	v.checkFragments(doc.Fragments)
+	for _, op := range doc.Operations {
+		selected := (operationName == "" && len(doc.Operations) == 1) || (operationName != "" && op.Name == operationName)
+		v.checkOperation(op, selected, variables)
+	}
+	for _, fragment := range doc.Fragments {
+		if !v.spread[fragment.Name] {
+			v.report(fragment.Position, false, "Fragment %q is never used.", fragment.Name)
+		}
+	}
+
+	sort.SliceStable(v.errs, func(i, j int) bool {
+		if v.errs[i].Line != v.errs[j].Line {
+			return v.errs[i].Line < v.errs[j].Line
+		}
+		return v.errs[i].Column < v.errs[j].Column
+	})
+	return v.errs
+}
+
+// validator walks a document, checking it against the schema
+type validator struct {
+	schema    *Schema
+	fragments map[string]*ast.FragmentDefinition
+	spread    map[string]bool // Fragments spread by any operation
+	reported  map[string]bool // Problems already reported, fragments are checked once per operation
+	errs      ValidationErrors
+
+	// State of the operation being checked
+	variables map[string]*ast.VariableDefinition
+	used      map[string]bool // Variables the operation uses
+	visited   map[string]bool // Fragments already checked for the operation
+}
+
+func (v *validator) report(at ast.Position, warning bool, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	key := fmt.Sprintf("%s %s", at, message)
+	if v.reported[key] {
+		return
+	}
+	v.reported[key] = true
+	v.errs = append(v.errs, &ValidationError{Message: message, Line: at.Line, Column: at.Column, Warning: warning})
+}
+
+func (v *validator) checkFragments(fragments []*ast.FragmentDefinition) {
+	for _, fragment := range fragments {
+		if _, duplicate := v.fragments[fragment.Name]; duplicate {
+			v.report(fragment.Position, false, "There can be only one fragment named %q.", fragment.Name)
+			continue
+		}
+		v.fragments[fragment.Name] = fragment
+
+		t := v.schema.Type(fragment.TypeCondition)
+		switch {
+		case t == nil && isIntrospectionType(fragment.TypeCondition):
+			// Schemas read from SDL don't describe the introspection types
+		case t == nil:
+			v.report(fragment.Position, false, "Unknown type %q.%s", fragment.TypeCondition, suggestion(fragment.TypeCondition, v.typeNames()))
+		case !isCompositeType(t):
+			v.report(fragment.Position, false, "Fragment %q cannot condition on non composite type %q.", fragment.Name, fragment.TypeCondition)
+		}
+	}
+}
+
+func (v *validator) checkOperation(op *ast.OperationDefinition, selected bool, values map[string]interface{}) {
+	v.variables = make(map[string]*ast.VariableDefinition, len(op.VariableDefinitions))
+	v.used = make(map[string]bool)
+	v.visited = make(map[string]bool)
+
+	for _, definition := range op.VariableDefinitions {
+		if _, duplicate := v.variables[definition.Variable]; duplicate {
+			v.report(definition.Position, false, "There can be only one variable named \"$%s\".", definition.Variable)
+			continue
+		}
+		v.variables[definition.Variable] = definition
+
+		t := v.schema.Type(definition.Type.NamedType())
+		switch {
+		case t == nil:
+			v.report(definition.Type.Position, false, "Unknown type %q.%s", definition.Type.NamedType(), suggestion(definition.Type.NamedType(), v.typeNames()))
+			continue
+		case t.Kind != KindScalar && t.Kind != KindEnum && t.Kind != KindInputObject:
+			v.report(definition.Type.Position, false, "Variable \"$%s\" cannot be non-input type %q.", definition.Variable, definition.Type)
+			continue
+		}
+		if definition.DefaultValue != nil {
+			v.checkValue(definition.DefaultValue, typeRefOf(definition.Type))
+		}
+		if selected && definition.Type.NonNull && definition.DefaultValue == nil {
+			if value, ok := values[definition.Variable]; !ok || value == nil {
+				v.report(definition.Position, false, "Variable \"$%s\" of required type %q was not provided.", definition.Variable, definition.Type)
+			}
+		}
+	}
+
+	root := v.schema.RootType(op.Operation)
+	if root == nil {
+		v.report(op.Position, false, "Schema is not configured for %ss.", op.Operation)
+		return
+	}
+	v.checkDirectives(op.Directives)
+	v.checkSelections(op.SelectionSet, root)
+
+	for _, definition := range op.VariableDefinitions {
+		if !v.used[definition.Variable] {
+			v.report(definition.Position, false, "Variable \"$%s\" is never used%s.", definition.Variable, operationLabel(op))
+		}
+	}
+}
+
+// operationLabel names the operation in messages
+func operationLabel(op *ast.OperationDefinition) string {
+	if op.Name == "" {
+		return ""
+	}
+	return fmt.Sprintf(" in operation %q", op.Name)
+}
+
+func (v *validator) checkSelections(selections ast.SelectionSet, parent *SchemaType) {
+	for _, s := range selections {
+		switch s := s.(type) {
+		case *ast.Field:
+			v.checkField(s, parent)
+		case *ast.FragmentSpread:
+			v.checkDirectives(s.Directives)
+			v.checkSpread(s, parent)
+		case *ast.InlineFragment:
+			v.checkDirectives(s.Directives)
+			target := parent
+			if s.TypeCondition != "" {
+				if target = v.schema.Type(s.TypeCondition); target == nil {
+					v.report(s.Position, false, "Unknown type %q.%s", s.TypeCondition, suggestion(s.TypeCondition, v.typeNames()))
+					continue
+				}
+				if !isCompositeType(target) {
+					v.report(s.Position, false, "Fragment cannot condition on non composite type %q.", s.TypeCondition)
+					continue
+				}
+				if !v.overlap(parent, target) {
+					v.report(s.Position, false, "Fragment cannot be spread here as objects of type %q can never be of type %q.", parent.Name, target.Name)
+					continue
+				}
+			}
+			v.checkSelections(s.SelectionSet, target)
+		}
+	}
+}
+
+func (v *validator) checkSpread(spread *ast.FragmentSpread, parent *SchemaType) {
+	fragment, ok := v.fragments[spread.Name]
+	if !ok {
+		v.report(spread.Position, false, "Unknown fragment %q.%s", spread.Name, suggestion(spread.Name, mapKeys(v.fragments)))
+		return
+	}
+	v.spread[spread.Name] = true
+
+	target := v.schema.Type(fragment.TypeCondition)
+	if target == nil && isIntrospectionType(fragment.TypeCondition) {
+		v.skimSpread(spread)
+		return
+	}
+	if target == nil || !isCompositeType(target) {
+		return // Reported with the fragment
+	}
+	if !v.overlap(parent, target) {
+		v.report(spread.Position, false, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", spread.Name, parent.Name, target.Name)
+		return
+	}
+	if v.visited[spread.Name] {
+		return
+	}
+	v.visited[spread.Name] = true
+	v.checkDirectives(fragment.Directives)
+	v.checkSelections(fragment.SelectionSet, target)
+}
+
+func (v *validator) checkField(field *ast.Field, parent *SchemaType) {
+	v.checkDirectives(field.Directives)
+	if field.Name == "__typename" {
+		if len(field.SelectionSet) > 0 {
+			v.report(field.Position, false, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+		}
+		return
+	}
+	if name, ok := introspectionFields[field.Name]; ok && parent == v.schema.RootType(OperationQuery) {
+		// Introspection is answered by every server; its types are only checked when the
+		// schema describes them
+		for _, arg := range field.Arguments {
+			v.useVariables(arg.Value)
+		}
+		if t := v.schema.Type(name); t != nil {
+			v.checkSelections(field.SelectionSet, t)
+		} else {
+			v.skimSelections(field.SelectionSet)
+		}
+		return
+	}
+
+	definition := parent.Field(field.Name)
+	if definition == nil {
+		names := make([]string, len(parent.Fields))
+		for i, f := range parent.Fields {
+			names[i] = f.Name
+		}
+		v.report(field.Position, false, "Cannot query field %q on type %q.%s", field.Name, parent.Name, suggestion(field.Name, names))
+		return
+	}
+	if definition.IsDeprecated {
+		v.report(field.Position, true, "The field \"%s.%s\" is deprecated. %s", parent.Name, field.Name, definition.DeprecationReason)
+	}
+	v.checkArguments(field.Arguments, definition.Args, fmt.Sprintf("field \"%s.%s\"", parent.Name, field.Name), field.Position)
+
+	t := v.schema.Type(definition.Type.NamedType())
+	switch {
+	case t == nil:
+		return
+	case isCompositeType(t) && len(field.SelectionSet) == 0:
+		v.report(field.Position, false, "Field %q of type %q must have a selection of subfields.", field.Name, definition.Type)
+	case !isCompositeType(t) && len(field.SelectionSet) > 0:
+		v.report(field.Position, false, "Field %q must not have a selection since type %q has no subfields.", field.Name, definition.Type)
+	case len(field.SelectionSet) > 0:
+		v.checkSelections(field.SelectionSet, t)
+	}
+}
+
+// introspectionFields are the root fields of introspection, with the type they return
+var introspectionFields = map[string]string{"__schema": "__Schema", "__type": "__Type"}
+
+// isIntrospectionType reports whether name is reserved for the introspection system
+func isIntrospectionType(name string) bool {
+	return strings.HasPrefix(name, "__")
+}
+
+// skimSelections walks selections the schema can't check, only recording the fragments they
+// spread and the variables they use
+func (v *validator) skimSelections(selections ast.SelectionSet) {
+	for _, s := range selections {
+		switch s := s.(type) {
+		case *ast.Field:
+			v.checkDirectives(s.Directives)
+			for _, arg := range s.Arguments {
+				v.useVariables(arg.Value)
+			}
+			v.skimSelections(s.SelectionSet)
+		case *ast.FragmentSpread:
+			v.checkDirectives(s.Directives)
+			if _, ok := v.fragments[s.Name]; !ok {
+				v.report(s.Position, false, "Unknown fragment %q.%s", s.Name, suggestion(s.Name, mapKeys(v.fragments)))
+				continue
+			}
+			v.spread[s.Name] = true
+			v.skimSpread(s)
+		case *ast.InlineFragment:
+			v.checkDirectives(s.Directives)
+			v.skimSelections(s.SelectionSet)
+		}
+	}
+}
+
+// skimSpread skims the fragment spread, once per operation
+func (v *validator) skimSpread(spread *ast.FragmentSpread) {
+	if v.visited[spread.Name] {
+		return
+	}
+	v.visited[spread.Name] = true
+	fragment := v.fragments[spread.Name]
+	v.checkDirectives(fragment.Directives)
+	v.skimSelections(fragment.SelectionSet)
+}
+
+// useVariables records the variables a value refers to, checking they're defined
+func (v *validator) useVariables(value *ast.Value) {
+	switch value.Kind {
+	case ast.Variable:
+		v.used[value.Raw] = true
+		if _, ok := v.variables[value.Raw]; !ok {
+			v.report(value.Position, false, "Variable \"$%s\" is not defined.", value.Raw)
+		}
+	case ast.ListValue:
+		for _, item := range value.List {
+			v.useVariables(item)
+		}
+	case ast.ObjectValue:
+		for _, field := range value.Fields {
+			v.useVariables(field.Value)
+		}
+	}
+}
+
+// checkArguments checks the arguments given against the ones accepted by what - a field or a directive
+func (v *validator) checkArguments(arguments []*ast.Argument, accepted []*InputValue, what string, at ast.Position) {
+	given := make(map[string]bool, len(arguments))
+	for _, arg := range arguments {
+		if given[arg.Name] {
+			v.report(arg.Position, false, "There can be only one argument named %q.", arg.Name)
+			continue
+		}
+		given[arg.Name] = true
+
+		var definition *InputValue
+		names := make([]string, len(accepted))
+		for i, a := range accepted {
+			names[i] = a.Name
+			if a.Name == arg.Name {
+				definition = a
+			}
+		}
+		if definition == nil {
+			v.report(arg.Position, false, "Unknown argument %q on %s.%s", arg.Name, what, suggestion(arg.Name, names))
+			continue
+		}
+		v.checkValue(arg.Value, definition.Type)
+	}
+
+	for _, a := range accepted {
+		if a.Type.IsNonNull() && a.DefaultValue == nil && !given[a.Name] {
+			v.report(at, false, "Argument %q of type %q is required on %s, but it was not provided.", a.Name, a.Type, what)
+		}
+	}
+}
+
+func (v *validator) checkDirectives(directives []*ast.Directive) {
+	for _, d := range directives {
+		definition := v.schema.Directive(d.Name)
+		if definition == nil {
+			names := make([]string, len(v.schema.Directives))
+			for i, known := range v.schema.Directives {
+				names[i] = known.Name
+			}
+			v.report(d.Position, false, "Unknown directive \"@%s\".%s", d.Name, suggestion(d.Name, names))
+			continue
+		}
+		v.checkArguments(d.Arguments, definition.Args, "directive \"@"+d.Name+"\"", d.Position)
+	}
+}
+
+// checkValue checks a literal or variable used where a value of type t is expected
+func (v *validator) checkValue(value *ast.Value, t *TypeRef) {
+	if value.Kind == ast.Variable {
+		v.used[value.Raw] = true
+		definition, ok := v.variables[value.Raw]
+		if !ok {
+			v.report(value.Position, false, "Variable \"$%s\" is not defined.", value.Raw)
+			return
+		}
+		if !variableFits(definition, t) {
+			v.report(value.Position, false, "Variable \"$%s\" of type %q used in position expecting type %q.", value.Raw, definition.Type, t)
+		}
+		return
+	}
+
+	if value.Kind == ast.NullValue {
+		if t.IsNonNull() {
+			v.report(value.Position, false, "Expected value of type %q, found null.", t)
+		}
+		return
+	}
+	if t.Kind == KindNonNull {
+		t = t.OfType
+	}
+	if t.Kind == KindList {
+		if value.Kind != ast.ListValue {
+			v.checkValue(value, t.OfType) // A single value stands for a list of one
+			return
+		}
+		for _, item := range value.List {
+			v.checkValue(item, t.OfType)
+		}
+		return
+	}
+
+	named := v.schema.Type(t.Name)
+	if named == nil {
+		return
+	}
+	switch named.Kind {
+	case KindScalar:
+		if !scalarAccepts(named.Name, value.Kind) {
+			v.report(value.Position, false, "%s cannot represent a value like %s.", named.Name, describeValue(value))
+		}
+	case KindEnum:
+		if value.Kind != ast.EnumValue {
+			v.report(value.Position, false, "Enum %q cannot represent non-enum value: %s.", named.Name, describeValue(value))
+			return
+		}
+		for _, enumValue := range named.EnumValues {
+			if enumValue.Name == value.Raw {
+				if enumValue.IsDeprecated {
+					v.report(value.Position, true, "The enum value \"%s.%s\" is deprecated. %s", named.Name, value.Raw, enumValue.DeprecationReason)
+				}
+				return
+			}
+		}
+		names := make([]string, len(named.EnumValues))
+		for i, enumValue := range named.EnumValues {
+			names[i] = enumValue.Name
+		}
+		v.report(value.Position, false, "Value %q does not exist in %q enum.%s", value.Raw, named.Name, suggestion(value.Raw, names))
+	case KindInputObject:
+		if value.Kind != ast.ObjectValue {
+			v.report(value.Position, false, "Expected value of type %q, found %s.", named.Name, describeValue(value))
+			return
+		}
+		v.checkObject(value, named)
+	}
+}
+
+func (v *validator) checkObject(value *ast.Value, t *SchemaType) {
+	given := make(map[string]bool, len(value.Fields))
+	for _, field := range value.Fields {
+		definition := t.InputField(field.Name)
+		if definition == nil {
+			names := make([]string, len(t.InputFields))
+			for i, f := range t.InputFields {
+				names[i] = f.Name
+			}
+			v.report(field.Position, false, "Field %q is not defined by type %q.%s", field.Name, t.Name, suggestion(field.Name, names))
+			continue
+		}
+		given[field.Name] = true
+		v.checkValue(field.Value, definition.Type)
+	}
+	for _, field := range t.InputFields {
+		if field.Type.IsNonNull() && field.DefaultValue == nil && !given[field.Name] {
+			v.report(value.Position, false, "Field \"%s.%s\" of required type %q was not provided.", t.Name, field.Name, field.Type)
+		}
+	}
+}
+
+// overlap reports whether an object can be of both types, so a fragment on one can be spread in the other
+func (v *validator) overlap(a, b *SchemaType) bool {
+	possible := v.possibleTypes(a)
+	for name := range v.possibleTypes(b) {
+		if possible[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) possibleTypes(t *SchemaType) map[string]bool {
+	if t.Kind == KindObject {
+		return map[string]bool{t.Name: true}
+	}
+	possible := make(map[string]bool, len(t.PossibleTypes))
+	for _, ref := range t.PossibleTypes {
+		possible[ref.NamedType()] = true
+	}
+	return possible
+}
+
+func (v *validator) typeNames() []string {
+	names := make([]string, len(v.schema.Types))
+	for i, t := range v.schema.Types {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func isCompositeType(t *SchemaType) bool {
+	return t.Kind == KindObject || t.Kind == KindInterface || t.Kind == KindUnion
+}
+
+// scalarAccepts reports whether a literal fits a scalar. Custom scalars accept any literal.
+func scalarAccepts(scalar string, kind ast.ValueKind) bool {
+	switch scalar {
+	case "Int":
+		return kind == ast.IntValue
+	case "Float":
+		return kind == ast.IntValue || kind == ast.FloatValue
+	case "String":
+		return kind == ast.StringValue
+	case "Boolean":
+		return kind == ast.BooleanValue
+	case "ID":
+		return kind == ast.StringValue || kind == ast.IntValue
+	}
+	return true
+}
+
+// describeValue renders a literal for messages
+func describeValue(value *ast.Value) string {
+	switch value.Kind {
+	case ast.ListValue:
+		return "a list"
+	case ast.ObjectValue:
+		return "an object"
+	}
+	return value.Raw
+}
+
+// typeRefOf converts a type written in the document into the schema's representation
+func typeRefOf(t *ast.Type) *TypeRef {
+	var ref *TypeRef
+	if t.Elem != nil {
+		ref = &TypeRef{Kind: KindList, OfType: typeRefOf(t.Elem)}
+	} else {
+		ref = &TypeRef{Kind: KindScalar, Name: t.Name} // The kind of named types doesn't matter here
+	}
+	if t.NonNull {
+		ref = &TypeRef{Kind: KindNonNull, OfType: ref}
+	}
+	return ref
+}
+
+// variableFits reports whether a variable can be used where a value of type expected goes. A
+// nullable variable fits a non-null position when it has a default.
+func variableFits(definition *ast.VariableDefinition, expected *TypeRef) bool {
+	if expected.IsNonNull() && !definition.Type.NonNull && definition.DefaultValue != nil && definition.DefaultValue.Kind != ast.NullValue {
+		return typeFits(definition.Type, expected.OfType)
+	}
+	return typeFits(definition.Type, expected)
+}
+
+func typeFits(t *ast.Type, expected *TypeRef) bool {
+	if expected.Kind == KindNonNull {
+		if !t.NonNull {
+			return false
+		}
+		nullable := *t
+		nullable.NonNull = false
+		return typeFits(&nullable, expected.OfType)
+	}
+	if t.NonNull {
+		nullable := *t
+		nullable.NonNull = false
+		return typeFits(&nullable, expected)
+	}
+	if expected.Kind == KindList {
+		return t.Elem != nil && typeFits(t.Elem, expected.OfType)
+	}
+	return t.Elem == nil && t.Name == expected.Name
+}
+
+// suggestion proposes the closest of options to a misspelt name
+func suggestion(name string, options []string) string {
+	best, bestDistance := "", len(name)*2/5+2 // Up to 40% of the name may differ
+	for _, option := range options {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(option)); d < bestDistance {
+			best, bestDistance = option, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" Did you mean %q?", best)
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
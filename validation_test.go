@@ -0,0 +1,292 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// testSchema parses the schema of schema_test.go
+func testSchema(t *testing.T) *Schema {
+	t.Helper()
+	schema, err := parseIntrospection([]byte(testIntrospection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := testSchema(t)
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		want      []string // Expected problems as "line:column: message", warnings prefixed with "warning "
+	}{
+		{
+			name: "valid query",
+			query: `query Users($limit: Int, $id: uuid!) {
+  users(limit: $limit, where: {id: $id}) { id role __typename }
+}`,
+			variables: map[string]interface{}{"id": "a"},
+		},
+		{
+			name:  "unknown field with a suggestion",
+			query: "query {\n  users { id rlee }\n}",
+			want:  []string{`2:14: Cannot query field "rlee" on type "users". Did you mean "role"?`},
+		},
+		{
+			name:  "unknown root field",
+			query: "{ accounts { id } }",
+			want:  []string{`1:3: Cannot query field "accounts" on type "query_root".`},
+		},
+		{
+			name:  "wrong argument types",
+			query: `{ users(limit: "ten", where: {id: 1, name: "x"}) { id } }`,
+			want: []string{
+				`1:16: Int cannot represent a value like "ten".`,
+				`1:38: Field "name" is not defined by type "users_bool_exp".`,
+			},
+		},
+		{
+			name:  "unknown argument",
+			query: "{ users(limt: 1) { id } }",
+			want:  []string{`1:9: Unknown argument "limt" on field "query_root.users". Did you mean "limit"?`},
+		},
+		{
+			name:  "missing required variable",
+			query: "query ($id: uuid!) { users(where: {id: $id}) { id } }",
+			want:  []string{`1:8: Variable "$id" of required type "uuid!" was not provided.`},
+		},
+		{
+			name:      "variable of the wrong type",
+			query:     "query ($limit: String) { users(limit: $limit) { id } }",
+			variables: map[string]interface{}{"limit": "1"},
+			want:      []string{`1:39: Variable "$limit" of type "String" used in position expecting type "Int".`},
+		},
+		{
+			name:  "undefined and unused variables",
+			query: "query ($unused: Int) { users(limit: $limit) { id } }",
+			want: []string{
+				`1:8: Variable "$unused" is never used.`,
+				`1:37: Variable "$limit" is not defined.`,
+			},
+		},
+		{
+			name:  "bad fragment spreads",
+			query: "{ users { ...Missing } ...UserFields }\nfragment UserFields on users { id }\nfragment Unused on users { id }",
+			want: []string{
+				`1:11: Unknown fragment "Missing".`,
+				`1:24: Fragment "UserFields" cannot be spread here as objects of type "query_root" can never be of type "users".`,
+				`3:1: Fragment "Unused" is never used.`,
+			},
+		},
+		{
+			name:  "fragment on an unknown type",
+			query: "{ users { ...F } }\nfragment F on user { id }",
+			want:  []string{`2:1: Unknown type "user". Did you mean "users"?`},
+		},
+		{
+			name:  "selections on leaves and composites",
+			query: "{ users { id { value } } legacy_users }",
+			want: []string{
+				`1:11: Field "id" must not have a selection since type "uuid!" has no subfields.`,
+				`warning 1:26: The field "query_root.legacy_users" is deprecated. Use users`,
+				`1:26: Field "legacy_users" of type "users" must have a selection of subfields.`,
+			},
+		},
+		{
+			name:  "directives",
+			query: "{ users @cached(ttl: \"1m\", ttl: 1) { id @include } }",
+			want: []string{
+				`1:22: Int cannot represent a value like "1m".`,
+				`1:28: There can be only one argument named "ttl".`,
+				`1:41: Unknown directive "@include".`,
+			},
+		},
+		{
+			name:  "unsupported operation type",
+			query: "subscription { users { id } }",
+			want:  []string{`1:1: Schema is not configured for subscriptions.`},
+		},
+		{
+			name:  "syntax error",
+			query: "{ users { id }",
+			want:  []string{`1:15: Syntax Error: expected Name, found <EOF>`},
+		},
+		{
+			name:  "introspection",
+			query: "{ __schema { types { name } } __type(name: \"users\") { name } }",
+		},
+		{
+			name:  "introspection query",
+			query: introspectionQuery,
+		},
+		{
+			name:      "introspection fragments and variables",
+			query:     "query ($name: String!) { __type(name: $name) { ...Type } }\nfragment Type on __Type { name fields { ...Missing } }",
+			variables: map[string]interface{}{"name": "users"},
+			want:      []string{`2:41: Unknown fragment "Missing".`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, problem := range schema.Validate(tt.query, tt.variables) {
+				text := problem.Error()
+				if problem.Warning {
+					text = "warning " + text
+				}
+				got = append(got, text)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSchemaValidateOperation(t *testing.T) {
+	schema := testSchema(t)
+	const query = `query User($id: uuid!) { users(where: {id: $id}) { id } }
+query Users($limit: Int!) { users(limit: $limit) { id } }`
+
+	tests := []struct {
+		operation string
+		variables map[string]interface{}
+		want      string
+	}{
+		{"User", map[string]interface{}{"id": "a"}, ""},
+		{"User", nil, `1:12: Variable "$id" of required type "uuid!" was not provided.`},
+		{"Users", map[string]interface{}{"limit": 1}, ""},
+		{"Users", map[string]interface{}{"id": "a"}, `2:13: Variable "$limit" of required type "Int!" was not provided.`},
+		{"", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			var got []string
+			for _, problem := range schema.ValidateOperation(query, tt.operation, tt.variables) {
+				got = append(got, problem.Error())
+			}
+			if strings.Join(got, "\n") != tt.want {
+				t.Errorf("ValidateOperation(%s) = %q, want %q", tt.operation, got, tt.want)
+			}
+		})
+	}
+}
+
+func (suite *Tests) TestBaseClient_Validation() {
+	newServer := func() (*httptest.Server, *int32) {
+		var requests int32
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			body, _ := io.ReadAll(r.Body)
+			if bytes.HasPrefix(body, []byte("[")) {
+				w.Write([]byte(`[{"data":{"users":[]}}]`))
+				return
+			}
+			w.Write([]byte(`{"data":{"users":[]}}`))
+		})), &requests
+	}
+
+	suite.T().Run("should fail invalid operations locally in strict mode", func(t *testing.T) {
+		server, requests := newServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithSchema(testSchema(t)), WithValidation(ValidationStrict))
+		assert.NoError(err)
+		_, err = b.Query("query {\n  users { id nmae }\n}", nil, nil)
+		var problems ValidationErrors
+		assert.True(errors.As(err, &problems))
+		assert.Equal(2, problems[0].Line)
+		assert.Equal(14, problems[0].Column)
+		assert.Equal(int32(0), atomic.LoadInt32(requests))
+
+		results, err := b.Batch(context.Background(), []BatchItem{{Query: "{ users { id } }"}, {Query: "{ users { nmae } }"}}, nil)
+		assert.NoError(err)
+		assert.NoError(results[0].Err)
+		assert.ErrorContains(results[1].Err, `Cannot query field "nmae"`)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+
+		// Deprecations are only logged
+		_, err = b.Query("{ legacy_users { id } }", nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(2), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should check the required variables of the operation run", func(t *testing.T) {
+		server, requests := newServer()
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithSchema(testSchema(t)), WithValidation(ValidationStrict))
+		assert.NoError(err)
+		doc, err := ParseDocument(`query User($id: uuid!) { users(where: {id: $id}) { id } }
+query Users($limit: Int) { users(limit: $limit) { id } }`)
+		assert.NoError(err)
+
+		_, err = b.QueryOperation(context.Background(), doc, "User", nil, nil)
+		assert.ErrorContains(err, `Variable "$id" of required type "uuid!" was not provided.`)
+		_, err = b.QueryOperation(context.Background(), doc, "Users", nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+
+		results, err := b.Batch(context.Background(), []BatchItem{
+			{Query: `query A($id: uuid!) { users(where: {id: $id}) { id } } query B { users { id } }`, OperationName: "A"},
+		}, nil)
+		assert.NoError(err)
+		assert.ErrorContains(results[0].Err, `Variable "$id" of required type "uuid!" was not provided.`)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should validate subscriptions and incremental queries", func(t *testing.T) {
+		server, accepts := newIncrementalServer(`{"data":{"users":[]},"hasNext":false}`)
+		defer server.Close()
+
+		b, err := New(WithEndpoint(server.URL), WithHTTPClient(server.Client()), WithSchema(testSchema(t)), WithValidation(ValidationStrict))
+		assert.NoError(err)
+		var problems ValidationErrors
+		_, err = b.QueryIncremental(context.Background(), "{ users { nmae } }", nil, nil)
+		assert.True(errors.As(err, &problems))
+		assert.ErrorContains(err, `Cannot query field "nmae"`)
+		_, err = b.Subscribe(context.Background(), "subscription { users { id } }", nil, nil)
+		assert.True(errors.As(err, &problems))
+		assert.ErrorContains(err, "Schema is not configured for subscriptions.")
+		assert.Empty(accepts)
+	})
+
+	suite.T().Run("should only log problems in warn mode", func(t *testing.T) {
+		server, requests := newServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetSchema(testSchema(t))
+		b.SetValidation(ValidationWarn)
+		_, err := b.Query("{ users { nmae } }", nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should not validate without a schema", func(t *testing.T) {
+		server, requests := newServer()
+		defer server.Close()
+
+		b := newBatchTestClient(server)
+		b.SetValidation(ValidationStrict)
+		_, err := b.Query("{ users { nmae } }", nil, nil)
+		assert.NoError(err)
+		assert.Equal(int32(1), atomic.LoadInt32(requests))
+	})
+
+	suite.T().Run("should reject unknown validation modes", func(t *testing.T) {
+		_, err := New(WithValidation("sometimes"))
+		assert.Error(err)
+	})
+}